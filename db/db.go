@@ -10,6 +10,14 @@ import (
 
 var db *sql.DB
 
+// Album is a row of the album table.
+type Album struct {
+	ID     int64
+	Title  string
+	Artist string
+	Price  float32
+}
+
 func main() {
 	cfg := mysql.Config{
 		User:   "root",
@@ -12,13 +12,6 @@ import (
 	"github.com/samber/lo"
 )
 
-type Album struct {
-	ID     int64
-	Title  string
-	Artist string
-	Price  float32
-}
-
 func connect() *sql.DB {
 	cfg := mysql.Config{
 		User:   "root",
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// config is the httpc config file, for example:
+//
+//	{
+//	  "default": "dev",
+//	  "environments": {
+//	    "dev": {"baseURL": "http://localhost:8080", "profile": "local"},
+//	    "shipos": {"baseURL": "https://api.shipos.cn/api", "profile": "shipos"}
+//	  },
+//	  "profiles": {
+//	    "local": {"type": "bearer", "token": "dev-token"},
+//	    "shipos": {"type": "headers", "headers": {"appkey": "...", "appsecret": "..."}}
+//	  }
+//	}
+//
+// Profile types are bearer, basic (user and password) and headers.
+type config struct {
+	Default      string                  `json:"default"`
+	Environments map[string]*environment `json:"environments"`
+	Profiles     map[string]*profile     `json:"profiles"`
+}
+
+type environment struct {
+	BaseURL string            `json:"baseURL"`
+	Profile string            `json:"profile"`
+	Headers map[string]string `json:"headers"`
+}
+
+type profile struct {
+	Type     string            `json:"type"`
+	Token    string            `json:"token"`
+	User     string            `json:"user"`
+	Password string            `json:"password"`
+	Headers  map[string]string `json:"headers"`
+}
+
+// defaultConfigPath is $HTTPC_CONFIG, or ~/.httpc.json.
+func defaultConfigPath() string {
+	if path := os.Getenv("HTTPC_CONFIG"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".httpc.json")
+}
+
+// loadConfig reads the config file. A missing file yields an empty config.
+func loadConfig(path string) (*config, error) {
+	cfg := &config{}
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read config failed: %s", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parse config %s failed: %s", path, err)
+	}
+	return cfg, nil
+}
+
+// environment returns the named environment, the default one when name is
+// empty, or nil when neither is set.
+func (c *config) environment(name string) (*environment, error) {
+	if name == "" {
+		name = c.Default
+	}
+	if name == "" {
+		return nil, nil
+	}
+	env, ok := c.Environments[name]
+	if !ok {
+		names := make([]string, 0, len(c.Environments))
+		for n := range c.Environments {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown environment %q, known: %s", name, strings.Join(names, ", "))
+	}
+	return env, nil
+}
+
+// authorize applies an auth profile to req. Headers given on the command line win.
+func (c *config) authorize(req *http.Request, name string) error {
+	if name == "" {
+		return nil
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown auth profile %q", name)
+	}
+	switch p.Type {
+	case "bearer":
+		if req.Header.Get("Authorization") == "" {
+			req.Header.Set("Authorization", "Bearer "+p.Token)
+		}
+	case "basic":
+		if req.Header.Get("Authorization") == "" {
+			req.SetBasicAuth(p.User, p.Password)
+		}
+	case "headers":
+		for k, v := range p.Headers {
+			if req.Header.Get(k) == "" {
+				req.Header.Set(k, v)
+			}
+		}
+	default:
+		return fmt.Errorf("auth profile %q has unknown type %q", name, p.Type)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	httpclient "http"
+	"http/loadtest"
+)
+
+type loadOptions struct {
+	duration    time.Duration
+	rate        float64
+	concurrency int
+	correct     bool
+	json        bool
+}
+
+func loadFlags(fs *flag.FlagSet) *loadOptions {
+	opts := &loadOptions{}
+	fs.DurationVar(&opts.duration, "load-duration", 0, "run a load test for this long instead of a single request")
+	fs.Float64Var(&opts.rate, "load-rps", 0, "requests per second of the load test; 0 sends back to back")
+	fs.IntVar(&opts.concurrency, "load-concurrency", 0, "workers of the load test")
+	fs.BoolVar(&opts.correct, "load-co", false, "correct load test latencies for coordinated omission")
+	fs.BoolVar(&opts.json, "load-json", false, "print the load test report as JSON")
+	return opts
+}
+
+// runLoad runs the load test with client, whose requests get the timeout
+// carried by ctx.
+func runLoad(ctx context.Context, opts *loadOptions, client *httpclient.HTTPClient, req *http.Request,
+	stdout io.Writer, stderr io.Writer) int {
+	report, err := loadtest.Run(ctx, loadtest.Config{
+		Request:                    req,
+		Client:                     client,
+		Duration:                   opts.duration,
+		Rate:                       opts.rate,
+		Concurrency:                opts.concurrency,
+		CorrectCoordinatedOmission: opts.correct,
+	})
+	if err != nil {
+		fmt.Fprintln(stderr, "httpc:", err)
+		return 1
+	}
+	if opts.json {
+		err = report.WriteJSON(stdout)
+	} else {
+		err = report.WriteText(stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "httpc:", err)
+		return 1
+	}
+	return 0
+}
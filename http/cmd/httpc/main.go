@@ -0,0 +1,164 @@
+// Command httpc sends HTTP requests through the http package's HTTPClient using
+// an httpie-like syntax:
+//
+//	httpc [flags] [METHOD] URL [ITEM ...]
+//
+// Items are Header:value, key=value (JSON string field), key:=json (raw JSON
+// field) and key==value (query parameter). Without a method, requests with
+// body fields are sent as POST and the others as GET. With -form, fields are
+// sent url-encoded instead of as a JSON object.
+//
+// Relative URLs such as /albums are resolved against the base URL of the
+// selected environment; see loadConfig for the config file format.
+//
+// With -load-duration the request is sent repeatedly as a load test and a
+// latency report is printed instead of the response.
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"time"
+
+	httpclient "http"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command and returns the process exit code. Requests are
+// sent by a client created with opts.
+func run(args []string, stdout io.Writer, stderr io.Writer, opts ...httpclient.Option) int {
+	fs := flag.NewFlagSet("httpc", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configPath := fs.String("config", defaultConfigPath(), "path of the config file with environments and auth profiles")
+	envName := fs.String("env", "", "named environment from the config file")
+	dryRun := fs.Bool("dry-run", false, "print the request instead of sending it")
+	asCurl := fs.Bool("curl", false, "print the request as a curl command instead of sending it")
+	form := fs.Bool("form", false, "send body fields url-encoded instead of as JSON")
+	color := fs.String("color", "auto", "colorize JSON output: auto, always or never")
+	timeout := fs.Duration("timeout", 30*time.Second, "request timeout, of each request in a load test")
+	load := loadFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: httpc [flags] [METHOD] URL [Header:value | key=value | key:=json | key==value ...]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(stderr, "httpc:", err)
+		return 1
+	}
+	env, err := cfg.environment(*envName)
+	if err != nil {
+		fmt.Fprintln(stderr, "httpc:", err)
+		return 1
+	}
+
+	cmd, err := parseCommand(fs.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, "httpc:", err)
+		fs.Usage()
+		return 2
+	}
+	req, err := cmd.request(env, *form)
+	if err != nil {
+		fmt.Fprintln(stderr, "httpc:", err)
+		return 1
+	}
+	if env != nil {
+		if err := cfg.authorize(req, env.Profile); err != nil {
+			fmt.Fprintln(stderr, "httpc:", err)
+			return 1
+		}
+	}
+
+	if *asCurl {
+		line, err := httpclient.CurlCommand(req)
+		if err != nil {
+			fmt.Fprintln(stderr, "httpc:", err)
+			return 1
+		}
+		fmt.Fprintln(stdout, line)
+		return 0
+	}
+	if *dryRun {
+		dump, err := httputil.DumpRequest(req, true)
+		if err != nil {
+			fmt.Fprintln(stderr, "httpc:", err)
+			return 1
+		}
+		stdout.Write(dump)
+		fmt.Fprintln(stdout)
+		return 0
+	}
+
+	// The flag replaces the client's default request timeout rather than
+	// adding an outer bound it would cut short.
+	client := httpclient.NewHTTPClient(opts...)
+	ctx := httpclient.WithRequestTimeout(context.Background(), *timeout)
+	if load.duration > 0 {
+		return runLoad(ctx, load, client, req, stdout, stderr)
+	}
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	body, err := client.Do(ctx, req)
+	var statusErr *httpclient.StatusError
+	if err != nil && !errors.As(err, &statusErr) {
+		fmt.Fprintln(stderr, "httpc:", err)
+		return 1
+	}
+	if statusErr != nil {
+		fmt.Fprintf(stderr, "HTTP %d %s\n", statusErr.StatusCode, http.StatusText(statusErr.StatusCode))
+	}
+
+	writeBody(stdout, body, useColor(*color, stdout))
+	if statusErr != nil {
+		// Like httpie, 4xx and 5xx responses exit with 4 and 5.
+		return statusErr.StatusCode / 100
+	}
+	return 0
+}
+
+// writeBody prints a response body, pretty-printing it when it is JSON.
+func writeBody(w io.Writer, body []byte, color bool) {
+	if pretty, ok := prettyJSON(body, color); ok {
+		w.Write(pretty)
+		fmt.Fprintln(w)
+		return
+	}
+	w.Write(body)
+	if len(body) > 0 && !bytes.HasSuffix(body, []byte("\n")) {
+		fmt.Fprintln(w)
+	}
+}
+
+func useColor(mode string, w io.Writer) bool {
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
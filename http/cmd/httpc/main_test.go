@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	httpclient "http"
+)
+
+func writeConfig(t *testing.T, baseURL string) string {
+	path := filepath.Join(t.TempDir(), "httpc.json")
+	config := fmt.Sprintf(`{
+		"default": "dev",
+		"environments": {"dev": {"baseURL": %q, "profile": "shipos", "headers": {"X-Env": "dev"}}},
+		"profiles": {"shipos": {"type": "headers", "headers": {"appkey": "shipos_959"}}}
+	}`, baseURL)
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestRunPostJSON(t *testing.T) {
+	fmt.Println("TestRunPostJSON")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || r.URL.Path != "/api/getLabels.php" || r.URL.Query().Get("debug") != "1" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		if r.Header.Get("appkey") != "shipos_959" || r.Header.Get("X-Env") != "dev" || r.Header.Get("X-Trace") != "abc" {
+			t.Errorf("unexpected headers %v", r.Header)
+		}
+		if string(body) != `{"orderid": "odr-1", "count": 2, "tags": ["a"]}` {
+			t.Errorf("unexpected body %s", body)
+		}
+		w.Write([]byte(`{"labels":[{"id":1,"ok":true,"url":null}]}`))
+	}))
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-config", writeConfig(t, server.URL), "-color", "never",
+		"/api/getLabels.php", "orderid=odr-1", "count:=2", `tags:=["a"]`, "debug==1", "X-Trace:abc"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("unexpected exit code %d: %s", code, stderr.String())
+	}
+	want := "{\n    \"labels\": [\n        {\n            \"id\": 1,\n            \"ok\": true,\n            \"url\": null\n        }\n    ]\n}\n"
+	if stdout.String() != want {
+		t.Fatalf("unexpected output:\n%s", stdout.String())
+	}
+}
+
+func TestRunStatusError(t *testing.T) {
+	fmt.Println("TestRunStatusError")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("not here"))
+	}))
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-config", "", "DELETE", server.URL + "/x"}, &stdout, &stderr)
+	if code != 4 || stdout.String() != "not here\n" || !strings.Contains(stderr.String(), "HTTP 404") {
+		t.Fatalf("unexpected result %d %q %q", code, stdout.String(), stderr.String())
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	fmt.Println("TestRunDryRun")
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-config", writeConfig(t, "https://api.shipos.cn"), "-dry-run", "-form", "PUT", "albums/1", "title=Jeru"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("unexpected exit code %d: %s", code, stderr.String())
+	}
+	out := stdout.String()
+	for _, want := range []string{"PUT /albums/1 HTTP/1.1", "Host: api.shipos.cn", "Appkey: shipos_959", "title=Jeru"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("dry run output misses %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRunCurl(t *testing.T) {
+	fmt.Println("TestRunCurl")
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-config", "", "-curl", "example.com/albums", "id==1"}, &stdout, &stderr)
+	if code != 0 || stdout.String() != "curl 'http://example.com/albums?id=1' -H 'Accept: application/json, */*;q=0.5'\n" {
+		t.Fatalf("unexpected result %d %q %q", code, stdout.String(), stderr.String())
+	}
+}
+
+func TestParseCommandErrors(t *testing.T) {
+	fmt.Println("TestParseCommandErrors")
+	for _, args := range [][]string{{}, {"get"}, {"http://x", "novalue"}, {"http://x", "a:={"}} {
+		if _, err := parseCommand(args); err == nil {
+			t.Fatalf("expected %v to fail", args)
+		}
+	}
+}
+
+func TestColorize(t *testing.T) {
+	fmt.Println("TestColorize")
+	out, ok := prettyJSON([]byte(`{"a":"b","n":-1.5e3,"t":true}`), true)
+	if !ok {
+		t.Fatalf("expected json to be recognized")
+	}
+	for _, want := range []string{colorKey + `"a"` + colorReset, colorString + `"b"` + colorReset,
+		colorNumber + "-1.5e3" + colorReset, colorLiteral + "true" + colorReset} {
+		if !strings.Contains(string(out), want) {
+			t.Fatalf("colorized output misses %q: %q", want, out)
+		}
+	}
+	if _, ok := prettyJSON([]byte("plain text"), true); ok {
+		t.Fatalf("expected plain text not to be treated as json")
+	}
+}
+
+func TestRunLoad(t *testing.T) {
+	fmt.Println("TestRunLoad")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-config", "", "-load-duration", "100ms", "-load-rps", "50", "-load-json", server.URL}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("unexpected exit code %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), `"requests": 5`) {
+		t.Fatalf("unexpected report:\n%s", stdout.String())
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	fmt.Println("TestRunTimeout")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// A request allowed a minute must not be cut off at the client's
+	// default timeout, so its deadline is checked instead of waited for.
+	var mu sync.Mutex
+	var left time.Duration
+	deadline := httpclient.WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		return httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if deadline, ok := req.Context().Deadline(); ok {
+				mu.Lock()
+				left = time.Until(deadline)
+				mu.Unlock()
+			}
+			return next.RoundTrip(req)
+		})
+	})
+
+	for _, args := range [][]string{
+		{"-config", "", "-timeout", "1m", server.URL},
+		// Load tests give each request the timeout.
+		{"-config", "", "-timeout", "1m", "-load-duration", "50ms", "-load-json", server.URL},
+	} {
+		left = 0
+		var stdout, stderr bytes.Buffer
+		if code := run(args, &stdout, &stderr, deadline); code != 0 {
+			t.Fatalf("%v: unexpected exit code %d: %s", args, code, stderr.String())
+		}
+		mu.Lock()
+		got := left
+		mu.Unlock()
+		if got <= httpclient.DefaultRequestTimeout || got > time.Minute {
+			t.Fatalf("%v: expected a deadline a minute away, got %v", args, got)
+		}
+	}
+}
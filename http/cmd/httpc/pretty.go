@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+const (
+	colorReset   = "\x1b[0m"
+	colorKey     = "\x1b[34m"
+	colorString  = "\x1b[32m"
+	colorNumber  = "\x1b[33m"
+	colorLiteral = "\x1b[35m"
+)
+
+// prettyJSON indents body and optionally colorizes it. It reports false when
+// body is not JSON.
+func prettyJSON(body []byte, color bool) ([]byte, bool) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') || !json.Valid(trimmed) {
+		return nil, false
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, trimmed, "", "    "); err != nil {
+		return nil, false
+	}
+	if !color {
+		return indented.Bytes(), true
+	}
+	return colorize(indented.Bytes()), true
+}
+
+// colorize adds ANSI colors to valid, indented JSON.
+func colorize(src []byte) []byte {
+	var out bytes.Buffer
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '"':
+			end := i + 1
+			for end < len(src) && src[end] != '"' {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end++
+			color := colorString
+			if next := skipSpace(src, end); next < len(src) && src[next] == ':' {
+				color = colorKey
+			}
+			out.WriteString(color)
+			out.Write(src[i:end])
+			out.WriteString(colorReset)
+			i = end
+		case c == '-' || (c >= '0' && c <= '9'):
+			end := i
+			for end < len(src) && bytes.IndexByte([]byte("+-.eE0123456789"), src[end]) >= 0 {
+				end++
+			}
+			out.WriteString(colorNumber)
+			out.Write(src[i:end])
+			out.WriteString(colorReset)
+			i = end
+		case c == 't' || c == 'f' || c == 'n':
+			end := i
+			for end < len(src) && src[end] >= 'a' && src[end] <= 'z' {
+				end++
+			}
+			out.WriteString(colorLiteral)
+			out.Write(src[i:end])
+			out.WriteString(colorReset)
+			i = end
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.Bytes()
+}
+
+func skipSpace(src []byte, i int) int {
+	for i < len(src) && (src[i] == ' ' || src[i] == '\n' || src[i] == '\t' || src[i] == '\r') {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var knownMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+// command is a parsed httpc invocation.
+type command struct {
+	method string
+	url    string
+	header http.Header
+	query  url.Values
+	// fields keeps body fields in the order they were given.
+	fields []field
+}
+
+type field struct {
+	key string
+	// value is a JSON encoded value for key:=json items and a plain string otherwise.
+	value string
+	raw   bool
+}
+
+func parseCommand(args []string) (*command, error) {
+	cmd := &command{header: make(http.Header), query: make(url.Values)}
+	if len(args) > 0 && knownMethods[strings.ToUpper(args[0])] {
+		cmd.method = strings.ToUpper(args[0])
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("missing url")
+	}
+	cmd.url = args[0]
+
+	for _, item := range args[1:] {
+		if err := cmd.addItem(item); err != nil {
+			return nil, err
+		}
+	}
+	return cmd, nil
+}
+
+// addItem classifies an item by its first separator, checking the longer
+// separators first so that key:=1 is not read as a header.
+func (cmd *command) addItem(item string) error {
+	sep, pos := "", -1
+	for i := 0; i < len(item) && pos < 0; i++ {
+		for _, s := range []string{"==", ":=", "=", ":"} {
+			if strings.HasPrefix(item[i:], s) {
+				sep, pos = s, i
+				break
+			}
+		}
+	}
+	if pos <= 0 {
+		return fmt.Errorf("invalid item %q", item)
+	}
+	key, value := item[:pos], item[pos+len(sep):]
+
+	switch sep {
+	case "==":
+		cmd.query.Add(key, value)
+	case ":=":
+		if !json.Valid([]byte(value)) {
+			return fmt.Errorf("invalid json in item %q", item)
+		}
+		cmd.fields = append(cmd.fields, field{key: key, value: value, raw: true})
+	case "=":
+		cmd.fields = append(cmd.fields, field{key: key, value: value})
+	case ":":
+		cmd.header.Add(key, strings.TrimSpace(value))
+	}
+	return nil
+}
+
+// request builds the HTTP request, resolving relative URLs against env.
+func (cmd *command) request(env *environment, form bool) (*http.Request, error) {
+	rawURL := cmd.url
+	switch {
+	case strings.Contains(rawURL, "://"):
+	case env != nil && env.BaseURL != "":
+		rawURL = strings.TrimRight(env.BaseURL, "/") + "/" + strings.TrimLeft(rawURL, "/")
+	case strings.HasPrefix(rawURL, ":"):
+		rawURL = "http://localhost" + rawURL
+	default:
+		rawURL = "http://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url %q: %s", cmd.url, err)
+	}
+	if len(cmd.query) > 0 {
+		q := u.Query()
+		for k, vs := range cmd.query {
+			for _, v := range vs {
+				q.Add(k, v)
+			}
+		}
+		u.RawQuery = q.Encode()
+	}
+
+	var body []byte
+	contentType := ""
+	if len(cmd.fields) > 0 {
+		if form {
+			values := make(url.Values)
+			for _, f := range cmd.fields {
+				if f.raw {
+					return nil, fmt.Errorf("raw json field %q cannot be sent as a form", f.key)
+				}
+				values.Add(f.key, f.value)
+			}
+			body = []byte(values.Encode())
+			contentType = "application/x-www-form-urlencoded; charset=utf-8"
+		} else {
+			body = jsonObject(cmd.fields)
+			contentType = "application/json"
+		}
+	}
+
+	method := cmd.method
+	if method == "" {
+		method = http.MethodGet
+		if body != nil {
+			method = http.MethodPost
+		}
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, u.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	if env != nil {
+		for k, v := range env.Headers {
+			req.Header.Set(k, v)
+		}
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if !form {
+		req.Header.Set("Accept", "application/json, */*;q=0.5")
+	}
+	for k, vs := range cmd.header {
+		req.Header[k] = vs
+	}
+	return req, nil
+}
+
+// jsonObject renders fields as a JSON object, keeping their order.
+func jsonObject(fields []field) []byte {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, f := range fields {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		key, _ := json.Marshal(f.key)
+		b.Write(key)
+		b.WriteString(": ")
+		if f.raw {
+			b.WriteString(f.value)
+		} else {
+			value, _ := json.Marshal(f.value)
+			b.Write(value)
+		}
+	}
+	b.WriteByte('}')
+	return b.Bytes()
+}
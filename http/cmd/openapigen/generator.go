@@ -0,0 +1,733 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// methods lists the HTTP methods HTTPRequester can issue, in generation order.
+var methods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+
+type generator struct {
+	doc          *document
+	pkg          string
+	clientImport string
+
+	buf     bytes.Buffer
+	pending []namedSchema
+	emitted map[string]bool
+	useTime bool
+}
+
+// namedSchema is an object schema waiting to be emitted as a struct type.
+type namedSchema struct {
+	name   string
+	schema *schema
+}
+
+// generate renders a typed client for doc into package pkg.
+func generate(doc *document, pkg string, clientImport string) ([]byte, error) {
+	g := &generator{doc: doc, pkg: pkg, clientImport: clientImport, emitted: make(map[string]bool)}
+
+	g.writeRuntime()
+	for _, name := range sortedKeys(doc.Components.Schemas) {
+		g.writeComponent(goName(name), doc.Components.Schemas[name])
+	}
+	g.flushPending()
+	for _, path := range sortedKeys(doc.Paths) {
+		item := doc.Paths[path]
+		for _, method := range methods {
+			op := item.operation(method)
+			if op == nil {
+				continue
+			}
+			if err := g.writeOperation(method, path, item, op); err != nil {
+				return nil, err
+			}
+		}
+	}
+	g.flushPending()
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by openapigen. DO NOT EDIT.\n\n")
+	if doc.Info.Title != "" {
+		fmt.Fprintf(&out, "// Package %s is a typed client for the %s API.\n", pkg, doc.Info.Title)
+	}
+	fmt.Fprintf(&out, "package %s\n\nimport (\n", pkg)
+	for _, imp := range []string{"context", "encoding/base64", "encoding/json", "errors", "fmt", "net/url", "strings"} {
+		fmt.Fprintf(&out, "%q\n", imp)
+	}
+	if g.useTime {
+		fmt.Fprintf(&out, "%q\n", "time")
+	}
+	fmt.Fprintf(&out, "\nhttpclient %q\n)\n\n", clientImport)
+	out.Write(g.buf.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code failed: %s", err)
+	}
+	return src, nil
+}
+
+func (p *pathItem) operation(method string) *operation {
+	switch method {
+	case "GET":
+		return p.Get
+	case "POST":
+		return p.Post
+	case "PUT":
+		return p.Put
+	case "PATCH":
+		return p.Patch
+	case "DELETE":
+		return p.Delete
+	}
+	return nil
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// writeRuntime emits the client type and helpers shared by all operations.
+func (g *generator) writeRuntime() {
+	g.printf(`// AuthFunc adds credentials to the headers of an outgoing request.
+type AuthFunc func(ctx context.Context, headers map[string]string) error
+
+// APIKeyAuth sends key in the given header.
+func APIKeyAuth(header string, key string) AuthFunc {
+	return func(ctx context.Context, headers map[string]string) error {
+		headers[header] = key
+		return nil
+	}
+}
+
+// BearerAuth sends token as a bearer token.
+func BearerAuth(token string) AuthFunc {
+	return func(ctx context.Context, headers map[string]string) error {
+		headers["Authorization"] = "Bearer " + token
+		return nil
+	}
+}
+
+// BasicAuth sends user and password with HTTP basic authentication.
+func BasicAuth(user string, password string) AuthFunc {
+	return func(ctx context.Context, headers map[string]string) error {
+		headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+		return nil
+	}
+}
+
+// Client calls the API through an HTTPRequester.
+type Client struct {
+	BaseURL   string
+	Requester httpclient.HTTPRequester
+	// Auth, when set, adds credentials to every operation that requires them.
+	Auth AuthFunc
+}
+
+func NewClient(baseURL string, requester httpclient.HTTPRequester) *Client {
+	return &Client{BaseURL: baseURL, Requester: requester}
+}
+
+func (c *Client) url(path string, query url.Values) string {
+	u := strings.TrimRight(c.BaseURL, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+func (c *Client) headers(ctx context.Context, secured bool) (map[string]string, error) {
+	headers := map[string]string{"Accept": "application/json"}
+	if secured && c.Auth != nil {
+		if err := c.Auth(ctx, headers); err != nil {
+			return nil, fmt.Errorf("authorize request failed: %%s", err)
+		}
+	}
+	return headers, nil
+}
+
+func decodeResponse(operation string, body []byte, out interface{}) error {
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decode %%s response failed: %%s", operation, err)
+	}
+	return nil
+}
+
+func asStatusError(err error) (*httpclient.StatusError, bool) {
+	var statusErr *httpclient.StatusError
+	ok := errors.As(err, &statusErr)
+	return statusErr, ok
+}
+
+`)
+}
+
+// writeComponent emits a named type for a component schema.
+func (g *generator) writeComponent(name string, s *schema) {
+	if isStruct(s) {
+		g.pending = append(g.pending, namedSchema{name: name, schema: s})
+		return
+	}
+	g.emitted[name] = true
+	if s.Description != "" {
+		g.printf("// %s %s\n", name, oneLine(s.Description))
+	}
+	if s.Type == "string" && len(s.Enum) > 0 {
+		g.printf("type %s string\n\nconst (\n", name)
+		for _, v := range s.Enum {
+			value := fmt.Sprint(v)
+			g.printf("%s%s %s = %q\n", name, goName(value), name, value)
+		}
+		g.printf(")\n\n")
+		return
+	}
+	g.printf("type %s %s\n\n", name, g.goType(s, name))
+}
+
+// flushPending emits queued struct types, including those discovered while emitting.
+func (g *generator) flushPending() {
+	for len(g.pending) > 0 {
+		next := g.pending[0]
+		g.pending = g.pending[1:]
+		if g.emitted[next.name] {
+			continue
+		}
+		g.emitted[next.name] = true
+		g.writeStruct(next.name, next.schema)
+	}
+}
+
+func (g *generator) writeStruct(name string, s *schema) {
+	props, required := g.collectProperties(s)
+	if s.Description != "" {
+		g.printf("// %s %s\n", name, oneLine(s.Description))
+	}
+	g.printf("type %s struct {\n", name)
+	for _, prop := range sortedKeys(props) {
+		field := goName(prop)
+		typ := g.goType(props[prop], name+field)
+		tag := prop
+		if !required[prop] {
+			tag += ",omitempty"
+			if g.isNamedStruct(props[prop]) {
+				typ = "*" + typ
+			}
+		}
+		if desc := props[prop].Description; desc != "" {
+			g.printf("// %s %s\n", field, oneLine(desc))
+		}
+		g.printf("%s %s `json:%q`\n", field, typ, tag)
+	}
+	g.printf("}\n\n")
+}
+
+// collectProperties merges the properties of a schema and its allOf members.
+func (g *generator) collectProperties(s *schema) (map[string]*schema, map[string]bool) {
+	props := make(map[string]*schema)
+	required := make(map[string]bool)
+	var walk func(s *schema)
+	walk = func(s *schema) {
+		if s == nil {
+			return
+		}
+		if s.Ref != "" {
+			walk(g.doc.Components.Schemas[refName(s.Ref)])
+			return
+		}
+		for _, member := range s.AllOf {
+			walk(member)
+		}
+		for k, v := range s.Properties {
+			props[k] = v
+		}
+		for _, r := range s.Required {
+			required[r] = true
+		}
+	}
+	walk(s)
+	return props, required
+}
+
+func isStruct(s *schema) bool {
+	return s != nil && s.Ref == "" && (len(s.Properties) > 0 || len(s.AllOf) > 0)
+}
+
+func (g *generator) isNamedStruct(s *schema) bool {
+	if s.Ref != "" {
+		return isStruct(g.doc.Components.Schemas[refName(s.Ref)])
+	}
+	return isStruct(s)
+}
+
+// goType maps a schema to a Go type, queueing inline objects as types named hint.
+func (g *generator) goType(s *schema, hint string) string {
+	if s == nil {
+		return "json.RawMessage"
+	}
+	if s.Ref != "" {
+		return goName(refName(s.Ref))
+	}
+	if isStruct(s) {
+		g.pending = append(g.pending, namedSchema{name: hint, schema: s})
+		return hint
+	}
+	switch s.Type {
+	case "string":
+		if s.Format == "date-time" {
+			g.useTime = true
+			return "time.Time"
+		}
+		return "string"
+	case "integer":
+		if s.Format == "int32" {
+			return "int32"
+		}
+		return "int64"
+	case "number":
+		if s.Format == "float" {
+			return "float32"
+		}
+		return "float64"
+	case "boolean":
+		return "bool"
+	case "array":
+		return "[]" + g.goType(s.Items, hint+"Item")
+	case "object":
+		var additional schema
+		if len(s.AdditionalProperties) > 0 && s.AdditionalProperties[0] == '{' {
+			if err := json.Unmarshal(s.AdditionalProperties, &additional); err == nil {
+				return "map[string]" + g.goType(&additional, hint+"Value")
+			}
+		}
+		return "map[string]interface{}"
+	}
+	return "json.RawMessage"
+}
+
+// operationParam is a resolved operation parameter with its Go field name.
+type operationParam struct {
+	*parameter
+	field string
+	typ   string
+}
+
+func (g *generator) writeOperation(method string, path string, item *pathItem, op *operation) error {
+	name := op.OperationID
+	if name == "" {
+		name = strings.ToLower(method) + " " + path
+	}
+	name = goName(name)
+
+	params, err := g.operationParams(name, item, op)
+	if err != nil {
+		return err
+	}
+
+	var bodyType string
+	if op.RequestBody != nil {
+		body, err := g.doc.resolveRequestBody(op.RequestBody)
+		if err != nil {
+			return err
+		}
+		if s := jsonSchema(body.Content); s != nil {
+			bodyType = g.goType(s, name+"Request")
+		}
+	}
+
+	successType, err := g.successResponse(name, op)
+	if err != nil {
+		return err
+	}
+	errorCodes := operationErrorCodes(op)
+
+	if len(params) > 0 {
+		g.printf("// %sParams holds the parameters of %s.\ntype %sParams struct {\n", name, name, name)
+		for _, p := range params {
+			if p.Description != "" {
+				g.printf("// %s %s\n", p.field, oneLine(p.Description))
+			}
+			g.printf("%s %s\n", p.field, p.typ)
+		}
+		g.printf("}\n\n")
+	}
+
+	var args []string
+	args = append(args, "ctx context.Context")
+	if len(params) > 0 {
+		args = append(args, "params "+name+"Params")
+	}
+	if bodyType != "" {
+		args = append(args, "body "+bodyType)
+	}
+
+	results := "error"
+	zero := ""
+	if successType != "" {
+		results = "(" + successType + ", error)"
+		zero = "nil, "
+		if !strings.HasPrefix(successType, "[]") && !strings.HasPrefix(successType, "map[") {
+			results = "(*" + successType + ", error)"
+		}
+	}
+
+	if op.Summary != "" {
+		g.printf("// %s %s\n", name, oneLine(lowerFirst(op.Summary)))
+	}
+	g.printf("//\n// %s %s\n", method, path)
+	g.printf("func (c *Client) %s(%s) %s {\n", name, strings.Join(args, ", "), results)
+
+	pathExpr, err := g.pathExpr(name, path, params)
+	if err != nil {
+		return err
+	}
+	g.printf("path := %s\n", pathExpr)
+	g.printf("query := url.Values{}\n")
+	for _, p := range params {
+		if p.In == "query" {
+			g.writeQueryParam(p)
+		}
+	}
+	g.printf("headers, err := c.headers(ctx, %t)\nif err != nil {\nreturn %serr\n}\n", g.secured(op), zero)
+	for _, p := range params {
+		if p.In == "header" {
+			if strings.HasPrefix(p.typ, "*") {
+				g.printf("if params.%s != nil {\nheaders[%q] = %s\n}\n", p.field, p.Name, paramString(p.typ, "*params."+p.field))
+			} else {
+				g.printf("headers[%q] = %s\n", p.Name, paramString(p.typ, "params."+p.field))
+			}
+		}
+	}
+
+	call := ""
+	switch method {
+	case "GET":
+		call = "c.Requester.GetWithHeader(ctx, c.url(path, query), headers)"
+	case "DELETE":
+		call = "c.Requester.DeleteWithHeader(ctx, c.url(path, query), headers)"
+	default:
+		requester := map[string]string{"POST": "PostWithHeader", "PUT": "PutWithHeader", "PATCH": "PatchWithHeader"}[method]
+		payload := "nil"
+		if bodyType != "" {
+			g.printf("payload, err := json.Marshal(body)\nif err != nil {\nreturn %sfmt.Errorf(\"encode %s request failed: %%s\", err)\n}\n", zero, name)
+			g.printf("headers[\"Content-Type\"] = \"application/json\"\n")
+			payload = "payload"
+		}
+		call = fmt.Sprintf("c.Requester.%s(ctx, c.url(path, query), %s, headers)", requester, payload)
+	}
+
+	if successType != "" {
+		g.printf("resp, err := %s\n", call)
+	} else {
+		g.printf("_, err = %s\n", call)
+	}
+	if len(errorCodes) > 0 {
+		g.printf("if err != nil {\nreturn %s%sError(err)\n}\n", zero, lowerFirst(name))
+	} else {
+		g.printf("if err != nil {\nreturn %serr\n}\n", zero)
+	}
+	switch {
+	case successType == "":
+		g.printf("return nil\n}\n\n")
+	case strings.HasPrefix(results, "(*"):
+		g.printf("var out %s\nif err := decodeResponse(%q, resp, &out); err != nil {\nreturn nil, err\n}\nreturn &out, nil\n}\n\n", successType, name)
+	default:
+		g.printf("var out %s\nif err := decodeResponse(%q, resp, &out); err != nil {\nreturn nil, err\n}\nreturn out, nil\n}\n\n", successType, name)
+	}
+
+	if err := g.writeOperationErrors(name, op, errorCodes); err != nil {
+		return err
+	}
+	g.flushPending()
+	return nil
+}
+
+func (g *generator) operationParams(name string, item *pathItem, op *operation) ([]operationParam, error) {
+	byKey := make(map[string]*parameter)
+	var order []string
+	for _, list := range [][]*parameter{item.Parameters, op.Parameters} {
+		for _, raw := range list {
+			p, err := g.doc.resolveParameter(raw)
+			if err != nil {
+				return nil, err
+			}
+			if p.In == "cookie" {
+				continue
+			}
+			key := p.In + ":" + p.Name
+			if _, ok := byKey[key]; !ok {
+				order = append(order, key)
+			}
+			byKey[key] = p
+		}
+	}
+
+	params := make([]operationParam, 0, len(order))
+	for _, key := range order {
+		p := byKey[key]
+		field := goName(p.Name)
+		typ := g.goType(p.Schema, name+field)
+		if p.Schema == nil {
+			typ = "string"
+		}
+		if !p.Required && p.In != "path" && !strings.HasPrefix(typ, "[]") {
+			typ = "*" + typ
+		}
+		params = append(params, operationParam{parameter: p, field: field, typ: typ})
+	}
+	return params, nil
+}
+
+// pathExpr builds the expression of an operation's path. Every placeholder
+// must be a declared path parameter.
+func (g *generator) pathExpr(name string, path string, params []operationParam) (string, error) {
+	fields := make(map[string]operationParam)
+	for _, p := range params {
+		if p.In == "path" {
+			fields[p.Name] = p
+		}
+	}
+	var parts []string
+	rest := path
+	for {
+		start := strings.Index(rest, "{")
+		end := strings.Index(rest, "}")
+		if start < 0 || end < start {
+			break
+		}
+		if start > 0 {
+			parts = append(parts, strconv.Quote(rest[:start]))
+		}
+		p, ok := fields[rest[start+1:end]]
+		if !ok {
+			return "", fmt.Errorf("%s: path parameter %s of %s is not declared", name, rest[start+1:end], path)
+		}
+		parts = append(parts, fmt.Sprintf("url.PathEscape(%s)", paramString(p.typ, "params."+p.field)))
+		rest = rest[end+1:]
+	}
+	if rest != "" || len(parts) == 0 {
+		parts = append(parts, strconv.Quote(rest))
+	}
+	return strings.Join(parts, " + "), nil
+}
+
+func (g *generator) writeQueryParam(p operationParam) {
+	switch {
+	case strings.HasPrefix(p.typ, "[]"):
+		g.printf("for _, v := range params.%s {\nquery.Add(%q, %s)\n}\n", p.field, p.Name, paramString(p.typ, "v"))
+	case strings.HasPrefix(p.typ, "*"):
+		g.printf("if params.%s != nil {\nquery.Set(%q, %s)\n}\n", p.field, p.Name, paramString(p.typ, "*params."+p.field))
+	default:
+		g.printf("query.Set(%q, %s)\n", p.Name, paramString(p.typ, "params."+p.field))
+	}
+}
+
+// paramString renders the value expr of a parameter of type typ, or of its
+// elements, as it is sent: times in RFC 3339, other values as fmt prints them.
+func paramString(typ string, expr string) string {
+	if strings.TrimLeft(typ, "*[]") == "time.Time" {
+		if strings.HasPrefix(expr, "*") {
+			expr = "(" + expr + ")"
+		}
+		return expr + ".Format(time.RFC3339)"
+	}
+	return "fmt.Sprint(" + expr + ")"
+}
+
+// secured reports whether an operation requires the client's auth hook.
+func (g *generator) secured(op *operation) bool {
+	if op.Security != nil {
+		return len(*op.Security) > 0
+	}
+	return len(g.doc.Security) > 0
+}
+
+// successResponse finds the first documented 2xx response with a JSON body.
+// Bodies of other content types are not supported, rather than dropped.
+func (g *generator) successResponse(name string, op *operation) (string, error) {
+	for _, code := range sortedKeys(op.Responses) {
+		status, err := strconv.Atoi(code)
+		if err != nil || status < 200 || status >= 300 {
+			continue
+		}
+		resp, err := g.doc.resolveResponse(op.Responses[code])
+		if err != nil {
+			return "", err
+		}
+		if s := jsonSchema(resp.Content); s != nil {
+			return g.goType(s, name+"Response"), nil
+		}
+		if len(resp.Content) > 0 && !hasJSONContent(resp.Content) {
+			return "", fmt.Errorf("%s: unsupported %s response content type %s", name, code,
+				strings.Join(sortedKeys(resp.Content), ", "))
+		}
+		return "", nil
+	}
+	return "", nil
+}
+
+// operationErrorCodes lists the documented non-2xx responses of an operation in
+// matching order: exact codes before ranges, and ranges before the default.
+func operationErrorCodes(op *operation) []string {
+	var codes []string
+	for _, code := range sortedKeys(op.Responses) {
+		if !strings.HasPrefix(code, "2") {
+			codes = append(codes, code)
+		}
+	}
+	sort.SliceStable(codes, func(i, j int) bool { return codeRank(codes[i]) < codeRank(codes[j]) })
+	return codes
+}
+
+// writeOperationErrors emits a typed error per documented non-2xx response and a
+// function mapping status errors onto them.
+func (g *generator) writeOperationErrors(name string, op *operation, codes []string) error {
+	if len(codes) == 0 {
+		return nil
+	}
+
+	for _, code := range codes {
+		resp, err := g.doc.resolveResponse(op.Responses[code])
+		if err != nil {
+			return err
+		}
+		typeName := errorTypeName(name, code)
+		g.printf("// %s is returned by %s when the server answers %s.\n", typeName, name, describeCode(code, resp.Description))
+		g.printf("type %s struct {\n", typeName)
+		if s := jsonSchema(resp.Content); s != nil {
+			g.printf("// Body holds the decoded payload when it matches the documented schema.\nBody %s\n", g.goType(s, typeName+"Body"))
+		}
+		g.printf("Err *httpclient.StatusError\n}\n\n")
+		g.printf("func (e *%s) Error() string {\nreturn fmt.Sprintf(\"%s: %%s\", e.Err)\n}\n\n", typeName, name)
+		g.printf("func (e *%s) Unwrap() error {\nreturn e.Err\n}\n\n", typeName)
+	}
+
+	g.printf("func %sError(err error) error {\nstatusErr, ok := asStatusError(err)\nif !ok {\nreturn err\n}\n", lowerFirst(name))
+	for _, code := range codes {
+		resp, _ := g.doc.resolveResponse(op.Responses[code])
+		decode := jsonSchema(resp.Content) != nil
+		typeName := errorTypeName(name, code)
+		if code == "default" {
+			g.printf("e := &%s{Err: statusErr}\n", typeName)
+			if decode {
+				g.printf("_ = json.Unmarshal(statusErr.Body, &e.Body)\n")
+			}
+			g.printf("return e\n}\n\n")
+			return nil
+		}
+		if strings.HasSuffix(strings.ToUpper(code), "XX") {
+			g.printf("if statusErr.StatusCode/100 == %c {\n", code[0])
+		} else {
+			g.printf("if statusErr.StatusCode == %s {\n", code)
+		}
+		g.printf("e := &%s{Err: statusErr}\n", typeName)
+		if decode {
+			g.printf("_ = json.Unmarshal(statusErr.Body, &e.Body)\n")
+		}
+		g.printf("return e\n}\n")
+	}
+	g.printf("return err\n}\n\n")
+	return nil
+}
+
+func errorTypeName(operation string, code string) string {
+	if code == "default" {
+		return operation + "DefaultError"
+	}
+	return operation + strings.ToUpper(code) + "Error"
+}
+
+func codeRank(code string) int {
+	switch {
+	case code == "default":
+		return 2
+	case strings.HasSuffix(strings.ToUpper(code), "XX"):
+		return 1
+	}
+	return 0
+}
+
+func describeCode(code string, description string) string {
+	text := code
+	if code == "default" {
+		text = "an undocumented error status"
+	}
+	if description != "" {
+		text += ": " + oneLine(description)
+	}
+	return text
+}
+
+// commonInitialisms are written in upper case in Go names, as golint asks.
+var commonInitialisms = map[string]bool{
+	"ACL": true, "API": true, "ASCII": true, "CPU": true, "CSS": true, "DNS": true, "EOF": true,
+	"GUID": true, "HTML": true, "HTTP": true, "HTTPS": true, "ID": true, "IP": true, "JSON": true,
+	"LHS": true, "QPS": true, "RAM": true, "RHS": true, "RPC": true, "SLA": true, "SMTP": true,
+	"SQL": true, "SSH": true, "TCP": true, "TLS": true, "TTL": true, "UDP": true, "UI": true,
+	"UID": true, "UUID": true, "URI": true, "URL": true, "UTF8": true, "VM": true, "XML": true,
+	"XMPP": true, "XSRF": true, "XSS": true,
+}
+
+// goName converts an identifier from the document into an exported Go name.
+// Words are split at punctuation and at lower to upper case changes, so
+// "x-request-id" and "petId" become XRequestID and PetID.
+func goName(s string) string {
+	var b strings.Builder
+	var word []rune
+	flush := func() {
+		if len(word) == 0 {
+			return
+		}
+		if upper := strings.ToUpper(string(word)); commonInitialisms[upper] {
+			b.WriteString(upper)
+		} else {
+			word[0] = unicode.ToUpper(word[0])
+			b.WriteString(string(word))
+		}
+		word = word[:0]
+	}
+	for _, r := range s {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+			continue
+		case unicode.IsUpper(r) && len(word) > 0 && !unicode.IsUpper(word[len(word)-1]):
+			flush()
+		}
+		word = append(word, r)
+	}
+	flush()
+	name := b.String()
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "X" + name
+	}
+	return name
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
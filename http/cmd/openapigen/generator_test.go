@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGenerateMatchesCheckedInExample(t *testing.T) {
+	fmt.Println("TestGenerateMatchesCheckedInExample")
+	data, err := os.ReadFile("../../examples/petstore/petstore.yaml")
+	if err != nil {
+		t.Fatalf("failed to read spec: %v", err)
+	}
+	doc, err := parseDocument(data)
+	if err != nil {
+		t.Fatalf("failed to parse spec: %v", err)
+	}
+	src, err := generate(doc, "petstore", "http")
+	if err != nil {
+		t.Fatalf("failed to generate client: %v", err)
+	}
+
+	want, err := os.ReadFile("../../examples/petstore/client.gen.go")
+	if err != nil {
+		t.Fatalf("failed to read generated client: %v", err)
+	}
+	if !bytes.Equal(src, want) {
+		t.Fatalf("examples/petstore/client.gen.go is stale, run go generate")
+	}
+}
+
+func TestGenerateFromJSON(t *testing.T) {
+	fmt.Println("TestGenerateFromJSON")
+	doc, err := parseDocument([]byte(`{
+		"openapi": "3.0.0",
+		"info": {"title": "Orders", "version": "1"},
+		"paths": {
+			"/orders/{order-id}/labels": {
+				"post": {
+					"parameters": [{"name": "order-id", "in": "path", "required": true, "schema": {"type": "string"}}],
+					"requestBody": {"content": {"application/json": {"schema": {
+						"type": "object",
+						"properties": {"format": {"type": "string"}, "meta": {"type": "object", "additionalProperties": {"type": "integer"}}}
+					}}}},
+					"responses": {"200": {"description": "ok", "content": {"application/json": {"schema": {
+						"type": "object",
+						"properties": {"created": {"type": "string", "format": "date-time"}}
+					}}}}}
+				}
+			}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("failed to parse spec: %v", err)
+	}
+	src, err := generate(doc, "orders", "http")
+	if err != nil {
+		t.Fatalf("failed to generate client: %v", err)
+	}
+
+	for _, want := range []string{
+		`func (c *Client) PostOrdersOrderIDLabels(ctx context.Context, params PostOrdersOrderIDLabelsParams, body PostOrdersOrderIDLabelsRequest) (*PostOrdersOrderIDLabelsResponse, error)`,
+		`path := "/orders/" + url.PathEscape(fmt.Sprint(params.OrderID)) + "/labels"`,
+		`Meta   map[string]int64 ` + "`json:\"meta,omitempty\"`",
+		`Created time.Time ` + "`json:\"created,omitempty\"`",
+		`c.headers(ctx, false)`,
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Fatalf("generated code misses %q:\n%s", want, src)
+		}
+	}
+}
+
+func TestGoName(t *testing.T) {
+	fmt.Println("TestGoName")
+	for in, want := range map[string]string{
+		"id":           "ID",
+		"petId":        "PetID",
+		"X-Request-Id": "XRequestID",
+		"listPets":     "ListPets",
+		"get /api/url": "GetAPIURL",
+		"HTTPServer":   "HTTPServer",
+		"utf8-name":    "UTF8Name",
+		"2fa":          "X2fa",
+	} {
+		if got := goName(in); got != want {
+			t.Fatalf("goName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestGenerateRejectsNonJSONResponses(t *testing.T) {
+	fmt.Println("TestGenerateRejectsNonJSONResponses")
+	doc, err := parseDocument([]byte(`{
+		"openapi": "3.0.0",
+		"info": {"title": "Labels", "version": "1"},
+		"paths": {
+			"/labels/{id}": {
+				"get": {
+					"operationId": "getLabel",
+					"parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}],
+					"responses": {"200": {"description": "ok", "content": {"application/pdf": {"schema": {"type": "string", "format": "binary"}}}}}
+				}
+			}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("failed to parse spec: %v", err)
+	}
+	_, err = generate(doc, "labels", "http")
+	if err == nil || !strings.Contains(err.Error(), "GetLabel: unsupported 200 response content type application/pdf") {
+		t.Fatalf("expected the pdf response to be rejected, got %v", err)
+	}
+}
+
+func TestGenerateTimeParams(t *testing.T) {
+	fmt.Println("TestGenerateTimeParams")
+	doc, err := parseDocument([]byte(`{
+		"openapi": "3.0.0",
+		"info": {"title": "Shipments", "version": "1"},
+		"paths": {
+			"/shipments/{day}": {
+				"get": {
+					"operationId": "listShipments",
+					"parameters": [
+						{"name": "day", "in": "path", "required": true, "schema": {"type": "string", "format": "date-time"}},
+						{"name": "since", "in": "query", "schema": {"type": "string", "format": "date-time"}},
+						{"name": "at", "in": "query", "schema": {"type": "array", "items": {"type": "string", "format": "date-time"}}},
+						{"name": "X-Sent-At", "in": "header", "required": true, "schema": {"type": "string", "format": "date-time"}}
+					],
+					"responses": {"204": {"description": "ok"}}
+				}
+			}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("failed to parse spec: %v", err)
+	}
+	src, err := generate(doc, "shipments", "http")
+	if err != nil {
+		t.Fatalf("failed to generate client: %v", err)
+	}
+	for _, want := range []string{
+		`path := "/shipments/" + url.PathEscape(params.Day.Format(time.RFC3339))`,
+		`query.Set("since", (*params.Since).Format(time.RFC3339))`,
+		`query.Add("at", v.Format(time.RFC3339))`,
+		`headers["X-Sent-At"] = params.XSentAt.Format(time.RFC3339)`,
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Fatalf("generated code misses %q:\n%s", want, src)
+		}
+	}
+}
+
+func TestGenerateRejectsUndeclaredPathParams(t *testing.T) {
+	fmt.Println("TestGenerateRejectsUndeclaredPathParams")
+	doc, err := parseDocument([]byte(`{
+		"openapi": "3.0.0",
+		"info": {"title": "Pets", "version": "1"},
+		"paths": {"/pets/{petId}": {"get": {"operationId": "getPet", "responses": {"204": {"description": "ok"}}}}}
+	}`))
+	if err != nil {
+		t.Fatalf("failed to parse spec: %v", err)
+	}
+	_, err = generate(doc, "pets", "http")
+	if err == nil || err.Error() != "GetPet: path parameter petId of /pets/{petId} is not declared" {
+		t.Fatalf("expected the undeclared parameter to be rejected, got %v", err)
+	}
+}
+
+func TestParseDocumentRejectsSwagger(t *testing.T) {
+	fmt.Println("TestParseDocumentRejectsSwagger")
+	if _, err := parseDocument([]byte(`{"swagger": "2.0"}`)); err == nil {
+		t.Fatalf("expected swagger 2.0 documents to be rejected")
+	}
+}
@@ -0,0 +1,54 @@
+// Command openapigen generates a typed Go client for an OpenAPI 3 document.
+//
+// The generated client issues its calls through the http package's HTTPRequester,
+// so any HTTPClient configuration applies to it. Typical use is a go:generate
+// directive next to the spec:
+//
+//	//go:generate go run http/cmd/openapigen -spec petstore.yaml -package petstore -out client.gen.go
+//
+// Specs may be JSON or YAML. Only JSON request and response bodies are supported.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+func main() {
+	spec := flag.String("spec", "", "path of the OpenAPI 3 document (JSON or YAML)")
+	pkg := flag.String("package", "", "package name of the generated code")
+	out := flag.String("out", "", "output file, stdout when empty")
+	clientImport := flag.String("client-import", "http", "import path of the package providing HTTPRequester")
+	flag.Parse()
+
+	if *spec == "" || *pkg == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*spec, *pkg, *out, *clientImport); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(spec string, pkg string, out string, clientImport string) error {
+	data, err := os.ReadFile(spec)
+	if err != nil {
+		return fmt.Errorf("read spec failed: %s", err)
+	}
+	doc, err := parseDocument(data)
+	if err != nil {
+		return err
+	}
+	src, err := generate(doc, pkg, clientImport)
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(out, src, 0644)
+}
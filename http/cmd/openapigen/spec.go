@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// document is the subset of an OpenAPI 3 document the generator understands.
+type document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       info                  `json:"info"`
+	Paths      map[string]*pathItem  `json:"paths"`
+	Components components            `json:"components"`
+	Security   []map[string][]string `json:"security"`
+}
+
+type info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type components struct {
+	Schemas       map[string]*schema      `json:"schemas"`
+	Parameters    map[string]*parameter   `json:"parameters"`
+	RequestBodies map[string]*requestBody `json:"requestBodies"`
+	Responses     map[string]*response    `json:"responses"`
+}
+
+type pathItem struct {
+	Parameters []*parameter `json:"parameters"`
+	Get        *operation   `json:"get"`
+	Put        *operation   `json:"put"`
+	Post       *operation   `json:"post"`
+	Delete     *operation   `json:"delete"`
+	Patch      *operation   `json:"patch"`
+}
+
+type operation struct {
+	OperationID string                 `json:"operationId"`
+	Summary     string                 `json:"summary"`
+	Parameters  []*parameter           `json:"parameters"`
+	RequestBody *requestBody           `json:"requestBody"`
+	Responses   map[string]*response   `json:"responses"`
+	Security    *[]map[string][]string `json:"security"`
+}
+
+type parameter struct {
+	Ref         string  `json:"$ref"`
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description"`
+	Required    bool    `json:"required"`
+	Schema      *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Ref      string                `json:"$ref"`
+	Required bool                  `json:"required"`
+	Content  map[string]*mediaType `json:"content"`
+}
+
+type response struct {
+	Ref         string                `json:"$ref"`
+	Description string                `json:"description"`
+	Content     map[string]*mediaType `json:"content"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Description          string             `json:"description"`
+	Items                *schema            `json:"items"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+	Enum                 []interface{}      `json:"enum"`
+	AllOf                []*schema          `json:"allOf"`
+	OneOf                []*schema          `json:"oneOf"`
+	AnyOf                []*schema          `json:"anyOf"`
+}
+
+// parseDocument decodes an OpenAPI document in JSON or YAML form.
+func parseDocument(data []byte) (*document, error) {
+	trimmed := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(trimmed, "{") {
+		var raw interface{}
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("parse yaml document failed: %s", err)
+		}
+		converted, err := json.Marshal(normalizeYAML(raw))
+		if err != nil {
+			return nil, fmt.Errorf("convert yaml document failed: %s", err)
+		}
+		data = converted
+	}
+
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse json document failed: %s", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported openapi version %q", doc.OpenAPI)
+	}
+	return &doc, nil
+}
+
+// normalizeYAML turns maps with non-string keys, such as response codes, into
+// string-keyed maps so the value can be encoded as JSON.
+func normalizeYAML(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, item := range t {
+			t[k] = normalizeYAML(item)
+		}
+		return t
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, item := range t {
+			m[fmt.Sprint(k)] = normalizeYAML(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range t {
+			t[i] = normalizeYAML(item)
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+// refName returns the last segment of a local reference like #/components/schemas/Pet.
+func refName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
+
+func (d *document) resolveParameter(p *parameter) (*parameter, error) {
+	if p.Ref == "" {
+		return p, nil
+	}
+	resolved, ok := d.Components.Parameters[refName(p.Ref)]
+	if !ok {
+		return nil, fmt.Errorf("unresolved parameter reference %s", p.Ref)
+	}
+	return resolved, nil
+}
+
+func (d *document) resolveRequestBody(b *requestBody) (*requestBody, error) {
+	if b.Ref == "" {
+		return b, nil
+	}
+	resolved, ok := d.Components.RequestBodies[refName(b.Ref)]
+	if !ok {
+		return nil, fmt.Errorf("unresolved request body reference %s", b.Ref)
+	}
+	return resolved, nil
+}
+
+func (d *document) resolveResponse(r *response) (*response, error) {
+	if r.Ref == "" {
+		return r, nil
+	}
+	resolved, ok := d.Components.Responses[refName(r.Ref)]
+	if !ok {
+		return nil, fmt.Errorf("unresolved response reference %s", r.Ref)
+	}
+	return resolved, nil
+}
+
+// jsonSchema picks the JSON schema of a content map, if any.
+func jsonSchema(content map[string]*mediaType) *schema {
+	for _, mime := range sortedKeys(content) {
+		if media := content[mime]; strings.Contains(mime, "json") && media != nil && media.Schema != nil {
+			return media.Schema
+		}
+	}
+	return nil
+}
+
+// hasJSONContent reports whether a content map offers JSON, with or without a
+// schema.
+func hasJSONContent(content map[string]*mediaType) bool {
+	for mime := range content {
+		if strings.Contains(mime, "json") {
+			return true
+		}
+	}
+	return false
+}
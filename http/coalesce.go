@@ -0,0 +1,129 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// coalescer collapses concurrent identical requests into a single upstream call.
+// Requests are identified by method, URL and the values of a fixed set of headers.
+type coalescer struct {
+	headers []string
+
+	mu    sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+// coalescedCall is an upstream call shared by one or more waiters.
+type coalescedCall struct {
+	done    chan struct{}
+	body    []byte
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+func newCoalescer(headers []string) *coalescer {
+	canonical := make([]string, 0, len(headers))
+	for _, h := range headers {
+		canonical = append(canonical, http.CanonicalHeaderKey(h))
+	}
+	return &coalescer{headers: canonical, calls: make(map[string]*coalescedCall)}
+}
+
+// key builds the deduplication key of a request.
+func (g *coalescer) key(method string, url string, headers map[string]string) string {
+	var b strings.Builder
+	b.WriteString(method)
+	b.WriteByte(' ')
+	b.WriteString(url)
+	for _, name := range g.headers {
+		b.WriteByte('\n')
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(lookupHeader(headers, name))
+	}
+	return b.String()
+}
+
+// do runs fn once for all concurrent callers sharing key.
+//
+// The upstream call runs on its own context, carrying the values of the first
+// caller's but not its cancellation, so a waiter giving up does not fail the
+// others. The call is cancelled only once every waiter has left.
+func (g *coalescer) do(ctx context.Context, key string, fn func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	g.mu.Lock()
+	call, ok := g.calls[key]
+	if !ok {
+		callCtx, cancel := context.WithCancel(detachedContext{ctx})
+		call = &coalescedCall{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = call
+		go g.run(callCtx, key, call, fn)
+	}
+	call.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		var body []byte
+		if call.body != nil {
+			body = make([]byte, len(call.body))
+			copy(body, call.body)
+		}
+		return body, call.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			call.cancel()
+			if g.calls[key] == call {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+func (g *coalescer) run(ctx context.Context, key string, call *coalescedCall, fn func(ctx context.Context) ([]byte, error)) {
+	body, err := fn(ctx)
+
+	g.mu.Lock()
+	if g.calls[key] == call {
+		delete(g.calls, key)
+	}
+	g.mu.Unlock()
+
+	call.body, call.err = body, err
+	close(call.done)
+	call.cancel()
+}
+
+// detachedContext has the values of its parent but never ends.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+// lookupHeader finds a header value in a map regardless of the key's case.
+func lookupHeader(headers map[string]string, name string) string {
+	if v, ok := headers[name]; ok {
+		return v
+	}
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,165 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitForWaiters blocks until the in-flight call for key has n waiters.
+func waitForWaiters(t *testing.T, g *coalescer, key string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		g.mu.Lock()
+		call, ok := g.calls[key]
+		waiters := 0
+		if ok {
+			waiters = call.waiters
+		}
+		g.mu.Unlock()
+		if waiters == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d waiters", n)
+}
+
+func TestCoalescedGet(t *testing.T) {
+	fmt.Println("TestCoalescedGet")
+	var hits int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		<-release
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(WithCoalescing())
+	const callers = 20
+
+	var wg sync.WaitGroup
+	results := make([]string, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := client.Get(context.Background(), server.URL)
+			if err != nil {
+				t.Errorf("failed to get response: %v", err)
+				return
+			}
+			results[i] = string(resp)
+		}(i)
+	}
+
+	waitForWaiters(t, client.coalescer, client.coalescer.key(http.MethodGet, server.URL, nil), callers)
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Fatalf("expected 1 upstream call, got %d", n)
+	}
+	for i, r := range results {
+		if r != `{"ok":true}` {
+			t.Fatalf("caller %d got %q", i, r)
+		}
+	}
+}
+
+func TestCoalescedGetKeyedByHeaders(t *testing.T) {
+	fmt.Println("TestCoalescedGetKeyedByHeaders")
+	g := newCoalescer([]string{"authorization"})
+
+	a := g.key(http.MethodGet, "http://example.com", map[string]string{"Authorization": "a", "X-Trace": "1"})
+	b := g.key(http.MethodGet, "http://example.com", map[string]string{"authorization": "a", "X-Trace": "2"})
+	c := g.key(http.MethodGet, "http://example.com", map[string]string{"Authorization": "b"})
+
+	if a != b {
+		t.Fatalf("expected headers outside the key to be ignored")
+	}
+	if a == c {
+		t.Fatalf("expected different authorization to produce different keys")
+	}
+}
+
+func TestCoalescedGetFirstCallerCancelled(t *testing.T) {
+	fmt.Println("TestCoalescedGetFirstCallerCancelled")
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+			w.Write([]byte("shared"))
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(WithCoalescing())
+	key := client.coalescer.key(http.MethodGet, server.URL, nil)
+
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := client.Get(firstCtx, server.URL)
+		firstErr <- err
+	}()
+	waitForWaiters(t, client.coalescer, key, 1)
+
+	second := make(chan string, 1)
+	go func() {
+		resp, err := client.Get(context.Background(), server.URL)
+		if err != nil {
+			t.Errorf("second caller failed: %v", err)
+		}
+		second <- string(resp)
+	}()
+	waitForWaiters(t, client.coalescer, key, 2)
+
+	cancelFirst()
+	if err := <-firstErr; err != context.Canceled {
+		t.Fatalf("expected first caller to be cancelled, got %v", err)
+	}
+
+	close(release)
+	if resp := <-second; resp != "shared" {
+		t.Fatalf("expected second caller to receive the shared response, got %q", resp)
+	}
+}
+
+func TestCoalescedGetKeepsContextValues(t *testing.T) {
+	fmt.Println("TestCoalescedGetKeepsContextValues")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	var route string
+	var left time.Duration
+	client := NewHTTPClient(WithCoalescing(), WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			route = RouteFromContext(req.Context())
+			if deadline, ok := req.Context().Deadline(); ok {
+				left = time.Until(deadline)
+			}
+			return next.RoundTrip(req)
+		})
+	}))
+
+	ctx, cancel := context.WithCancel(WithRoute(context.Background(), "/albums/{id}"))
+	defer cancel()
+	ctx = WithRequestTimeout(ctx, time.Minute)
+	if _, err := client.GetWithHeader(ctx, server.URL+"/albums/1", nil); err != nil {
+		t.Fatalf("failed to get: %v", err)
+	}
+	if route != "/albums/{id}" || left <= DefaultRequestTimeout || left > time.Minute {
+		t.Fatalf("expected the caller's route and timeout, got %q, %v", route, left)
+	}
+}
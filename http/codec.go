@@ -0,0 +1,441 @@
+package http
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec encodes and decodes message bodies of one media type.
+type Codec interface {
+	// ContentType is the media type sent in the Content-Type header.
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// Media types of the built-in codecs.
+const (
+	JSONContentType        = "application/json"
+	XMLContentType         = "application/xml"
+	MessagePackContentType = "application/msgpack"
+	ProtobufContentType    = "application/x-protobuf"
+	FormContentType        = "application/x-www-form-urlencoded"
+)
+
+type JSONCodec struct{}
+
+func (JSONCodec) ContentType() string { return JSONContentType }
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+type XMLCodec struct{}
+
+func (XMLCodec) ContentType() string { return XMLContentType }
+
+func (XMLCodec) Marshal(v interface{}) ([]byte, error) { return xml.Marshal(v) }
+
+func (XMLCodec) Unmarshal(data []byte, v interface{}) error { return xml.Unmarshal(data, v) }
+
+// MessagePackCodec encodes values with msgpack struct tags, falling back to
+// field names like encoding/json does.
+type MessagePackCodec struct{}
+
+func (MessagePackCodec) ContentType() string { return MessagePackContentType }
+
+func (MessagePackCodec) Marshal(v interface{}) ([]byte, error) { return msgpack.Marshal(v) }
+
+func (MessagePackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+// ProtobufCodec encodes values that implement proto.Message in the binary wire
+// format.
+type ProtobufCodec struct{}
+
+func (ProtobufCodec) ContentType() string { return ProtobufContentType }
+
+func (ProtobufCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protobuf codec needs a proto.Message, got %T", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (ProtobufCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf codec needs a proto.Message, got %T", v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+// FormCodec encodes url.Values, string maps and flat structs as url-encoded
+// forms. Struct fields are named by their form tag, or by the field name, and
+// may be strings, booleans, numbers or string slices. A tag of "-" skips the
+// field and ",omitempty" drops zero values.
+type FormCodec struct{}
+
+func (FormCodec) ContentType() string { return FormContentType }
+
+func (FormCodec) Marshal(v interface{}) ([]byte, error) {
+	values, err := formValues(v)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(values.Encode()), nil
+}
+
+func (FormCodec) Unmarshal(data []byte, v interface{}) error {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+	return decodeForm(values, v)
+}
+
+// CodecRegistry maps media types to codecs. It is safe for concurrent use.
+type CodecRegistry struct {
+	mu     sync.RWMutex
+	byType map[string]Codec
+	// order keeps the primary media types by preference, for Accept headers.
+	order []string
+}
+
+// NewCodecRegistry creates a registry holding codecs, most preferred first.
+func NewCodecRegistry(codecs ...Codec) *CodecRegistry {
+	r := &CodecRegistry{byType: make(map[string]Codec)}
+	for _, codec := range codecs {
+		r.Register(codec)
+	}
+	return r
+}
+
+// DefaultCodecs returns a registry with the JSON, XML, MessagePack, Protobuf
+// and form codecs, preferring JSON.
+func DefaultCodecs() *CodecRegistry {
+	r := NewCodecRegistry()
+	r.Register(JSONCodec{})
+	r.Register(XMLCodec{}, "text/xml")
+	r.Register(MessagePackCodec{}, "application/x-msgpack", "application/vnd.msgpack")
+	r.Register(ProtobufCodec{}, "application/protobuf", "application/vnd.google.protobuf")
+	r.Register(FormCodec{})
+	return r
+}
+
+// Register adds codec for its content type and the given aliases, replacing
+// any codec registered for them before.
+func (r *CodecRegistry) Register(codec Codec, aliases ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	primary := normalizeMediaType(codec.ContentType())
+	if _, ok := r.byType[primary]; !ok {
+		r.order = append(r.order, primary)
+	}
+	r.byType[primary] = codec
+	for _, alias := range aliases {
+		r.byType[normalizeMediaType(alias)] = codec
+	}
+}
+
+// Lookup returns the codec for a Content-Type header value. Structured syntax
+// suffixes fall back to their base type, so application/problem+json is
+// decoded by the JSON codec.
+func (r *CodecRegistry) Lookup(contentType string) (Codec, bool) {
+	mediaType := normalizeMediaType(contentType)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if codec, ok := r.byType[mediaType]; ok {
+		return codec, true
+	}
+	if i := strings.LastIndex(mediaType, "+"); i >= 0 {
+		codec, ok := r.byType["application/"+mediaType[i+1:]]
+		return codec, ok
+	}
+	return nil, false
+}
+
+// Accept builds an Accept header listing the registered media types, with
+// preferred first and the others in registration order at decreasing quality.
+func (r *CodecRegistry) Accept(preferred string) string {
+	preferred = normalizeMediaType(preferred)
+	r.mu.RLock()
+	types := make([]string, 0, len(r.order)+1)
+	if _, ok := r.byType[preferred]; ok {
+		types = append(types, preferred)
+	}
+	for _, t := range r.order {
+		if t != preferred {
+			types = append(types, t)
+		}
+	}
+	r.mu.RUnlock()
+
+	parts := make([]string, len(types))
+	for i, t := range types {
+		q := 10 - i
+		if q < 1 {
+			q = 1
+		}
+		if q == 10 {
+			parts[i] = t
+		} else {
+			parts[i] = fmt.Sprintf("%s;q=0.%d", t, q)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Negotiate picks the codec to answer a request with the given Accept header,
+// honouring quality values and wildcards. An empty header accepts the most
+// preferred codec.
+func (r *CodecRegistry) Negotiate(accept string) (Codec, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if strings.TrimSpace(accept) == "" {
+		if len(r.order) == 0 {
+			return nil, false
+		}
+		return r.byType[r.order[0]], true
+	}
+	for _, mediaRange := range parseAccept(accept) {
+		if codec, ok := r.byType[mediaRange.mediaType]; ok {
+			return codec, true
+		}
+		for _, t := range r.order {
+			if mediaRange.matches(t) {
+				return r.byType[t], true
+			}
+		}
+	}
+	return nil, false
+}
+
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+func (a acceptRange) matches(mediaType string) bool {
+	if a.mediaType == "*/*" || a.mediaType == mediaType {
+		return true
+	}
+	if strings.HasSuffix(a.mediaType, "/*") {
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(a.mediaType, "*"))
+	}
+	return false
+}
+
+// parseAccept returns the acceptable media ranges, best first. Among equal
+// qualities, more specific ranges come first.
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, acceptRange{mediaType: mediaType, q: q})
+		}
+	}
+	specificity := func(mediaType string) int {
+		switch {
+		case mediaType == "*/*":
+			return 0
+		case strings.HasSuffix(mediaType, "/*"):
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return specificity(ranges[i].mediaType) > specificity(ranges[j].mediaType)
+	})
+	return ranges
+}
+
+func normalizeMediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mediaType
+}
+
+func formValues(v interface{}) (url.Values, error) {
+	switch v := v.(type) {
+	case url.Values:
+		return v, nil
+	case map[string][]string:
+		return url.Values(v), nil
+	case map[string]string:
+		values := make(url.Values, len(v))
+		for key, value := range v {
+			values.Set(key, value)
+		}
+		return values, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("form codec cannot encode %T", v)
+	}
+	values := make(url.Values)
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		name, omitEmpty, ok := formField(rt.Field(i))
+		if !ok {
+			continue
+		}
+		field := rv.Field(i)
+		if omitEmpty && field.IsZero() {
+			continue
+		}
+		if field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String {
+			for j := 0; j < field.Len(); j++ {
+				values.Add(name, field.Index(j).String())
+			}
+			continue
+		}
+		s, err := formatFormValue(field)
+		if err != nil {
+			return nil, fmt.Errorf("form field %s: %s", name, err)
+		}
+		values.Set(name, s)
+	}
+	return values, nil
+}
+
+func decodeForm(values url.Values, v interface{}) error {
+	switch v := v.(type) {
+	case *url.Values:
+		*v = values
+		return nil
+	case *map[string][]string:
+		*v = values
+		return nil
+	case *map[string]string:
+		m := make(map[string]string, len(values))
+		for key := range values {
+			m[key] = values.Get(key)
+		}
+		*v = m
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("form codec cannot decode into %T", v)
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		name, _, ok := formField(rt.Field(i))
+		if !ok {
+			continue
+		}
+		vs, ok := values[name]
+		if !ok || len(vs) == 0 {
+			continue
+		}
+		field := rv.Field(i)
+		if field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String {
+			field.Set(reflect.ValueOf(append([]string(nil), vs...)).Convert(field.Type()))
+			continue
+		}
+		if err := parseFormValue(field, vs[0]); err != nil {
+			return fmt.Errorf("form field %s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+func formField(f reflect.StructField) (name string, omitEmpty bool, ok bool) {
+	if f.PkgPath != "" {
+		return "", false, false
+	}
+	tag := f.Tag.Get("form")
+	if tag == "-" {
+		return "", false, false
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = f.Name
+	}
+	return name, opts == "omitempty", true
+}
+
+func formatFormValue(v reflect.Value) (string, error) {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), nil
+	}
+	return "", fmt.Errorf("unsupported type %s", v.Type())
+}
+
+func parseFormValue(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
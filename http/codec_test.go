@@ -0,0 +1,210 @@
+package http
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type codecLabel struct {
+	XMLName xml.Name `json:"-" msgpack:"-" xml:"label" form:"-"`
+	OrderID string   `json:"orderid" msgpack:"orderid" xml:"orderid" form:"orderid"`
+	Pages   int      `json:"pages" msgpack:"pages" xml:"pages" form:"pages"`
+	Tags    []string `json:"tags" msgpack:"tags" xml:"tag" form:"tag"`
+}
+
+// echoServer answers with the request body and content type.
+func echoServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("read body: %v", err)
+		}
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		w.Write(body)
+	}))
+}
+
+func TestSendEncodedRoundTrip(t *testing.T) {
+	fmt.Println("TestSendEncodedRoundTrip")
+	server := echoServer(t)
+	defer server.Close()
+
+	client := NewHTTPClient()
+	in := codecLabel{OrderID: "odr-1", Pages: 2, Tags: []string{"a", "b"}}
+	for _, contentType := range []string{JSONContentType, XMLContentType, MessagePackContentType, FormContentType, "application/vnd.msgpack"} {
+		var out codecLabel
+		err := client.SendEncoded(context.Background(), http.MethodPost, server.URL, in,
+			map[string]string{"Content-Type": contentType}, &out)
+		if err != nil {
+			t.Fatalf("%s: %v", contentType, err)
+		}
+		if out.OrderID != in.OrderID || out.Pages != in.Pages || strings.Join(out.Tags, ",") != "a,b" {
+			t.Fatalf("%s: got %+v", contentType, out)
+		}
+	}
+
+	var out wrapperspb.StringValue
+	err := client.SendEncoded(context.Background(), http.MethodPost, server.URL, wrapperspb.String("odr-1"),
+		map[string]string{"Content-Type": ProtobufContentType}, &out)
+	if err != nil {
+		t.Fatalf("protobuf: %v", err)
+	}
+	if out.GetValue() != "odr-1" {
+		t.Fatalf("protobuf: got %q", out.GetValue())
+	}
+}
+
+func TestZeroValueClientDecodes(t *testing.T) {
+	fmt.Println("TestZeroValueClientDecodes")
+	server := echoServer(t)
+	defer server.Close()
+
+	var client HTTPClient
+	in := codecLabel{OrderID: "odr-1", Pages: 2}
+	var out codecLabel
+	if err := client.SendEncoded(context.Background(), http.MethodPost, server.URL, in, nil, &out); err != nil {
+		t.Fatalf("failed to send with a zero-value client: %v", err)
+	}
+	if out.OrderID != in.OrderID || out.Pages != in.Pages {
+		t.Fatalf("unexpected label %+v", out)
+	}
+	if err := client.GetDecoded(context.Background(), server.URL, nil, &out); err != nil {
+		t.Fatalf("failed to get with a zero-value client: %v", err)
+	}
+}
+
+func TestSendEncodedDiscardsUnknownContentType(t *testing.T) {
+	fmt.Println("TestSendEncodedDiscardsUnknownContentType")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("accepted"))
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient()
+	in := codecLabel{OrderID: "odr-1"}
+	if err := client.SendEncoded(context.Background(), http.MethodPost, server.URL, in, nil, nil); err != nil {
+		t.Fatalf("expected a nil out to discard the response, got %v", err)
+	}
+	var out codecLabel
+	err := client.SendEncoded(context.Background(), http.MethodPost, server.URL, in, nil, &out)
+	if err == nil || err.Error() != `no codec for response content type "text/plain"` {
+		t.Fatalf("expected decoding text/plain to fail, got %v", err)
+	}
+}
+
+func TestGetDecodedNegotiatesAccept(t *testing.T) {
+	fmt.Println("TestGetDecodedNegotiatesAccept")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "" {
+			t.Errorf("GET carries Content-Type %q", r.Header.Get("Content-Type"))
+		}
+		codec, ok := DefaultCodecs().Negotiate(r.Header.Get("Accept"))
+		if !ok {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+		body, _ := codec.Marshal(codecLabel{OrderID: "odr-2", Pages: 1})
+		w.Header().Set("Content-Type", codec.ContentType())
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(WithContentType(MessagePackContentType))
+	var out codecLabel
+	if err := client.GetDecoded(context.Background(), server.URL, nil, &out); err != nil {
+		t.Fatalf("failed to get: %v", err)
+	}
+	if out.OrderID != "odr-2" {
+		t.Fatalf("unexpected label %+v", out)
+	}
+
+	if err := client.GetDecoded(context.Background(), server.URL, map[string]string{"Accept": "text/csv"}, &out); err == nil {
+		t.Fatalf("expected an error for an unacceptable media type")
+	}
+}
+
+func TestCodecRegistryNegotiate(t *testing.T) {
+	fmt.Println("TestCodecRegistryNegotiate")
+	registry := DefaultCodecs()
+	for accept, want := range map[string]string{
+		"":                                 JSONContentType,
+		"*/*":                              JSONContentType,
+		"application/xml":                  XMLContentType,
+		"text/xml":                         XMLContentType,
+		"application/json;q=0.5, text/xml": XMLContentType,
+		"application/*;q=0.2, application/x-msgpack;q=0.9": MessagePackContentType,
+		"text/html, application/*;q=0.1":                   JSONContentType,
+		"application/protobuf;q=0, */*;q=0.1":              JSONContentType,
+	} {
+		codec, ok := registry.Negotiate(accept)
+		if !ok || codec.ContentType() != want {
+			t.Fatalf("Negotiate(%q) = %v, want %s", accept, codec, want)
+		}
+	}
+	if _, ok := registry.Negotiate("text/html"); ok {
+		t.Fatalf("expected no codec for text/html")
+	}
+	if codec, ok := registry.Lookup("application/problem+json; charset=utf-8"); !ok || codec.ContentType() != JSONContentType {
+		t.Fatalf("expected the JSON codec for problem details")
+	}
+	if got := registry.Accept(XMLContentType); !strings.HasPrefix(got, "application/xml, application/json;q=0.9,") {
+		t.Fatalf("unexpected Accept header %q", got)
+	}
+}
+
+// lineCodec encodes string slices one per line.
+type lineCodec struct{}
+
+func (lineCodec) ContentType() string { return "text/plain" }
+
+func (lineCodec) Marshal(v interface{}) ([]byte, error) {
+	return []byte(strings.Join(v.([]string), "\n")), nil
+}
+
+func (lineCodec) Unmarshal(data []byte, v interface{}) error {
+	*v.(*[]string) = strings.Split(string(data), "\n")
+	return nil
+}
+
+func TestRegisterCustomCodec(t *testing.T) {
+	fmt.Println("TestRegisterCustomCodec")
+	server := echoServer(t)
+	defer server.Close()
+
+	client := NewHTTPClient(WithContentType("text/plain"))
+	client.Codecs().Register(lineCodec{})
+
+	var out []string
+	err := client.SendEncoded(context.Background(), http.MethodPut, server.URL, []string{"a", "b"}, nil, &out)
+	if err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	if strings.Join(out, ",") != "a,b" {
+		t.Fatalf("unexpected lines %q", out)
+	}
+}
+
+func TestHttpGetOmitsContentType(t *testing.T) {
+	fmt.Println("TestHttpGetOmitsContentType")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Content-Type")))
+	}))
+	defer server.Close()
+
+	body, err := HttpGet(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("failed to get: %v", err)
+	}
+	if len(body) != 0 {
+		t.Fatalf("GET sent Content-Type %q", body)
+	}
+}
@@ -0,0 +1,615 @@
+package http
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const maskedSecret = "***"
+
+// sensitiveHeaders are masked by MaskSecrets in addition to the headers it is given.
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+type curlConfig struct {
+	mask          bool
+	secretHeaders map[string]bool
+}
+
+// CurlOption configures how CurlCommand renders a request.
+type CurlOption func(*curlConfig)
+
+// MaskSecrets replaces credentials with *** in the rendered command: well-known
+// auth headers, the given extra headers and any password in the URL.
+func MaskSecrets(headers ...string) CurlOption {
+	return func(cfg *curlConfig) {
+		cfg.mask = true
+		for _, h := range append(headers, sensitiveHeaders...) {
+			cfg.secretHeaders[http.CanonicalHeaderKey(h)] = true
+		}
+	}
+}
+
+// CurlCommand renders req as a shell-safe curl command line. The request body is
+// read and then restored, so req can still be sent afterwards.
+func CurlCommand(req *http.Request, opts ...CurlOption) (string, error) {
+	cfg := &curlConfig{secretHeaders: make(map[string]bool)}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	body, err := peekBody(req)
+	if err != nil {
+		return "", err
+	}
+
+	args := []string{"curl"}
+	switch {
+	case req.Method == http.MethodHead && len(body) == 0:
+		// -X HEAD would leave curl waiting for a body that never comes.
+		args = append(args, "-I")
+	case req.Method != "" && req.Method != http.MethodGet || len(body) > 0 && req.Method == http.MethodGet:
+		args = append(args, "-X", req.Method)
+	}
+
+	u := *req.URL
+	if cfg.mask && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), maskedSecret)
+		}
+	}
+	args = append(args, shellQuote(u.String()))
+
+	if req.Host != "" && req.Host != req.URL.Host {
+		args = append(args, "-H", shellQuote("Host: "+req.Host))
+	}
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range req.Header[name] {
+			if cfg.mask && cfg.secretHeaders[http.CanonicalHeaderKey(name)] {
+				value = maskedSecret
+			}
+			args = append(args, "-H", shellQuote(name+": "+value))
+		}
+	}
+
+	if len(body) > 0 {
+		// Unlike --data-binary, --data-raw does not read a body starting
+		// with @ from a file.
+		args = append(args, "--data-raw", shellQuote(string(body)))
+	}
+	return strings.Join(args, " "), nil
+}
+
+// peekBody reads the request body and puts an equivalent reader back in place.
+func peekBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("read request body failed: %s", err)
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read request body failed: %s", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
+
+// shellQuote quotes s for POSIX shells. Values that are not printable UTF-8 use
+// the $'...' form understood by bash and zsh.
+func shellQuote(s string) string {
+	if utf8.ValidString(s) && !strings.ContainsAny(s, "\x00\r") {
+		return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	}
+	var b strings.Builder
+	b.WriteString("$'")
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\'' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// curl options that take an argument but do not affect the request itself.
+var ignoredCurlArgOptions = map[string]bool{
+	"-o": true, "--output": true, "-m": true, "--max-time": true, "--connect-timeout": true,
+	"--retry": true, "-w": true, "--write-out": true, "--cacert": true, "--cert": true,
+	"--key": true, "-x": true, "--proxy": true, "--resolve": true, "-c": true, "--cookie-jar": true,
+}
+
+// curl options without an argument that do not affect the request itself.
+var ignoredCurlFlags = map[string]bool{
+	"-s": true, "--silent": true, "-S": true, "--show-error": true, "-v": true, "--verbose": true,
+	"-i": true, "--include": true, "-L": true, "--location": true, "-k": true, "--insecure": true,
+	"--compressed": true, "-f": true, "--fail": true, "-g": true, "--globoff": true, "-#": true,
+	"--progress-bar": true, "-N": true, "--no-buffer": true,
+}
+
+// isCurlFlag reports whether name is a curl option without an argument.
+func isCurlFlag(name string) bool {
+	return ignoredCurlFlags[name] || name == "-G" || name == "--get" || name == "-I" || name == "--head"
+}
+
+// curlRequest collects parsed curl options before building the request.
+type curlRequest struct {
+	method  string
+	url     string
+	header  http.Header
+	data    []string
+	get     bool
+	form    []string
+	user    string
+	hasData bool
+}
+
+// ParseCurl turns a curl command line into a request that HTTPClient.Do can send.
+// It understands -X, -H, -d and its --data-* variants, --data-urlencode, --json,
+// -u, -F, -G, -I, -A, -e and -b; options that only affect curl's output are ignored.
+func ParseCurl(command string) (*http.Request, error) {
+	words, err := shellSplit(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(words) == 0 || words[0] != "curl" {
+		return nil, fmt.Errorf("not a curl command")
+	}
+
+	cr := &curlRequest{header: make(http.Header)}
+	for i := 1; i < len(words); i++ {
+		word := words[i]
+		if !strings.HasPrefix(word, "-") || word == "-" {
+			if cr.url != "" {
+				return nil, fmt.Errorf("multiple urls are not supported")
+			}
+			cr.url = word
+			continue
+		}
+
+		if !strings.HasPrefix(word, "--") && len(word) > 2 && isCurlFlag(word[:2]) {
+			// Short flags combine, as in -sS or -sd data: take the first
+			// and read the rest as options again.
+			words[i] = "-" + word[2:]
+			word = word[:2]
+			i--
+		}
+
+		name, value, hasValue := splitCurlOption(word)
+		arg := func() (string, error) {
+			if hasValue {
+				return value, nil
+			}
+			if i+1 >= len(words) {
+				return "", fmt.Errorf("curl option %s requires an argument", name)
+			}
+			i++
+			return words[i], nil
+		}
+
+		switch {
+		case ignoredCurlFlags[name]:
+			continue
+		case ignoredCurlArgOptions[name]:
+			if _, err := arg(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		switch name {
+		case "-G", "--get":
+			cr.get = true
+			continue
+		case "-I", "--head":
+			cr.method = http.MethodHead
+			continue
+		}
+
+		v, err := arg()
+		if err != nil {
+			return nil, err
+		}
+		if err := cr.apply(name, v); err != nil {
+			return nil, err
+		}
+	}
+	return cr.build()
+}
+
+// splitCurlOption separates an option from an argument attached to it, as in
+// -XPOST or --request=POST.
+func splitCurlOption(word string) (string, string, bool) {
+	if strings.HasPrefix(word, "--") {
+		if i := strings.Index(word, "="); i > 0 {
+			return word[:i], word[i+1:], true
+		}
+		return word, "", false
+	}
+	if len(word) > 2 {
+		return word[:2], word[2:], true
+	}
+	return word, "", false
+}
+
+func (cr *curlRequest) apply(name string, value string) error {
+	switch name {
+	case "-X", "--request":
+		cr.method = value
+	case "--url":
+		cr.url = value
+	case "-H", "--header":
+		k, v, ok := strings.Cut(value, ":")
+		if !ok {
+			return fmt.Errorf("invalid header %q", value)
+		}
+		cr.header.Add(strings.TrimSpace(k), strings.TrimSpace(v))
+	case "-A", "--user-agent":
+		cr.header.Set("User-Agent", value)
+	case "-e", "--referer":
+		cr.header.Set("Referer", value)
+	case "-b", "--cookie":
+		cr.header.Add("Cookie", value)
+	case "-u", "--user":
+		cr.user = value
+	case "-d", "--data", "--data-ascii":
+		data, err := readCurlData(value, true)
+		if err != nil {
+			return err
+		}
+		cr.addData(data)
+	case "--data-binary":
+		data, err := readCurlData(value, false)
+		if err != nil {
+			return err
+		}
+		cr.addData(data)
+	case "--data-raw":
+		cr.addData(value)
+	case "--data-urlencode":
+		data, err := urlencodeCurlData(value)
+		if err != nil {
+			return err
+		}
+		cr.addData(data)
+	case "--json":
+		data, err := readCurlData(value, false)
+		if err != nil {
+			return err
+		}
+		cr.addData(data)
+		cr.header.Set("Content-Type", "application/json")
+		cr.header.Set("Accept", "application/json")
+	case "-F", "--form":
+		cr.form = append(cr.form, value)
+	default:
+		return fmt.Errorf("unsupported curl option %s", name)
+	}
+	return nil
+}
+
+func (cr *curlRequest) addData(data string) {
+	cr.data = append(cr.data, data)
+	cr.hasData = true
+}
+
+func (cr *curlRequest) build() (*http.Request, error) {
+	if cr.url == "" {
+		return nil, fmt.Errorf("curl command has no url")
+	}
+	rawURL := cr.url
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url %q: %s", cr.url, err)
+	}
+
+	var body []byte
+	method := cr.method
+	switch {
+	case len(cr.form) > 0:
+		if cr.hasData {
+			return nil, fmt.Errorf("-F cannot be combined with -d")
+		}
+		var contentType string
+		body, contentType, err = buildCurlForm(cr.form)
+		if err != nil {
+			return nil, err
+		}
+		cr.header.Set("Content-Type", contentType)
+		if method == "" {
+			method = http.MethodPost
+		}
+	case cr.hasData && cr.get:
+		if u.RawQuery != "" {
+			u.RawQuery += "&"
+		}
+		u.RawQuery += strings.Join(cr.data, "&")
+	case cr.hasData:
+		body = []byte(strings.Join(cr.data, "&"))
+		if cr.header.Get("Content-Type") == "" {
+			cr.header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		if method == "" {
+			method = http.MethodPost
+		}
+	}
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, u.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range cr.header {
+		if strings.EqualFold(k, "Host") {
+			req.Host = vs[0]
+			continue
+		}
+		req.Header[k] = vs
+	}
+	if cr.user != "" {
+		user, pass, _ := strings.Cut(cr.user, ":")
+		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user+":"+pass)))
+	}
+	return req, nil
+}
+
+// readCurlData resolves a -d style argument, reading @file references.
+// Like curl, -d strips newlines from file contents while --data-binary does not.
+func readCurlData(value string, stripNewlines bool) (string, error) {
+	if !strings.HasPrefix(value, "@") {
+		return value, nil
+	}
+	data, err := os.ReadFile(value[1:])
+	if err != nil {
+		return "", fmt.Errorf("read curl data file failed: %s", err)
+	}
+	if stripNewlines {
+		data = bytes.ReplaceAll(bytes.ReplaceAll(data, []byte("\r"), nil), []byte("\n"), nil)
+	}
+	return string(data), nil
+}
+
+// urlencodeCurlData implements the content, =content, name=content, @file and
+// name@file forms of --data-urlencode.
+func urlencodeCurlData(value string) (string, error) {
+	eq := strings.Index(value, "=")
+	at := strings.Index(value, "@")
+	switch {
+	case eq == 0:
+		return url.QueryEscape(value[1:]), nil
+	case eq > 0 && (at < 0 || eq < at):
+		return value[:eq] + "=" + url.QueryEscape(value[eq+1:]), nil
+	case at >= 0:
+		data, err := os.ReadFile(value[at+1:])
+		if err != nil {
+			return "", fmt.Errorf("read curl data file failed: %s", err)
+		}
+		if at == 0 {
+			return url.QueryEscape(string(data)), nil
+		}
+		return value[:at] + "=" + url.QueryEscape(string(data)), nil
+	}
+	return url.QueryEscape(value), nil
+}
+
+// buildCurlForm encodes -F fields as multipart/form-data. Values starting with @
+// attach a file, values starting with < inline a file's contents, and a trailing
+// ;type=... sets the part's content type.
+func buildCurlForm(fields []string) ([]byte, string, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, field := range fields {
+		name, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, "", fmt.Errorf("invalid form field %q", field)
+		}
+		contentType := ""
+		if i := strings.Index(value, ";type="); i >= 0 {
+			contentType = value[i+len(";type="):]
+			value = value[:i]
+		}
+
+		switch {
+		case strings.HasPrefix(value, "@"):
+			path := value[1:]
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, "", fmt.Errorf("read form file failed: %s", err)
+			}
+			if contentType == "" {
+				contentType = "application/octet-stream"
+			}
+			h := make(textproto.MIMEHeader)
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename=%q`, name, filepath.Base(path)))
+			h.Set("Content-Type", contentType)
+			part, err := w.CreatePart(h)
+			if err != nil {
+				return nil, "", err
+			}
+			part.Write(data)
+		default:
+			if strings.HasPrefix(value, "<") {
+				data, err := os.ReadFile(value[1:])
+				if err != nil {
+					return nil, "", fmt.Errorf("read form file failed: %s", err)
+				}
+				value = string(data)
+			}
+			h := make(textproto.MIMEHeader)
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q`, name))
+			if contentType != "" {
+				h.Set("Content-Type", contentType)
+			}
+			part, err := w.CreatePart(h)
+			if err != nil {
+				return nil, "", err
+			}
+			part.Write([]byte(value))
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
+
+// shellSplit splits a command line into words the way a POSIX shell would,
+// honouring quotes, backslash escapes and line continuations.
+func shellSplit(s string) ([]string, error) {
+	var words []string
+	var cur strings.Builder
+	inWord := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch {
+			case s[i] == '\n':
+			case s[i] == '\r' && i+1 < len(s) && s[i+1] == '\n':
+				i++
+			default:
+				cur.WriteByte(s[i])
+				inWord = true
+			}
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote")
+			}
+			cur.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '$' && i+1 < len(s) && s[i+1] == '\'':
+			n, err := readANSIQuoted(s[i+2:], &cur)
+			if err != nil {
+				return nil, err
+			}
+			i += n + 2
+			inWord = true
+		case c == '"':
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\"\\$`\n", s[i+1]) >= 0 {
+					i++
+					if s[i] == '\n' {
+						continue
+					}
+				}
+				cur.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, fmt.Errorf("unterminated double quote")
+			}
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, cur.String())
+	}
+	return words, nil
+}
+
+// readANSIQuoted decodes the body of a $'...' string and returns how many bytes
+// were consumed, including the closing quote.
+func readANSIQuoted(s string, out *strings.Builder) (int, error) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\'' {
+			return i + 1, nil
+		}
+		if c != '\\' || i+1 >= len(s) {
+			out.WriteByte(c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			out.WriteByte('\n')
+		case 't':
+			out.WriteByte('\t')
+		case 'r':
+			out.WriteByte('\r')
+		case 'x':
+			var b byte
+			n := 0
+			for ; n < 2 && i+1 < len(s) && isHex(s[i+1]); n++ {
+				i++
+				b = b<<4 | unhex(s[i])
+			}
+			if n == 0 {
+				return 0, fmt.Errorf("invalid \\x escape")
+			}
+			out.WriteByte(b)
+		default:
+			out.WriteByte(s[i])
+		}
+	}
+	return 0, fmt.Errorf("unterminated $' quote")
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	}
+	return c - 'A' + 10
+}
@@ -0,0 +1,318 @@
+// Package digest answers RFC 7616 Digest authentication challenges:
+//
+//	auth := digest.New("user", "password")
+//	client := httpclient.NewHTTPClient(httpclient.WithMiddleware(auth.Middleware))
+//
+// A request answered with a 401 Digest challenge is sent again with the
+// computed credentials. The challenge is then remembered per host, so later
+// requests authenticate up front until the server marks the nonce stale.
+package digest
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	httpclient "http"
+)
+
+// algorithms are the supported algorithms, most preferred first.
+var algorithms = []struct {
+	name string
+	hash func() hash.Hash
+}{
+	{"SHA-512-256", sha512.New512_256},
+	{"SHA-256", sha256.New},
+	{"MD5", md5.New},
+}
+
+func lookupAlgorithm(name string) (func() hash.Hash, bool) {
+	name = strings.TrimSuffix(strings.ToUpper(name), "-SESS")
+	for _, a := range algorithms {
+		if a.name == name {
+			return a.hash, true
+		}
+	}
+	return nil, false
+}
+
+// Challenge is a parsed WWW-Authenticate Digest challenge.
+type Challenge struct {
+	Realm     string
+	Nonce     string
+	Opaque    string
+	Algorithm string
+	// Qop lists the offered qualities of protection, "auth" and
+	// "auth-int". Empty for RFC 2069 servers.
+	Qop      []string
+	Stale    bool
+	UserHash bool
+}
+
+// ParseChallenge parses the Digest challenge of a WWW-Authenticate header.
+func ParseChallenge(header string) (*Challenge, error) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	if !strings.EqualFold(scheme, "Digest") {
+		return nil, fmt.Errorf("not a digest challenge: %q", header)
+	}
+	params, err := parseParams(rest)
+	if err != nil {
+		return nil, err
+	}
+	c := &Challenge{
+		Realm:     params["realm"],
+		Nonce:     params["nonce"],
+		Opaque:    params["opaque"],
+		Algorithm: params["algorithm"],
+		Stale:     strings.EqualFold(params["stale"], "true"),
+		UserHash:  strings.EqualFold(params["userhash"], "true"),
+	}
+	if c.Algorithm == "" {
+		c.Algorithm = "MD5"
+	}
+	if _, ok := lookupAlgorithm(c.Algorithm); !ok {
+		return nil, fmt.Errorf("unsupported digest algorithm %q", c.Algorithm)
+	}
+	if c.Nonce == "" {
+		return nil, fmt.Errorf("digest challenge without nonce")
+	}
+	for _, qop := range strings.Split(params["qop"], ",") {
+		if qop = strings.TrimSpace(qop); qop != "" {
+			c.Qop = append(c.Qop, qop)
+		}
+	}
+	return c, nil
+}
+
+// parseParams parses comma-separated auth-params whose values are tokens or
+// quoted strings.
+func parseParams(s string) (map[string]string, error) {
+	params := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return params, nil
+		}
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("malformed digest parameter %q", s)
+		}
+		name := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " \t")
+		var value strings.Builder
+		if strings.HasPrefix(s, `"`) {
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				value.WriteByte(s[i])
+			}
+			if i == len(s) {
+				return nil, fmt.Errorf("unterminated digest parameter %q", name)
+			}
+			s = s[i+1:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value.WriteString(strings.TrimSpace(s[:end]))
+			s = s[end:]
+		}
+		params[name] = value.String()
+	}
+}
+
+// Credentials computes the Authorization header answering c for a request.
+// body is hashed for the auth-int quality of protection, nc is the nonce
+// count and cnonce the client nonce.
+func (c *Challenge) Credentials(username, password, method, uri string, body []byte, nc uint32, cnonce string) string {
+	newHash, _ := lookupAlgorithm(c.Algorithm)
+	h := func(s string) string {
+		sum := newHash()
+		io.WriteString(sum, s)
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+
+	qop := ""
+	for _, offered := range c.Qop {
+		if offered == "auth" {
+			qop = offered
+			break
+		}
+		if offered == "auth-int" {
+			qop = offered
+		}
+	}
+
+	a1 := h(username + ":" + c.Realm + ":" + password)
+	if strings.HasSuffix(strings.ToUpper(c.Algorithm), "-SESS") {
+		a1 = h(a1 + ":" + c.Nonce + ":" + cnonce)
+	}
+	a2 := method + ":" + uri
+	if qop == "auth-int" {
+		sum := newHash()
+		sum.Write(body)
+		a2 += ":" + hex.EncodeToString(sum.Sum(nil))
+	}
+	count := fmt.Sprintf("%08x", nc)
+	var response string
+	if qop == "" {
+		response = h(a1 + ":" + c.Nonce + ":" + h(a2))
+	} else {
+		response = h(strings.Join([]string{a1, c.Nonce, count, cnonce, qop, h(a2)}, ":"))
+	}
+
+	user := username
+	if c.UserHash {
+		user = h(username + ":" + c.Realm)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, `Digest username=%s, realm=%s, uri=%s, algorithm=%s, nonce=%s`,
+		quote(user), quote(c.Realm), quote(uri), c.Algorithm, quote(c.Nonce))
+	if qop != "" {
+		fmt.Fprintf(&b, `, nc=%s, cnonce=%s, qop=%s`, count, quote(cnonce), qop)
+	}
+	fmt.Fprintf(&b, `, response=%s`, quote(response))
+	if c.Opaque != "" {
+		fmt.Fprintf(&b, `, opaque=%s`, quote(c.Opaque))
+	}
+	if c.UserHash {
+		b.WriteString(`, userhash=true`)
+	}
+	return b.String()
+}
+
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// session is the challenge last received from a host and its nonce count.
+type session struct {
+	challenge *Challenge
+	nc        uint32
+}
+
+// Authenticator answers Digest challenges with one username and password.
+type Authenticator struct {
+	username string
+	password string
+	cnonce   func() string
+
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+func New(username string, password string) *Authenticator {
+	return &Authenticator{username: username, password: password, cnonce: newCnonce, sessions: make(map[string]*session)}
+}
+
+func newCnonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Middleware authenticates the requests sent through next. Its signature
+// matches the http package's Middleware.
+func (a *Authenticator) Middleware(next http.RoundTripper) http.RoundTripper {
+	return httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return a.roundTrip(req, next)
+	})
+}
+
+func (a *Authenticator) roundTrip(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	// The body is sent twice when the first attempt is challenged, and
+	// hashed for auth-int.
+	body, err := readBody(req)
+	if err != nil {
+		return nil, fmt.Errorf("read request body failed: %s", err)
+	}
+	host := req.URL.Host
+
+	first, sent := a.authorize(req, host, body, nil)
+	resp, err := next.RoundTrip(first)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	challenge := bestChallenge(resp.Header.Values("WWW-Authenticate"))
+	// Credentials rejected for the same nonce, unless it went stale, are
+	// wrong, and so would be the retry.
+	if challenge == nil || sent != nil && sent.Nonce == challenge.Nonce && !challenge.Stale {
+		return resp, nil
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	retry, _ := a.authorize(req, host, body, challenge)
+	return next.RoundTrip(retry)
+}
+
+// bestChallenge picks the Digest challenge with the strongest algorithm among
+// those a server offers.
+func bestChallenge(headers []string) *Challenge {
+	var best *Challenge
+	bestRank := len(algorithms)
+	for _, header := range headers {
+		c, err := ParseChallenge(header)
+		if err != nil {
+			continue
+		}
+		name := strings.TrimSuffix(strings.ToUpper(c.Algorithm), "-SESS")
+		for rank, a := range algorithms {
+			if a.name == name && rank < bestRank {
+				best, bestRank = c, rank
+			}
+		}
+	}
+	return best
+}
+
+// authorize returns a copy of req carrying credentials for the remembered
+// challenge of host, or for challenge when one was just received, and the
+// challenge answered.
+func (a *Authenticator) authorize(req *http.Request, host string, body []byte, challenge *Challenge) (*http.Request, *Challenge) {
+	a.mu.Lock()
+	s := a.sessions[host]
+	if challenge != nil {
+		s = &session{challenge: challenge}
+		a.sessions[host] = s
+	}
+	var nc uint32
+	if s != nil {
+		s.nc++
+		nc = s.nc
+	}
+	a.mu.Unlock()
+
+	out := req.Clone(req.Context())
+	if body != nil {
+		out.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	if s == nil {
+		return out, nil
+	}
+	out.Header.Set("Authorization",
+		s.challenge.Credentials(a.username, a.password, req.Method, req.URL.RequestURI(), body, nc, a.cnonce()))
+	return out, s.challenge
+}
+
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	defer req.Body.Close()
+	return io.ReadAll(req.Body)
+}
@@ -0,0 +1,159 @@
+package digest
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	httpclient "http"
+)
+
+func param(header, name string) string {
+	_, rest, _ := strings.Cut(header, " ")
+	params, _ := parseParams(rest)
+	return params[name]
+}
+
+// Examples from RFC 7616 section 3.9.1 and RFC 2617 section 3.5.
+func TestCredentialsVectors(t *testing.T) {
+	fmt.Println("TestCredentialsVectors")
+	for _, c := range []struct {
+		challenge string
+		username  string
+		password  string
+		cnonce    string
+		response  string
+	}{
+		{
+			challenge: `Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=MD5, ` +
+				`nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`,
+			username: "Mufasa",
+			password: "Circle of Life",
+			cnonce:   "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ",
+			response: "8ca523f5e9506fed4657c9700eebdbec",
+		},
+		{
+			challenge: `Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=SHA-256, ` +
+				`nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`,
+			username: "Mufasa",
+			password: "Circle of Life",
+			cnonce:   "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ",
+			response: "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1",
+		},
+		{
+			challenge: `Digest realm="testrealm@host.com", qop="auth,auth-int", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", ` +
+				`opaque="5ccc069c403ebaf9f0171e9517f40e41"`,
+			username: "Mufasa",
+			password: "Circle Of Life",
+			cnonce:   "0a4f113b",
+			response: "6629fae49393a05397450978507c4ef1",
+		},
+	} {
+		challenge, err := ParseChallenge(c.challenge)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", c.challenge, err)
+		}
+		header := challenge.Credentials(c.username, c.password, http.MethodGet, "/dir/index.html", nil, 1, c.cnonce)
+		if got := param(header, "response"); got != c.response {
+			t.Fatalf("expected response %s, got %s in %s", c.response, got, header)
+		}
+		if param(header, "nc") != "00000001" || param(header, "qop") != "auth" || param(header, "opaque") != challenge.Opaque {
+			t.Fatalf("unexpected header %s", header)
+		}
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	fmt.Println("TestParseChallenge")
+	c, err := ParseChallenge(`Digest realm="a \"quoted\" realm", nonce=abc, algorithm=SHA-256-sess, stale=TRUE, userhash=true`)
+	if err != nil || c.Realm != `a "quoted" realm` || c.Nonce != "abc" || !c.Stale || !c.UserHash || c.Qop != nil {
+		t.Fatalf("unexpected challenge %+v, %v", c, err)
+	}
+	for _, header := range []string{`Basic realm="x"`, `Digest realm="x"`, `Digest nonce="n", algorithm=SHA-1`, `Digest nonce="n`} {
+		if _, err := ParseChallenge(header); err == nil {
+			t.Fatalf("expected %s to be rejected", header)
+		}
+	}
+}
+
+// digestServer accepts MD5 Digest credentials for user/secret and marks its
+// nonce stale every staleAfter requests.
+type digestServer struct {
+	mu         sync.Mutex
+	nonce      int
+	served     int
+	staleAfter int
+	requests   int
+}
+
+func (s *digestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	nonce := fmt.Sprintf("nonce-%d", s.nonce)
+	challenge := func(stale bool) {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm="api", qop="auth", nonce="%s", stale=%v`, nonce, stale))
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		challenge(false)
+		return
+	}
+	h := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	ha1 := h(param(auth, "username") + ":api:secret")
+	ha2 := h(r.Method + ":" + r.URL.RequestURI())
+	want := h(strings.Join([]string{ha1, param(auth, "nonce"), param(auth, "nc"), param(auth, "cnonce"), "auth", ha2}, ":"))
+	if param(auth, "username") != "user" || param(auth, "uri") != r.URL.RequestURI() || param(auth, "response") != want {
+		challenge(false)
+		return
+	}
+	if param(auth, "nonce") != nonce {
+		challenge(true)
+		return
+	}
+	s.served++
+	if s.served%s.staleAfter == 0 {
+		s.nonce++
+	}
+	body, _ := io.ReadAll(r.Body)
+	w.Write(append([]byte("ok:"), body...))
+}
+
+func TestMiddlewareAnswersChallenges(t *testing.T) {
+	fmt.Println("TestMiddlewareAnswersChallenges")
+	ds := &digestServer{staleAfter: 3}
+	server := httptest.NewServer(ds)
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(httpclient.WithMiddleware(New("user", "secret").Middleware))
+	ctx := context.Background()
+	for i := 0; i < 4; i++ {
+		resp, err := client.PostWithHeader(ctx, fmt.Sprintf("%s/labels?n=%d", server.URL, i), []byte("body"), nil)
+		if err != nil || string(resp) != "ok:body" {
+			t.Fatalf("request %d: unexpected response %q, %v", i, resp, err)
+		}
+	}
+	// One challenge for the first request, three authenticated up front,
+	// and one stale nonce on the fourth.
+	if ds.requests != 6 {
+		t.Fatalf("expected 6 requests, got %d", ds.requests)
+	}
+
+	wrong := httpclient.NewHTTPClient(httpclient.WithMiddleware(New("user", "wrong").Middleware))
+	_, err := wrong.Get(ctx, server.URL)
+	if se, ok := err.(*httpclient.StatusError); !ok || se.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected a 401, got %v", err)
+	}
+}
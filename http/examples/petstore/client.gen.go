@@ -0,0 +1,321 @@
+// Code generated by openapigen. DO NOT EDIT.
+
+// Package petstore is a typed client for the Petstore API.
+package petstore
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	httpclient "http"
+)
+
+// AuthFunc adds credentials to the headers of an outgoing request.
+type AuthFunc func(ctx context.Context, headers map[string]string) error
+
+// APIKeyAuth sends key in the given header.
+func APIKeyAuth(header string, key string) AuthFunc {
+	return func(ctx context.Context, headers map[string]string) error {
+		headers[header] = key
+		return nil
+	}
+}
+
+// BearerAuth sends token as a bearer token.
+func BearerAuth(token string) AuthFunc {
+	return func(ctx context.Context, headers map[string]string) error {
+		headers["Authorization"] = "Bearer " + token
+		return nil
+	}
+}
+
+// BasicAuth sends user and password with HTTP basic authentication.
+func BasicAuth(user string, password string) AuthFunc {
+	return func(ctx context.Context, headers map[string]string) error {
+		headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+		return nil
+	}
+}
+
+// Client calls the API through an HTTPRequester.
+type Client struct {
+	BaseURL   string
+	Requester httpclient.HTTPRequester
+	// Auth, when set, adds credentials to every operation that requires them.
+	Auth AuthFunc
+}
+
+func NewClient(baseURL string, requester httpclient.HTTPRequester) *Client {
+	return &Client{BaseURL: baseURL, Requester: requester}
+}
+
+func (c *Client) url(path string, query url.Values) string {
+	u := strings.TrimRight(c.BaseURL, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+func (c *Client) headers(ctx context.Context, secured bool) (map[string]string, error) {
+	headers := map[string]string{"Accept": "application/json"}
+	if secured && c.Auth != nil {
+		if err := c.Auth(ctx, headers); err != nil {
+			return nil, fmt.Errorf("authorize request failed: %s", err)
+		}
+	}
+	return headers, nil
+}
+
+func decodeResponse(operation string, body []byte, out interface{}) error {
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decode %s response failed: %s", operation, err)
+	}
+	return nil
+}
+
+func asStatusError(err error) (*httpclient.StatusError, bool) {
+	var statusErr *httpclient.StatusError
+	ok := errors.As(err, &statusErr)
+	return statusErr, ok
+}
+
+// PetStatus lifecycle state of a pet.
+type PetStatus string
+
+const (
+	PetStatusAvailable PetStatus = "available"
+	PetStatusPending   PetStatus = "pending"
+	PetStatusSold      PetStatus = "sold"
+)
+
+type Error struct {
+	Code    int32  `json:"code"`
+	Message string `json:"message"`
+}
+
+type NewPet struct {
+	Name string `json:"name"`
+	Tag  string `json:"tag,omitempty"`
+}
+
+type Pet struct {
+	ID     int64     `json:"id"`
+	Name   string    `json:"name"`
+	Status PetStatus `json:"status,omitempty"`
+	Tag    string    `json:"tag,omitempty"`
+}
+
+// ListPetsParams holds the parameters of ListPets.
+type ListPetsParams struct {
+	Tags  []string
+	Limit *int32
+}
+
+// ListPets lists pets, optionally filtered by tags.
+//
+// GET /pets
+func (c *Client) ListPets(ctx context.Context, params ListPetsParams) ([]Pet, error) {
+	path := "/pets"
+	query := url.Values{}
+	for _, v := range params.Tags {
+		query.Add("tags", fmt.Sprint(v))
+	}
+	if params.Limit != nil {
+		query.Set("limit", fmt.Sprint(*params.Limit))
+	}
+	headers, err := c.headers(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Requester.GetWithHeader(ctx, c.url(path, query), headers)
+	if err != nil {
+		return nil, listPetsError(err)
+	}
+	var out []Pet
+	if err := decodeResponse("ListPets", resp, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListPetsDefaultError is returned by ListPets when the server answers an undocumented error status: unexpected error.
+type ListPetsDefaultError struct {
+	// Body holds the decoded payload when it matches the documented schema.
+	Body Error
+	Err  *httpclient.StatusError
+}
+
+func (e *ListPetsDefaultError) Error() string {
+	return fmt.Sprintf("ListPets: %s", e.Err)
+}
+
+func (e *ListPetsDefaultError) Unwrap() error {
+	return e.Err
+}
+
+func listPetsError(err error) error {
+	statusErr, ok := asStatusError(err)
+	if !ok {
+		return err
+	}
+	e := &ListPetsDefaultError{Err: statusErr}
+	_ = json.Unmarshal(statusErr.Body, &e.Body)
+	return e
+}
+
+// CreatePet creates a pet.
+//
+// POST /pets
+func (c *Client) CreatePet(ctx context.Context, body NewPet) (*Pet, error) {
+	path := "/pets"
+	query := url.Values{}
+	headers, err := c.headers(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("encode CreatePet request failed: %s", err)
+	}
+	headers["Content-Type"] = "application/json"
+	resp, err := c.Requester.PostWithHeader(ctx, c.url(path, query), payload, headers)
+	if err != nil {
+		return nil, createPetError(err)
+	}
+	var out Pet
+	if err := decodeResponse("CreatePet", resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreatePet409Error is returned by CreatePet when the server answers 409: pet already exists.
+type CreatePet409Error struct {
+	// Body holds the decoded payload when it matches the documented schema.
+	Body Error
+	Err  *httpclient.StatusError
+}
+
+func (e *CreatePet409Error) Error() string {
+	return fmt.Sprintf("CreatePet: %s", e.Err)
+}
+
+func (e *CreatePet409Error) Unwrap() error {
+	return e.Err
+}
+
+func createPetError(err error) error {
+	statusErr, ok := asStatusError(err)
+	if !ok {
+		return err
+	}
+	if statusErr.StatusCode == 409 {
+		e := &CreatePet409Error{Err: statusErr}
+		_ = json.Unmarshal(statusErr.Body, &e.Body)
+		return e
+	}
+	return err
+}
+
+// GetPetParams holds the parameters of GetPet.
+type GetPetParams struct {
+	ID         int64
+	XRequestID *string
+}
+
+// GetPet returns a pet by id.
+//
+// GET /pets/{id}
+func (c *Client) GetPet(ctx context.Context, params GetPetParams) (*Pet, error) {
+	path := "/pets/" + url.PathEscape(fmt.Sprint(params.ID))
+	query := url.Values{}
+	headers, err := c.headers(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	if params.XRequestID != nil {
+		headers["X-Request-Id"] = fmt.Sprint(*params.XRequestID)
+	}
+	resp, err := c.Requester.GetWithHeader(ctx, c.url(path, query), headers)
+	if err != nil {
+		return nil, getPetError(err)
+	}
+	var out Pet
+	if err := decodeResponse("GetPet", resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetPet404Error is returned by GetPet when the server answers 404: pet not found.
+type GetPet404Error struct {
+	// Body holds the decoded payload when it matches the documented schema.
+	Body Error
+	Err  *httpclient.StatusError
+}
+
+func (e *GetPet404Error) Error() string {
+	return fmt.Sprintf("GetPet: %s", e.Err)
+}
+
+func (e *GetPet404Error) Unwrap() error {
+	return e.Err
+}
+
+// GetPet5XXError is returned by GetPet when the server answers 5XX: server error.
+type GetPet5XXError struct {
+	Err *httpclient.StatusError
+}
+
+func (e *GetPet5XXError) Error() string {
+	return fmt.Sprintf("GetPet: %s", e.Err)
+}
+
+func (e *GetPet5XXError) Unwrap() error {
+	return e.Err
+}
+
+func getPetError(err error) error {
+	statusErr, ok := asStatusError(err)
+	if !ok {
+		return err
+	}
+	if statusErr.StatusCode == 404 {
+		e := &GetPet404Error{Err: statusErr}
+		_ = json.Unmarshal(statusErr.Body, &e.Body)
+		return e
+	}
+	if statusErr.StatusCode/100 == 5 {
+		e := &GetPet5XXError{Err: statusErr}
+		return e
+	}
+	return err
+}
+
+// DeletePetParams holds the parameters of DeletePet.
+type DeletePetParams struct {
+	ID int64
+}
+
+// DeletePet deletes a pet.
+//
+// DELETE /pets/{id}
+func (c *Client) DeletePet(ctx context.Context, params DeletePetParams) error {
+	path := "/pets/" + url.PathEscape(fmt.Sprint(params.ID))
+	query := url.Values{}
+	headers, err := c.headers(ctx, true)
+	if err != nil {
+		return err
+	}
+	_, err = c.Requester.DeleteWithHeader(ctx, c.url(path, query), headers)
+	if err != nil {
+		return err
+	}
+	return nil
+}
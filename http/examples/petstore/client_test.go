@@ -0,0 +1,142 @@
+package petstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	httpclient "http"
+)
+
+func newPetServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/pets":
+			if r.Header.Get("X-API-Key") != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"code": 401, "message": "missing key"}`))
+				return
+			}
+			if got := r.URL.Query()["tags"]; len(got) != 2 || r.URL.Query().Get("limit") != "5" {
+				t.Errorf("unexpected query %s", r.URL.RawQuery)
+			}
+			w.Write([]byte(`[{"id": 1, "name": "rex", "status": "available"}]`))
+		case r.Method == http.MethodPost && r.URL.Path == "/pets":
+			var pet NewPet
+			if err := json.NewDecoder(r.Body).Decode(&pet); err != nil || pet.Name != "tom" {
+				t.Errorf("unexpected body %+v, %v", pet, err)
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": 2, "name": "tom"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/pets/404":
+			if r.Header.Get("X-API-Key") != "" {
+				t.Errorf("expected unsecured operation to skip auth")
+			}
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code": 404, "message": "no such pet"}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+}
+
+func TestListPets(t *testing.T) {
+	fmt.Println("TestListPets")
+	server := newPetServer(t)
+	defer server.Close()
+
+	client := NewClient(server.URL, httpclient.NewHTTPClient())
+	client.Auth = APIKeyAuth("X-API-Key", "secret")
+
+	limit := int32(5)
+	pets, err := client.ListPets(context.Background(), ListPetsParams{Tags: []string{"a", "b"}, Limit: &limit})
+	if err != nil {
+		t.Fatalf("failed to list pets: %v", err)
+	}
+	if len(pets) != 1 || pets[0].Name != "rex" || pets[0].Status != PetStatusAvailable {
+		t.Fatalf("unexpected pets %+v", pets)
+	}
+}
+
+func TestListPetsResponseSchema(t *testing.T) {
+	fmt.Println("TestListPetsResponseSchema")
+	server := newPetServer(t)
+	defer server.Close()
+
+	// The server's pets have no tag, which this contract requires.
+	schemas := httpclient.NewSchemaRegistry()
+	err := schemas.Register(http.MethodGet, "/pets", []byte(`{
+		"type": "array",
+		"items": {"type": "object", "required": ["id", "name", "tag"]}
+	}`))
+	if err != nil {
+		t.Fatalf("failed to register schema: %v", err)
+	}
+	client := NewClient(server.URL, httpclient.NewHTTPClient(httpclient.WithResponseSchemas(schemas)))
+	client.Auth = APIKeyAuth("X-API-Key", "secret")
+
+	limit := int32(5)
+	_, err = client.ListPets(context.Background(), ListPetsParams{Tags: []string{"a", "b"}, Limit: &limit})
+	var schemaErr *httpclient.SchemaError
+	if !errors.As(err, &schemaErr) || schemaErr.Endpoint != "/pets" || schemaErr.Violations[0].Pointer != "/0" {
+		t.Fatalf("expected a schema error, got %v", err)
+	}
+}
+
+func TestListPetsDefaultError(t *testing.T) {
+	fmt.Println("TestListPetsDefaultError")
+	server := newPetServer(t)
+	defer server.Close()
+
+	client := NewClient(server.URL, httpclient.NewHTTPClient())
+	_, err := client.ListPets(context.Background(), ListPetsParams{})
+
+	var defaultErr *ListPetsDefaultError
+	if !errors.As(err, &defaultErr) {
+		t.Fatalf("expected ListPetsDefaultError, got %v", err)
+	}
+	if defaultErr.Err.StatusCode != http.StatusUnauthorized || defaultErr.Body.Message != "missing key" {
+		t.Fatalf("unexpected error %+v", defaultErr)
+	}
+}
+
+func TestCreatePet(t *testing.T) {
+	fmt.Println("TestCreatePet")
+	server := newPetServer(t)
+	defer server.Close()
+
+	client := NewClient(server.URL, httpclient.NewHTTPClient())
+	pet, err := client.CreatePet(context.Background(), NewPet{Name: "tom"})
+	if err != nil {
+		t.Fatalf("failed to create pet: %v", err)
+	}
+	if pet.ID != 2 {
+		t.Fatalf("unexpected pet %+v", pet)
+	}
+}
+
+func TestGetPetNotFound(t *testing.T) {
+	fmt.Println("TestGetPetNotFound")
+	server := newPetServer(t)
+	defer server.Close()
+
+	client := NewClient(server.URL, httpclient.NewHTTPClient())
+	client.Auth = APIKeyAuth("X-API-Key", "secret")
+	_, err := client.GetPet(context.Background(), GetPetParams{ID: 404})
+
+	var notFound *GetPet404Error
+	if !errors.As(err, &notFound) {
+		t.Fatalf("expected GetPet404Error, got %v", err)
+	}
+	if notFound.Body.Code != 404 {
+		t.Fatalf("unexpected error body %+v", notFound.Body)
+	}
+	var statusErr *httpclient.StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected typed error to wrap the status error")
+	}
+}
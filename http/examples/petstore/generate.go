@@ -0,0 +1,3 @@
+package petstore
+
+//go:generate go run http/cmd/openapigen -spec petstore.yaml -package petstore -out client.gen.go
@@ -0,0 +1,267 @@
+// Package fault injects failures into the requests of an HTTPClient, to test
+// how services cope with slow or failing dependencies:
+//
+//	injector := fault.New(42,
+//		fault.Rule{Host: "api.shipos.cn", Probability: 0.1, Fault: fault.Fault{Status: 503}},
+//		fault.Rule{Path: "/api/*", Fault: fault.Fault{Latency: 2 * time.Second}},
+//	)
+//	client := httpclient.NewHTTPClient(httpclient.WithMiddleware(injector.Middleware))
+//
+// The first rule matching a request decides its fault. Random draws come from
+// the seed, so a sequence of requests sees the same faults on every run.
+package fault
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	httpclient "http"
+)
+
+// Fault describes what happens to a request. Latency combines with the other
+// faults; of those, the first set in field order applies.
+type Fault struct {
+	// Latency delays the request before anything else happens.
+	Latency time.Duration
+	// Reset fails the request with a connection reset error.
+	Reset bool
+	// Timeout makes the request hang until its context is done, then fail
+	// with a timeout error.
+	Timeout bool
+	// Status answers with this status code without contacting the server.
+	Status int
+	// Body is the body of Status responses, the status text by default.
+	Body string
+	// TruncateBody cuts the real response body after this many bytes, so
+	// reading it fails with io.ErrUnexpectedEOF.
+	TruncateBody int64
+}
+
+// Rule selects requests and the fault injected into them. Empty fields match
+// every request.
+type Rule struct {
+	// Name identifies the rule in Counts, "rule N" by default.
+	Name string
+	// Host matches the request host without port. A leading "*." matches
+	// subdomains.
+	Host string
+	// Path matches the URL path as a path.Match pattern when it contains
+	// wildcards and as a prefix otherwise.
+	Path   string
+	Method string
+	// Probability is the chance in (0, 1] that a matching request gets the
+	// fault. Zero means always.
+	Probability float64
+	Fault       Fault
+}
+
+func (r *Rule) matches(req *http.Request) bool {
+	if r.Method != "" && !strings.EqualFold(r.Method, req.Method) {
+		return false
+	}
+	if r.Host != "" {
+		host := req.URL.Hostname()
+		if strings.HasPrefix(r.Host, "*.") {
+			if !strings.HasSuffix(strings.ToLower(host), strings.ToLower(r.Host[1:])) {
+				return false
+			}
+		} else if !strings.EqualFold(host, r.Host) {
+			return false
+		}
+	}
+	if r.Path != "" {
+		if strings.ContainsAny(r.Path, "*?[") {
+			if ok, _ := path.Match(r.Path, req.URL.Path); !ok {
+				return false
+			}
+		} else if !strings.HasPrefix(req.URL.Path, r.Path) {
+			return false
+		}
+	}
+	return true
+}
+
+// Injector decides which requests fail. It is safe for concurrent use and its
+// rules can be changed while requests flow.
+type Injector struct {
+	enabled int32
+
+	mu     sync.Mutex
+	rules  []Rule
+	rand   *rand.Rand
+	counts map[string]int
+}
+
+// New creates an enabled injector whose random draws are seeded with seed.
+func New(seed int64, rules ...Rule) *Injector {
+	i := &Injector{enabled: 1, rand: rand.New(rand.NewSource(seed)), counts: make(map[string]int)}
+	i.SetRules(rules...)
+	return i
+}
+
+// Enable turns injection on.
+func (i *Injector) Enable() {
+	atomic.StoreInt32(&i.enabled, 1)
+}
+
+// Disable lets all requests through untouched until Enable is called.
+func (i *Injector) Disable() {
+	atomic.StoreInt32(&i.enabled, 0)
+}
+
+func (i *Injector) Enabled() bool {
+	return atomic.LoadInt32(&i.enabled) == 1
+}
+
+// SetRules replaces the rules.
+func (i *Injector) SetRules(rules ...Rule) {
+	named := make([]Rule, len(rules))
+	for n, rule := range rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule %d", n+1)
+		}
+		named[n] = rule
+	}
+	i.mu.Lock()
+	i.rules = named
+	i.mu.Unlock()
+}
+
+// Counts returns how many faults each rule injected.
+func (i *Injector) Counts() map[string]int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	counts := make(map[string]int, len(i.counts))
+	for name, n := range i.counts {
+		counts[name] = n
+	}
+	return counts
+}
+
+// pick returns the fault for req, if any.
+func (i *Injector) pick(req *http.Request) (Fault, bool) {
+	if !i.Enabled() {
+		return Fault{}, false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for n := range i.rules {
+		rule := &i.rules[n]
+		if !rule.matches(req) {
+			continue
+		}
+		if rule.Probability > 0 && rule.Probability < 1 && i.rand.Float64() >= rule.Probability {
+			return Fault{}, false
+		}
+		i.counts[rule.Name]++
+		return rule.Fault, true
+	}
+	return Fault{}, false
+}
+
+// Middleware injects faults into the requests sent through next. Its
+// signature matches the http package's Middleware.
+func (i *Injector) Middleware(next http.RoundTripper) http.RoundTripper {
+	return httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		f, ok := i.pick(req)
+		if !ok {
+			return next.RoundTrip(req)
+		}
+		return f.apply(req, next)
+	})
+}
+
+func (f Fault) apply(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	ctx := req.Context()
+	if f.Latency > 0 {
+		timer := time.NewTimer(f.Latency)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			closeBody(req)
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	switch {
+	case f.Reset:
+		closeBody(req)
+		return nil, &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+	case f.Timeout:
+		closeBody(req)
+		<-ctx.Done()
+		return nil, &timeoutError{err: ctx.Err()}
+	case f.Status != 0:
+		closeBody(req)
+		body := f.Body
+		if body == "" {
+			body = http.StatusText(f.Status)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", f.Status, http.StatusText(f.Status)),
+			StatusCode:    f.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+			Body:          io.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+
+	resp, err := next.RoundTrip(req)
+	if err != nil || f.TruncateBody <= 0 {
+		return resp, err
+	}
+	resp.Body = &truncatedBody{ReadCloser: resp.Body, remaining: f.TruncateBody}
+	return resp, nil
+}
+
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+// timeoutError is a net.Error reporting a timeout, like the errors of a real
+// request that ran out of time.
+type timeoutError struct {
+	err error
+}
+
+func (e *timeoutError) Error() string   { return "injected timeout: " + e.err.Error() }
+func (e *timeoutError) Unwrap() error   { return e.err }
+func (e *timeoutError) Timeout() bool   { return true }
+func (e *timeoutError) Temporary() bool { return true }
+
+var _ net.Error = (*timeoutError)(nil)
+
+// truncatedBody ends a body early with io.ErrUnexpectedEOF.
+type truncatedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *truncatedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
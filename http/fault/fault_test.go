@@ -0,0 +1,168 @@
+package fault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	httpclient "http"
+)
+
+func newServer(hits *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*hits++
+		w.Write([]byte(strings.Repeat("x", 100)))
+	}))
+}
+
+func TestFaults(t *testing.T) {
+	fmt.Println("TestFaults")
+	hits := 0
+	server := newServer(&hits)
+	defer server.Close()
+
+	injector := New(1,
+		Rule{Path: "/reset", Fault: Fault{Reset: true}},
+		Rule{Path: "/timeout", Fault: Fault{Timeout: true}},
+		Rule{Path: "/status", Method: http.MethodGet, Fault: Fault{Status: http.StatusServiceUnavailable, Body: "down"}},
+		Rule{Path: "/slow", Fault: Fault{Latency: 50 * time.Millisecond}},
+		Rule{Path: "/truncate", Fault: Fault{TruncateBody: 10}},
+	)
+	client := httpclient.NewHTTPClient(httpclient.WithMiddleware(injector.Middleware))
+	ctx := context.Background()
+
+	_, err := client.Get(ctx, server.URL+"/reset")
+	// The client helpers flatten errors into their messages.
+	if err == nil || !strings.Contains(err.Error(), syscall.ECONNRESET.Error()) {
+		t.Fatalf("expected a connection reset, got %v", err)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	_, err = client.Get(timeoutCtx, server.URL+"/timeout")
+	cancel()
+	if err == nil || !strings.Contains(err.Error(), "injected timeout") {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+
+	_, err = client.Get(ctx, server.URL+"/status")
+	var statusErr *httpclient.StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable || string(statusErr.Body) != "down" {
+		t.Fatalf("expected a 503, got %v", err)
+	}
+	if _, err := client.Post(ctx, server.URL+"/status", []byte("{}")); err != nil {
+		t.Fatalf("expected POST to pass, got %v", err)
+	}
+
+	start := time.Now()
+	if _, err := client.Get(ctx, server.URL+"/slow"); err != nil || time.Since(start) < 50*time.Millisecond {
+		t.Fatalf("expected a delayed response, got %v after %s", err, time.Since(start))
+	}
+
+	if _, err := client.Get(ctx, server.URL+"/truncate"); err == nil || !strings.Contains(err.Error(), io.ErrUnexpectedEOF.Error()) {
+		t.Fatalf("expected a truncated body, got %v", err)
+	}
+
+	if hits != 3 {
+		t.Fatalf("expected 3 requests to reach the server, got %d", hits)
+	}
+	counts := injector.Counts()
+	for n := 1; n <= 5; n++ {
+		if counts[fmt.Sprintf("rule %d", n)] != 1 {
+			t.Fatalf("unexpected counts %v", counts)
+		}
+	}
+}
+
+func TestProbabilityIsSeeded(t *testing.T) {
+	fmt.Println("TestProbabilityIsSeeded")
+	hits := 0
+	server := newServer(&hits)
+	defer server.Close()
+
+	run := func(seed int64) string {
+		injector := New(seed, Rule{Name: "flaky", Probability: 0.3, Fault: Fault{Status: http.StatusBadGateway}})
+		client := httpclient.NewHTTPClient(httpclient.WithMiddleware(injector.Middleware))
+		var outcome strings.Builder
+		for i := 0; i < 50; i++ {
+			if _, err := client.Get(context.Background(), server.URL); err != nil {
+				outcome.WriteByte('F')
+			} else {
+				outcome.WriteByte('.')
+			}
+		}
+		if injector.Counts()["flaky"] != strings.Count(outcome.String(), "F") {
+			t.Fatalf("counts %v do not match %s", injector.Counts(), outcome.String())
+		}
+		return outcome.String()
+	}
+
+	first := run(7)
+	if second := run(7); first != second {
+		t.Fatalf("same seed gave different faults:\n%s\n%s", first, second)
+	}
+	if failed := strings.Count(first, "F"); failed == 0 || failed == 50 {
+		t.Fatalf("expected some requests to fail, got %s", first)
+	}
+}
+
+func TestRulesMatchAndToggle(t *testing.T) {
+	fmt.Println("TestRulesMatchAndToggle")
+	hits := 0
+	server := newServer(&hits)
+	defer server.Close()
+
+	injector := New(1, Rule{Host: "*.shipos.cn", Path: "/api/*/labels", Fault: Fault{Reset: true}})
+	for _, c := range []struct {
+		url   string
+		fault bool
+	}{
+		{"http://api.shipos.cn/api/v1/labels", true},
+		{"http://API.SHIPOS.CN/api/v2/labels", true},
+		{"http://shipos.cn/api/v1/labels", false},
+		{"http://api.shipos.cn/api/v1/orders", false},
+		{"http://example.com/api/v1/labels", false},
+	} {
+		req, _ := http.NewRequest(http.MethodGet, c.url, nil)
+		if _, fault := injector.pick(req); fault != c.fault {
+			t.Fatalf("%s: expected fault %v", c.url, c.fault)
+		}
+	}
+
+	injector.SetRules(Rule{Fault: Fault{Reset: true}})
+	client := httpclient.NewHTTPClient(httpclient.WithMiddleware(injector.Middleware))
+	if _, err := client.Get(context.Background(), server.URL); err == nil {
+		t.Fatalf("expected a fault")
+	}
+	injector.Disable()
+	if _, err := client.Get(context.Background(), server.URL); err != nil {
+		t.Fatalf("expected no fault while disabled, got %v", err)
+	}
+	injector.Enable()
+	if _, err := client.Get(context.Background(), server.URL); err == nil {
+		t.Fatalf("expected a fault after enabling")
+	}
+}
+
+func TestErrorsKeepTheirKind(t *testing.T) {
+	fmt.Println("TestErrorsKeepTheirKind")
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	if _, err := (Fault{Reset: true}).apply(req, nil); !errors.Is(err, syscall.ECONNRESET) {
+		t.Fatalf("expected a connection reset, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err := (Fault{Timeout: true}).apply(req.WithContext(ctx), nil)
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+}
@@ -0,0 +1,232 @@
+// Package graphql is a GraphQL client on top of the http package's HTTPRequester.
+package graphql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	httpclient "http"
+)
+
+// Request is a single GraphQL operation.
+type Request struct {
+	Query         string                 `json:"query,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	Extensions    map[string]interface{} `json:"extensions,omitempty"`
+
+	// Hash is the SHA-256 of a query registered on the server. When set and
+	// Query is empty, only the hash is sent.
+	Hash string `json:"-"`
+}
+
+// Location is a position in the query document.
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Error is one entry of a GraphQL errors array.
+type Error struct {
+	Message    string                 `json:"message"`
+	Locations  []Location             `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (e Error) Error() string {
+	if len(e.Path) == 0 {
+		return e.Message
+	}
+	parts := make([]string, len(e.Path))
+	for i, p := range e.Path {
+		parts[i] = fmt.Sprint(p)
+	}
+	return strings.Join(parts, ".") + ": " + e.Message
+}
+
+// Code returns the extensions.code of the error, if any.
+func (e Error) Code() string {
+	code, _ := e.Extensions["code"].(string)
+	return code
+}
+
+// Errors is the errors array of a GraphQL response. Data decoded alongside
+// the errors is still written to the caller's output.
+type Errors []Error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "graphql: " + strings.Join(msgs, "; ")
+}
+
+type response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors Errors          `json:"errors"`
+}
+
+// Client sends GraphQL operations to a single endpoint.
+type Client struct {
+	endpoint  string
+	requester httpclient.HTTPRequester
+
+	// Headers are added to every request, e.g. for authorization.
+	Headers map[string]string
+	// PersistedQueries enables automatic persisted queries: the query hash is
+	// sent first and the full query only when the server does not know it.
+	PersistedQueries bool
+}
+
+func NewClient(endpoint string, requester httpclient.HTTPRequester) *Client {
+	return &Client{endpoint: endpoint, requester: requester}
+}
+
+// Do sends req and decodes its data into out, which may be nil.
+// GraphQL errors are returned as Errors.
+func (c *Client) Do(ctx context.Context, req *Request, out interface{}) error {
+	if c.PersistedQueries && req.Query != "" {
+		err := c.do(ctx, withHash(req, hashQuery(req.Query), false), out)
+		if !isPersistedQueryNotFound(err) {
+			return err
+		}
+		return c.do(ctx, withHash(req, hashQuery(req.Query), true), out)
+	}
+	if req.Hash != "" {
+		return c.do(ctx, withHash(req, req.Hash, req.Query != ""), out)
+	}
+	return c.do(ctx, req, out)
+}
+
+// Query sends req and returns its data decoded as T.
+func Query[T any](ctx context.Context, c *Client, req *Request) (T, error) {
+	var out T
+	err := c.Do(ctx, req, &out)
+	return out, err
+}
+
+func (c *Client) do(ctx context.Context, req *Request, out interface{}) error {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("encode graphql request failed: %s", err)
+	}
+	body, err := c.post(ctx, payload)
+	if err != nil {
+		return err
+	}
+	var resp response
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("decode graphql response failed: %s", err)
+	}
+	return resp.decode(out)
+}
+
+// Batch sends several operations in one request. It returns one error per
+// operation, in order, and an error for failures affecting the whole batch.
+// Requests with a Hash are sent with it, as by Do, but without automatic
+// persisted query retries.
+func (c *Client) Batch(ctx context.Context, reqs []*Request, outs []interface{}) ([]error, error) {
+	if len(reqs) != len(outs) {
+		return nil, fmt.Errorf("batch has %d requests but %d outputs", len(reqs), len(outs))
+	}
+	sent := make([]*Request, len(reqs))
+	for i, req := range reqs {
+		sent[i] = req
+		if req.Hash != "" {
+			sent[i] = withHash(req, req.Hash, req.Query != "")
+		}
+	}
+	payload, err := json.Marshal(sent)
+	if err != nil {
+		return nil, fmt.Errorf("encode graphql batch failed: %s", err)
+	}
+	body, err := c.post(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+	var resps []response
+	if err := json.Unmarshal(body, &resps); err != nil {
+		return nil, fmt.Errorf("decode graphql batch response failed: %s", err)
+	}
+	if len(resps) != len(reqs) {
+		return nil, fmt.Errorf("batch has %d requests but %d responses", len(reqs), len(resps))
+	}
+
+	errs := make([]error, len(reqs))
+	for i := range resps {
+		errs[i] = resps[i].decode(outs[i])
+	}
+	return errs, nil
+}
+
+func (c *Client) post(ctx context.Context, payload []byte) ([]byte, error) {
+	headers := map[string]string{"Content-Type": "application/json", "Accept": "application/json"}
+	for k, v := range c.Headers {
+		headers[k] = v
+	}
+	body, err := c.requester.PostWithHeader(ctx, c.endpoint, payload, headers)
+	if err != nil {
+		// Servers commonly answer 4xx with a regular GraphQL error document.
+		var statusErr *httpclient.StatusError
+		if errors.As(err, &statusErr) {
+			var resp response
+			if json.Unmarshal(statusErr.Body, &resp) == nil && len(resp.Errors) > 0 {
+				return nil, resp.Errors
+			}
+		}
+		return nil, err
+	}
+	return body, nil
+}
+
+func (r *response) decode(out interface{}) error {
+	if out != nil && len(r.Data) > 0 && string(r.Data) != "null" {
+		if err := json.Unmarshal(r.Data, out); err != nil {
+			return fmt.Errorf("decode graphql data failed: %s", err)
+		}
+	}
+	if len(r.Errors) > 0 {
+		return r.Errors
+	}
+	return nil
+}
+
+func hashQuery(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// withHash copies req with the persisted query extension set. The query text
+// is kept only when includeQuery is true.
+func withHash(req *Request, hash string, includeQuery bool) *Request {
+	copied := *req
+	copied.Extensions = make(map[string]interface{}, len(req.Extensions)+1)
+	for k, v := range req.Extensions {
+		copied.Extensions[k] = v
+	}
+	copied.Extensions["persistedQuery"] = map[string]interface{}{"version": 1, "sha256Hash": hash}
+	if !includeQuery {
+		copied.Query = ""
+	}
+	return &copied
+}
+
+func isPersistedQueryNotFound(err error) bool {
+	var gqlErrs Errors
+	if !errors.As(err, &gqlErrs) {
+		return false
+	}
+	for _, e := range gqlErrs {
+		if e.Code() == "PERSISTED_QUERY_NOT_FOUND" || e.Message == "PersistedQueryNotFound" {
+			return true
+		}
+	}
+	return false
+}
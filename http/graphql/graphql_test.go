@@ -0,0 +1,183 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	httpclient "http"
+)
+
+type hero struct {
+	Hero struct {
+		Name string `json:"name"`
+	} `json:"hero"`
+}
+
+func TestQuery(t *testing.T) {
+	fmt.Println("TestQuery")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.OperationName != "Hero" || req.Variables["episode"] != "JEDI" || r.Header.Get("Authorization") != "token" {
+			t.Errorf("unexpected request %+v", req)
+		}
+		w.Write([]byte(`{"data": {"hero": {"name": "R2-D2"}}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, httpclient.NewHTTPClient())
+	client.Headers = map[string]string{"Authorization": "token"}
+
+	out, err := Query[hero](context.Background(), client, &Request{
+		Query:         `query Hero($episode: Episode) { hero(episode: $episode) { name } }`,
+		OperationName: "Hero",
+		Variables:     map[string]interface{}{"episode": "JEDI"},
+	})
+	if err != nil {
+		t.Fatalf("failed to query: %v", err)
+	}
+	if out.Hero.Name != "R2-D2" {
+		t.Fatalf("unexpected data %+v", out)
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	fmt.Println("TestQueryErrors")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{
+			"data": {"hero": {"name": "Luke"}},
+			"errors": [{"message": "friend not found", "path": ["hero", "friends", 1], "locations": [{"line": 1, "column": 20}], "extensions": {"code": "NOT_FOUND"}}]
+		}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, httpclient.NewHTTPClient())
+	out, err := Query[hero](context.Background(), client, &Request{Query: `{ hero { name friends { name } } }`})
+
+	var gqlErrs Errors
+	if !errors.As(err, &gqlErrs) || len(gqlErrs) != 1 {
+		t.Fatalf("expected graphql errors, got %v", err)
+	}
+	if gqlErrs[0].Code() != "NOT_FOUND" || gqlErrs[0].Error() != "hero.friends.1: friend not found" {
+		t.Fatalf("unexpected error %+v", gqlErrs[0])
+	}
+	if out.Hero.Name != "Luke" {
+		t.Fatalf("expected partial data to be decoded, got %+v", out)
+	}
+}
+
+func TestPersistedQueries(t *testing.T) {
+	fmt.Println("TestPersistedQueries")
+	known := map[string]string{}
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		var req Request
+		json.NewDecoder(r.Body).Decode(&req)
+		pq, _ := req.Extensions["persistedQuery"].(map[string]interface{})
+		hash, _ := pq["sha256Hash"].(string)
+		if req.Query == "" {
+			if _, ok := known[hash]; !ok {
+				w.Write([]byte(`{"errors": [{"message": "PersistedQueryNotFound", "extensions": {"code": "PERSISTED_QUERY_NOT_FOUND"}}]}`))
+				return
+			}
+		} else {
+			known[hash] = req.Query
+		}
+		w.Write([]byte(`{"data": {"hero": {"name": "R2-D2"}}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, httpclient.NewHTTPClient())
+	client.PersistedQueries = true
+	req := &Request{Query: `{ hero { name } }`}
+
+	for i := 0; i < 2; i++ {
+		if _, err := Query[hero](context.Background(), client, req); err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+	}
+	// The first query registers the hash, the second is served from it.
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	fmt.Println("TestBatch")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqs []Request
+		if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil || len(reqs) != 2 {
+			t.Errorf("expected a batch of 2, got %v, %v", reqs, err)
+		}
+		w.Write([]byte(`[{"data": {"hero": {"name": "R2-D2"}}}, {"errors": [{"message": "boom"}]}]`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, httpclient.NewHTTPClient())
+	var first, second hero
+	errs, err := client.Batch(context.Background(),
+		[]*Request{{Query: `{ hero { name } }`}, {Query: `{ broken }`}},
+		[]interface{}{&first, &second})
+	if err != nil {
+		t.Fatalf("failed to send batch: %v", err)
+	}
+	if errs[0] != nil || first.Hero.Name != "R2-D2" {
+		t.Fatalf("unexpected first result %+v, %v", first, errs[0])
+	}
+	if errs[1] == nil || errs[1].Error() != "graphql: boom" {
+		t.Fatalf("unexpected second error %v", errs[1])
+	}
+}
+
+func TestBatchWithHashes(t *testing.T) {
+	fmt.Println("TestBatchWithHashes")
+	hash := hashQuery(`{ hero { name } }`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqs []Request
+		if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil || len(reqs) != 2 {
+			t.Errorf("expected a batch of 2, got %v, %v", reqs, err)
+			return
+		}
+		pq, _ := reqs[0].Extensions["persistedQuery"].(map[string]interface{})
+		if sent, _ := pq["sha256Hash"].(string); sent != hash || reqs[0].Query != "" {
+			t.Errorf("expected the first request by hash only, got %+v", reqs[0])
+		}
+		if reqs[1].Query != `{ droid }` || reqs[1].Extensions != nil {
+			t.Errorf("expected the second request as it is, got %+v", reqs[1])
+		}
+		w.Write([]byte(`[{"data": {"hero": {"name": "R2-D2"}}}, {"data": {}}]`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, httpclient.NewHTTPClient())
+	var first hero
+	errs, err := client.Batch(context.Background(),
+		[]*Request{{Hash: hash}, {Query: `{ droid }`}},
+		[]interface{}{&first, nil})
+	if err != nil || errs[0] != nil || errs[1] != nil || first.Hero.Name != "R2-D2" {
+		t.Fatalf("unexpected batch result %+v, %v, %v", first, errs, err)
+	}
+}
+
+func TestStatusErrorWithGraphQLBody(t *testing.T) {
+	fmt.Println("TestStatusErrorWithGraphQLBody")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"errors": [{"message": "syntax error", "extensions": {"code": "GRAPHQL_PARSE_FAILED"}}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, httpclient.NewHTTPClient())
+	err := client.Do(context.Background(), &Request{Query: `{`}, nil)
+
+	var gqlErrs Errors
+	if !errors.As(err, &gqlErrs) || gqlErrs[0].Code() != "GRAPHQL_PARSE_FAILED" {
+		t.Fatalf("expected graphql errors, got %v", err)
+	}
+}
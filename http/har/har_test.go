@@ -0,0 +1,228 @@
+package har
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	httpclient "http"
+)
+
+func readHAR(t *testing.T, path string) *HAR {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	var h HAR
+	if err := json.Unmarshal(data, &h); err != nil {
+		t.Fatalf("%s is not valid JSON: %v", path, err)
+	}
+	return &h
+}
+
+func header(list []NameValue, name string) string {
+	for _, nv := range list {
+		if strings.EqualFold(nv.Name, name) {
+			return nv.Value
+		}
+	}
+	return ""
+}
+
+func newServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/labels":
+			body, _ := io.ReadAll(r.Body)
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cret", HttpOnly: true})
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(bytes.ToUpper(body))
+		case "/large":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte(strings.Repeat("é", 100)))
+		case "/pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Write([]byte{0x25, 0x50, 0x44, 0x46, 0xff, 0xfe})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestRecorderCapturesEntries(t *testing.T) {
+	fmt.Println("TestRecorderCapturesEntries")
+	server := newServer()
+	defer server.Close()
+
+	dir := t.TempDir()
+	rec, err := NewRecorder(dir, WithMaxBodySize(51), WithRedactedHeaders("appsecret"), WithRedactedQuery("token"),
+		WithRedact(func(e *Entry) {
+			if e.Request.PostData != nil {
+				e.Request.PostData.Text = strings.ReplaceAll(e.Request.PostData.Text, "odr-1", "odr-*")
+			}
+		}))
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	client := httpclient.NewHTTPClient(httpclient.WithMiddleware(rec.Middleware))
+	ctx := context.Background()
+
+	resp, err := client.PostWithHeader(ctx, server.URL+"/labels?token=abc&format=pdf", []byte(`{"orderid":"odr-1"}`),
+		map[string]string{"appkey": "shipos_959", "appsecret": "194aa", "Authorization": "Bearer t"})
+	if err != nil || string(resp) != `{"ORDERID":"ODR-1"}` {
+		t.Fatalf("unexpected response %q, %v", resp, err)
+	}
+	if _, err := client.GetWithHeader(ctx, server.URL+"/large", nil); err != nil {
+		t.Fatalf("failed to get: %v", err)
+	}
+	if _, err := client.Get(ctx, server.URL+"/pdf"); err != nil {
+		t.Fatalf("failed to get: %v", err)
+	}
+	client.Get(ctx, "http://127.0.0.1:1/unreachable")
+
+	files := rec.Files()
+	if len(files) != 1 {
+		t.Fatalf("expected one file, got %q", files)
+	}
+	// Files are complete documents even before the recorder is closed.
+	h := readHAR(t, files[0])
+	if err := rec.Close(); err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+	if h.Log.Version != "1.2" || len(h.Log.Entries) != 4 {
+		t.Fatalf("unexpected log: version %s, %d entries", h.Log.Version, len(h.Log.Entries))
+	}
+
+	post := h.Log.Entries[0]
+	if post.Request.Method != http.MethodPost || post.Response.Status != http.StatusOK {
+		t.Fatalf("unexpected entry %+v", post)
+	}
+	if header(post.Request.Headers, "appsecret") != Redacted || header(post.Request.Headers, "Authorization") != Redacted ||
+		header(post.Request.Headers, "appkey") != "shipos_959" {
+		t.Fatalf("unexpected request headers %+v", post.Request.Headers)
+	}
+	if !strings.Contains(post.Request.URL, "token=REDACTED") || header(post.Request.QueryString, "token") != Redacted ||
+		header(post.Request.QueryString, "format") != "pdf" {
+		t.Fatalf("query not redacted: %s %+v", post.Request.URL, post.Request.QueryString)
+	}
+	if post.Request.PostData == nil || post.Request.PostData.Text != `{"orderid":"odr-*"}` || post.Request.BodySize != 19 {
+		t.Fatalf("unexpected post data %+v", post.Request.PostData)
+	}
+	if len(post.Response.Cookies) != 1 || post.Response.Cookies[0].Value != Redacted || header(post.Response.Headers, "Set-Cookie") != Redacted {
+		t.Fatalf("cookies not redacted: %+v", post.Response.Cookies)
+	}
+	if post.Response.Content.Text != `{"ORDERID":"ODR-1"}` || post.Response.Content.MimeType != "application/json" {
+		t.Fatalf("unexpected content %+v", post.Response.Content)
+	}
+	tm := post.Timings
+	if tm.Connect < 0 || tm.Send < 0 || tm.Wait < 0 || tm.Receive < 0 || tm.Blocked < 0 || tm.SSL != -1 || post.Time <= 0 {
+		t.Fatalf("unexpected timings %+v in %f", tm, post.Time)
+	}
+	if post.ServerIPAddress != "127.0.0.1" {
+		t.Fatalf("unexpected server address %q", post.ServerIPAddress)
+	}
+
+	large := h.Log.Entries[1].Response
+	// 51 bytes cut through the 26th two-byte character, which is dropped.
+	if large.Content.Text != strings.Repeat("é", 25) || large.Content.Size != 200 ||
+		large.Content.Comment != "body truncated to 51 of 200 bytes" {
+		t.Fatalf("unexpected truncated content %+v", large.Content)
+	}
+	if h.Log.Entries[1].Timings.Connect != -1 {
+		t.Fatalf("expected a reused connection, got %+v", h.Log.Entries[1].Timings)
+	}
+
+	pdf := h.Log.Entries[2].Response.Content
+	if decoded, _ := base64.StdEncoding.DecodeString(pdf.Text); pdf.Encoding != "base64" || len(decoded) != 6 {
+		t.Fatalf("unexpected binary content %+v", pdf)
+	}
+
+	failed := h.Log.Entries[3]
+	if failed.Error == "" || failed.Response.Status != 0 {
+		t.Fatalf("expected a failed entry, got %+v", failed)
+	}
+	// HAR allows -1 only for the optional phases.
+	if tm := failed.Timings; tm.Send != 0 || tm.Wait != 0 || tm.Receive != 0 {
+		t.Fatalf("expected the phases of a failed request to be 0, got %+v", tm)
+	}
+}
+
+func TestRecorderRoutes(t *testing.T) {
+	fmt.Println("TestRecorderRoutes")
+	server := newServer()
+	defer server.Close()
+
+	dir := t.TempDir()
+	rec, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	client := httpclient.NewHTTPClient(httpclient.WithMiddleware(rec.Middleware))
+	ctx := context.Background()
+	if _, err := client.GetWithTemplate(ctx, server.URL+"{/name}", map[string]interface{}{"name": "pdf"}, nil); err != nil {
+		t.Fatalf("failed to get: %v", err)
+	}
+	if _, err := client.Get(ctx, server.URL+"/pdf"); err != nil {
+		t.Fatalf("failed to get: %v", err)
+	}
+	rec.Close()
+
+	files := rec.Files()
+	if len(files) != 1 {
+		t.Fatalf("expected one file, got %q", files)
+	}
+	h := readHAR(t, files[0])
+	if len(h.Log.Entries) != 2 || h.Log.Entries[0].Route != server.URL+"{/name}" || h.Log.Entries[1].Route != "" {
+		t.Fatalf("unexpected routes %+v", h.Log.Entries)
+	}
+}
+
+func TestRecorderRotatesFiles(t *testing.T) {
+	fmt.Println("TestRecorderRotatesFiles")
+	server := newServer()
+	defer server.Close()
+
+	rec, err := NewRecorder(t.TempDir(), WithMaxFileSize(2048), WithMaxFiles(2), WithFilePrefix("vendor"))
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	client := httpclient.NewHTTPClient(httpclient.WithMiddleware(rec.Middleware))
+	for i := 0; i < 12; i++ {
+		client.PostWithHeader(context.Background(), server.URL+"/labels", []byte(fmt.Sprintf(`{"n":%d}`, i)), nil)
+	}
+	rec.Close()
+
+	files := rec.Files()
+	if len(files) != 2 {
+		t.Fatalf("expected 2 files kept, got %q", files)
+	}
+	last := 0
+	for _, path := range files {
+		if !strings.Contains(path, "vendor-") {
+			t.Fatalf("unexpected file name %s", path)
+		}
+		info, _ := os.Stat(path)
+		if info.Size() > 2048 {
+			t.Fatalf("%s has %d bytes", path, info.Size())
+		}
+		for _, e := range readHAR(t, path).Log.Entries {
+			var n struct{ N int }
+			json.Unmarshal([]byte(e.Request.PostData.Text), &n)
+			if n.N < last {
+				t.Fatalf("entries out of order")
+			}
+			last = n.N
+		}
+	}
+	if last != 11 {
+		t.Fatalf("expected the newest entries to be kept, last is %d", last)
+	}
+}
@@ -0,0 +1,481 @@
+package har
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	httpclient "http"
+)
+
+// Redacted replaces the values of redacted headers, cookies and query
+// parameters.
+const Redacted = "REDACTED"
+
+// Recorder captures requests and responses into rotating HAR files.
+type Recorder struct {
+	writer        *fileWriter
+	maxBodySize   int64
+	redactHeaders map[string]bool
+	redactQuery   map[string]bool
+	redact        func(*Entry)
+	now           func() time.Time
+}
+
+// Option configures a Recorder.
+type Option func(*Recorder)
+
+// WithMaxBodySize sets how many bytes of each request and response body are
+// kept, 64 KiB by default. Longer bodies are truncated and say so in their
+// comment.
+func WithMaxBodySize(n int64) Option {
+	return func(r *Recorder) {
+		r.maxBodySize = n
+	}
+}
+
+// WithMaxFileSize starts a new file once the current one would grow beyond n
+// bytes, 10 MiB by default.
+func WithMaxFileSize(n int64) Option {
+	return func(r *Recorder) {
+		r.writer.maxSize = n
+	}
+}
+
+// WithMaxFiles deletes the oldest files written by the recorder so that at
+// most n are kept. By default all are kept.
+func WithMaxFiles(n int) Option {
+	return func(r *Recorder) {
+		r.writer.maxFiles = n
+	}
+}
+
+// WithFilePrefix sets the name prefix of the files, "capture" by default.
+func WithFilePrefix(prefix string) Option {
+	return func(r *Recorder) {
+		r.writer.prefix = prefix
+	}
+}
+
+// WithRedactedHeaders redacts the values of the given headers in addition to
+// Authorization, Proxy-Authorization, Cookie and Set-Cookie.
+func WithRedactedHeaders(names ...string) Option {
+	return func(r *Recorder) {
+		for _, name := range names {
+			r.redactHeaders[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+}
+
+// WithRedactedQuery redacts the values of the given query parameters.
+func WithRedactedQuery(names ...string) Option {
+	return func(r *Recorder) {
+		for _, name := range names {
+			r.redactQuery[name] = true
+		}
+	}
+}
+
+// WithRedact calls fn on every entry before it is written, e.g. to mask
+// secrets in bodies.
+func WithRedact(fn func(*Entry)) Option {
+	return func(r *Recorder) {
+		r.redact = fn
+	}
+}
+
+// NewRecorder creates a recorder writing HAR files into dir, which is created
+// if needed.
+func NewRecorder(dir string, opts ...Option) (*Recorder, error) {
+	r := &Recorder{
+		writer:      newFileWriter(dir),
+		maxBodySize: 64 << 10,
+		redactHeaders: map[string]bool{
+			"Authorization":       true,
+			"Proxy-Authorization": true,
+			"Cookie":              true,
+			"Set-Cookie":          true,
+		},
+		redactQuery: make(map[string]bool),
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if err := r.writer.init(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Files returns the paths of the files written so far, oldest first, without
+// those deleted by WithMaxFiles.
+func (r *Recorder) Files() []string {
+	return r.writer.list()
+}
+
+// Close finishes the current file. Entries completing afterwards are dropped.
+func (r *Recorder) Close() error {
+	return r.writer.close()
+}
+
+// Middleware records the requests sent through next. Its signature matches
+// the http package's Middleware.
+func (r *Recorder) Middleware(next http.RoundTripper) http.RoundTripper {
+	return httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		c := &capture{recorder: r, start: r.now()}
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), c.trace()))
+		if req.Body != nil && req.Body != http.NoBody {
+			c.reqBody = &bodyCapture{ReadCloser: req.Body, limit: r.maxBodySize}
+			req.Body = c.reqBody
+		}
+
+		resp, err := next.RoundTrip(req)
+		if err != nil {
+			c.finish(req, nil, err)
+			return nil, err
+		}
+		c.respBody = &bodyCapture{ReadCloser: resp.Body, limit: r.maxBodySize}
+		c.respBody.done = func() { c.finish(req, resp, nil) }
+		resp.Body = c.respBody
+		return resp, nil
+	})
+}
+
+// capture collects the timings and bodies of one exchange.
+type capture struct {
+	recorder *Recorder
+	reqBody  *bodyCapture
+	respBody *bodyCapture
+	once     sync.Once
+
+	mu                    sync.Mutex
+	start                 time.Time
+	dnsStart, dnsDone     time.Time
+	connStart, connDone   time.Time
+	tlsStart, tlsDone     time.Time
+	gotConn, wroteReq     time.Time
+	firstByte             time.Time
+	remoteAddr, localAddr string
+}
+
+func (c *capture) trace() *httptrace.ClientTrace {
+	mark := func(t *time.Time) {
+		c.mu.Lock()
+		*t = c.recorder.now()
+		c.mu.Unlock()
+	}
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { mark(&c.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { mark(&c.dnsDone) },
+		ConnectStart: func(string, string) {
+			// Dialing several addresses only counts the first start.
+			c.mu.Lock()
+			if c.connStart.IsZero() {
+				c.connStart = c.recorder.now()
+			}
+			c.mu.Unlock()
+		},
+		ConnectDone:       func(string, string, error) { mark(&c.connDone) },
+		TLSHandshakeStart: func() { mark(&c.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { mark(&c.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			c.mu.Lock()
+			c.gotConn = c.recorder.now()
+			if info.Conn != nil {
+				c.remoteAddr = info.Conn.RemoteAddr().String()
+				c.localAddr = info.Conn.LocalAddr().String()
+			}
+			c.mu.Unlock()
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { mark(&c.wroteReq) },
+		GotFirstResponseByte: func() { mark(&c.firstByte) },
+	}
+}
+
+func ms(from, to time.Time) float64 {
+	if from.IsZero() || to.IsZero() {
+		return -1
+	}
+	return float64(to.Sub(from)) / float64(time.Millisecond)
+}
+
+// required is ms for the send, wait and receive phases, which HAR does not
+// allow to be -1: phases that did not happen take no time.
+func required(from, to time.Time) float64 {
+	if from.IsZero() || to.IsZero() {
+		return 0
+	}
+	return ms(from, to)
+}
+
+func (c *capture) finish(req *http.Request, resp *http.Response, err error) {
+	c.once.Do(func() {
+		end := c.recorder.now()
+		entry := c.entry(req, resp, err, end)
+		c.recorder.redactEntry(&entry)
+		c.recorder.writer.write(&entry)
+	})
+}
+
+func (c *capture) entry(req *http.Request, resp *http.Response, err error, end time.Time) Entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := Timings{
+		DNS:     ms(c.dnsStart, c.dnsDone),
+		Connect: ms(c.connStart, c.connDone),
+		SSL:     ms(c.tlsStart, c.tlsDone),
+		Send:    required(c.gotConn, c.wroteReq),
+		Wait:    required(c.wroteReq, c.firstByte),
+		Receive: required(c.firstByte, end),
+	}
+	if t.Connect >= 0 && t.SSL >= 0 {
+		// HAR counts the handshake in connect; the dial may have ended
+		// before it.
+		t.Connect = ms(c.connStart, c.tlsDone)
+	}
+	t.Blocked = ms(c.start, c.gotConn)
+	for _, phase := range []float64{t.DNS, t.Connect} {
+		if t.Blocked >= 0 && phase > 0 {
+			t.Blocked -= phase
+		}
+	}
+	if t.Blocked < 0 && !c.gotConn.IsZero() {
+		t.Blocked = 0
+	}
+
+	proto := "HTTP/1.1"
+	if resp != nil && resp.Proto != "" {
+		proto = resp.Proto
+	}
+	e := Entry{
+		StartedDateTime: c.start,
+		Time:            ms(c.start, end),
+		Request:         newRequest(req, proto, c.reqBody),
+		Timings:         t,
+		ServerIPAddress: hostOnly(c.remoteAddr),
+		Connection:      portOnly(c.localAddr),
+		Route:           httpclient.RouteFromContext(req.Context()),
+	}
+	if err != nil {
+		e.Error = err.Error()
+		e.Response = Response{Cookies: []Cookie{}, Headers: []NameValue{}, HTTPVersion: proto, HeadersSize: -1, BodySize: -1}
+		return e
+	}
+	e.Response = newResponse(resp, proto, c.respBody)
+	return e
+}
+
+func newRequest(req *http.Request, proto string, body *bodyCapture) Request {
+	r := Request{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: proto,
+		Cookies:     []Cookie{},
+		Headers:     headerList(req.Header),
+		QueryString: []NameValue{},
+		HeadersSize: -1,
+	}
+	if req.Host != "" && req.Host != req.URL.Host {
+		r.Headers = append([]NameValue{{Name: "Host", Value: req.Host}}, r.Headers...)
+	}
+	for _, cookie := range req.Cookies() {
+		r.Cookies = append(r.Cookies, Cookie{Name: cookie.Name, Value: cookie.Value})
+	}
+	for _, kv := range strings.Split(req.URL.RawQuery, "&") {
+		if kv == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(kv, "=")
+		name, _ = url.QueryUnescape(name)
+		value, _ = url.QueryUnescape(value)
+		r.QueryString = append(r.QueryString, NameValue{Name: name, Value: value})
+	}
+	if body != nil {
+		text, _, comment := body.text()
+		r.BodySize = body.size
+		r.PostData = &PostData{MimeType: req.Header.Get("Content-Type"), Text: text, Comment: comment}
+	}
+	return r
+}
+
+func newResponse(resp *http.Response, proto string, body *bodyCapture) Response {
+	r := Response{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: proto,
+		Cookies:     []Cookie{},
+		Headers:     headerList(resp.Header),
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    body.size,
+	}
+	for _, cookie := range resp.Cookies() {
+		c := Cookie{Name: cookie.Name, Value: cookie.Value, Path: cookie.Path, Domain: cookie.Domain,
+			HTTPOnly: cookie.HttpOnly, Secure: cookie.Secure}
+		if !cookie.Expires.IsZero() {
+			expires := cookie.Expires
+			c.Expires = &expires
+		}
+		r.Cookies = append(r.Cookies, c)
+	}
+	text, encoding, comment := body.text()
+	r.Content = Content{
+		Size:     body.size,
+		MimeType: resp.Header.Get("Content-Type"),
+		Text:     text,
+		Encoding: encoding,
+		Comment:  comment,
+	}
+	return r
+}
+
+func headerList(header http.Header) []NameValue {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list := []NameValue{}
+	for _, name := range names {
+		for _, value := range header[name] {
+			list = append(list, NameValue{Name: name, Value: value})
+		}
+	}
+	return list
+}
+
+func (r *Recorder) redactEntry(e *Entry) {
+	redactHeaders := func(headers []NameValue) {
+		for i := range headers {
+			if r.redactHeaders[http.CanonicalHeaderKey(headers[i].Name)] {
+				headers[i].Value = Redacted
+			}
+		}
+	}
+	redactHeaders(e.Request.Headers)
+	redactHeaders(e.Response.Headers)
+	if r.redactHeaders["Cookie"] {
+		for i := range e.Request.Cookies {
+			e.Request.Cookies[i].Value = Redacted
+		}
+	}
+	if r.redactHeaders["Set-Cookie"] {
+		for i := range e.Response.Cookies {
+			e.Response.Cookies[i].Value = Redacted
+		}
+	}
+
+	if len(r.redactQuery) > 0 {
+		for i := range e.Request.QueryString {
+			if r.redactQuery[e.Request.QueryString[i].Name] {
+				e.Request.QueryString[i].Value = Redacted
+			}
+		}
+		if u, err := url.Parse(e.Request.URL); err == nil {
+			query := u.Query()
+			for name := range query {
+				if r.redactQuery[name] {
+					query.Set(name, Redacted)
+				}
+			}
+			u.RawQuery = query.Encode()
+			e.Request.URL = u.String()
+		}
+	}
+	if u, err := url.Parse(e.Request.URL); err == nil && u.User != nil {
+		u.User = url.User(u.User.Username())
+		e.Request.URL = u.String()
+	}
+
+	if r.redact != nil {
+		r.redact(e)
+	}
+}
+
+func hostOnly(addr string) string {
+	if i := strings.LastIndex(addr, ":"); i >= 0 {
+		return strings.Trim(addr[:i], "[]")
+	}
+	return addr
+}
+
+func portOnly(addr string) string {
+	if i := strings.LastIndex(addr, ":"); i >= 0 {
+		return addr[i+1:]
+	}
+	return ""
+}
+
+// bodyCapture keeps the first limit bytes of a body as it is read and counts
+// the rest. done is called once the body hits EOF or is closed.
+type bodyCapture struct {
+	io.ReadCloser
+	limit int64
+
+	mu   sync.Mutex
+	buf  []byte
+	size int64
+	done func()
+	once sync.Once
+}
+
+func (b *bodyCapture) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.mu.Lock()
+	b.size += int64(n)
+	if keep := b.limit - int64(len(b.buf)); keep > 0 {
+		if int64(n) < keep {
+			keep = int64(n)
+		}
+		b.buf = append(b.buf, p[:keep]...)
+	}
+	b.mu.Unlock()
+	if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *bodyCapture) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish()
+	return err
+}
+
+func (b *bodyCapture) finish() {
+	if b.done != nil {
+		b.once.Do(b.done)
+	}
+}
+
+// text returns the captured body as HAR text, base64 encoded unless it is
+// UTF-8, with a comment when it was truncated.
+func (b *bodyCapture) text() (text string, encoding string, comment string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if int64(len(b.buf)) < b.size {
+		comment = fmt.Sprintf("body truncated to %d of %d bytes", len(b.buf), b.size)
+	}
+	buf := b.buf
+	if comment != "" {
+		// Do not let the cut through a character make the text binary.
+		for i := 0; i < utf8.UTFMax-1 && len(buf) > 0 && !utf8.Valid(buf); i++ {
+			buf = buf[:len(buf)-1]
+		}
+	}
+	if utf8.Valid(buf) {
+		return string(buf), "", comment
+	}
+	return base64.StdEncoding.EncodeToString(b.buf), "base64", comment
+}
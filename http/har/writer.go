@@ -0,0 +1,123 @@
+package har
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	fileHeader  = []byte(`{"log":{"version":"1.2","creator":{"name":"http/har","version":"1.0"},"entries":[`)
+	fileTrailer = []byte("]}}\n")
+)
+
+// fileWriter appends entries to HAR files. After every entry the file is a
+// complete document: the closing brackets are written after the entry and
+// overwritten by the next one.
+type fileWriter struct {
+	dir      string
+	prefix   string
+	maxSize  int64
+	maxFiles int
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64 // bytes before the trailer
+	entries int
+	seq     int
+	files   []string
+	closed  bool
+}
+
+func newFileWriter(dir string) *fileWriter {
+	return &fileWriter{dir: dir, prefix: "capture", maxSize: 10 << 20}
+}
+
+func (w *fileWriter) init() error {
+	if err := os.MkdirAll(w.dir, 0o755); err != nil {
+		return fmt.Errorf("create capture directory failed: %s", err)
+	}
+	return nil
+}
+
+// write appends e. Errors are dropped: capturing must not break the traffic
+// it observes.
+func (w *fileWriter) write(e *Entry) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+
+	if w.file != nil && w.entries > 0 && w.size+1+int64(len(data))+int64(len(fileTrailer)) > w.maxSize {
+		w.finishFile()
+	}
+	if w.file == nil {
+		if err := w.openFile(); err != nil {
+			return
+		}
+	}
+
+	var buf []byte
+	if w.entries > 0 {
+		buf = append(buf, ',')
+	}
+	buf = append(buf, data...)
+	if _, err := w.file.WriteAt(append(buf, fileTrailer...), w.size); err != nil {
+		return
+	}
+	w.size += int64(len(buf))
+	w.entries++
+}
+
+func (w *fileWriter) openFile() error {
+	w.seq++
+	name := fmt.Sprintf("%s-%s-%03d.har", w.prefix, time.Now().Format("20060102T150405"), w.seq)
+	path := filepath.Join(w.dir, name)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(append([]byte(nil), fileHeader...), fileTrailer...)); err != nil {
+		f.Close()
+		return err
+	}
+	w.file = f
+	w.size = int64(len(fileHeader))
+	w.entries = 0
+	w.files = append(w.files, path)
+	for w.maxFiles > 0 && len(w.files) > w.maxFiles {
+		os.Remove(w.files[0])
+		w.files = w.files[1:]
+	}
+	return nil
+}
+
+func (w *fileWriter) finishFile() error {
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+func (w *fileWriter) list() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.files...)
+}
+
+func (w *fileWriter) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	if w.file == nil {
+		return nil
+	}
+	return w.finishFile()
+}
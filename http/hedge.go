@@ -0,0 +1,239 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HedgePolicy configures hedged requests: when a GET or HEAD request has not
+// been answered after a delay, an identical second request is sent and the
+// first response wins. The other request is cancelled.
+type HedgePolicy struct {
+	// Delay is how long to wait before hedging.
+	Delay time.Duration
+	// Percentile, between 0 and 100, derives the delay from the latencies
+	// of recent requests instead, e.g. 95 hedges requests slower than the
+	// p95. Delay applies until enough latencies are known.
+	Percentile float64
+	// Budget caps hedges to this fraction of requests, 0.1 by default.
+	Budget float64
+}
+
+// HedgeStats counts hedging activity.
+type HedgeStats struct {
+	// Requests is the number of requests that could be hedged.
+	Requests int64
+	// Hedges is the number of hedge requests sent.
+	Hedges int64
+	// Wins is the number of hedge requests that answered first.
+	Wins int64
+	// Denied is the number of hedges skipped for lack of budget.
+	Denied int64
+}
+
+const (
+	hedgeSamples    = 256
+	hedgeMinSamples = 20
+	// hedgeMaxTokens is the most hedges the budget saves up.
+	hedgeMaxTokens = 10
+)
+
+// WithHedging hedges the client's idempotent requests without a body.
+func WithHedging(policy HedgePolicy) Option {
+	return func(c *HTTPClient) {
+		if policy.Budget <= 0 {
+			policy.Budget = 0.1
+		}
+		c.hedger = &hedger{policy: policy, tokens: 1}
+	}
+}
+
+// HedgeStats returns the hedging counters, zero when hedging is off.
+func (c *HTTPClient) HedgeStats() HedgeStats {
+	if c.hedger == nil {
+		return HedgeStats{}
+	}
+	c.hedger.mu.Lock()
+	defer c.hedger.mu.Unlock()
+	return c.hedger.stats
+}
+
+// hedger is the transport sending hedged requests.
+type hedger struct {
+	policy HedgePolicy
+	next   http.RoundTripper
+
+	mu        sync.Mutex
+	stats     HedgeStats
+	tokens    float64
+	latencies [hedgeSamples]time.Duration
+	samples   int
+	delay     time.Duration // cached percentile delay
+	sampledAt int           // samples when delay was computed
+}
+
+type hedgeResult struct {
+	resp  *http.Response
+	err   error
+	index int // 0 for the original request, 1 for the hedge
+}
+
+func (h *hedger) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead || req.Body != nil && req.Body != http.NoBody {
+		return h.next.RoundTrip(req)
+	}
+	delay := h.begin()
+	start := time.Now()
+	if delay <= 0 {
+		resp, err := h.next.RoundTrip(req)
+		if err == nil {
+			h.record(time.Since(start))
+		}
+		return resp, err
+	}
+
+	results := make(chan hedgeResult, 2)
+	var cancels []context.CancelFunc
+	// Each request is timed apart, so that a loser answering late does not
+	// pass for the winner.
+	timings, _ := req.Context().Value(timingsKey{}).(*timingsHolder)
+	var holders []*timingsHolder
+	send := func() {
+		ctx, cancel := context.WithCancel(req.Context())
+		if timings != nil {
+			holder := &timingsHolder{}
+			holders = append(holders, holder)
+			ctx = context.WithValue(ctx, timingsKey{}, holder)
+		}
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			resp, err := h.next.RoundTrip(req.Clone(ctx))
+			results <- hedgeResult{resp: resp, err: err, index: index}
+		}()
+	}
+	send()
+	inFlight := 1
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	timerC := timer.C
+
+	for {
+		select {
+		case <-timerC:
+			timerC = nil
+			if h.take() {
+				send()
+				inFlight++
+			}
+		case r := <-results:
+			inFlight--
+			if r.err != nil {
+				cancels[r.index]()
+				// An error is not worth a hedge: fail once nothing else
+				// can answer.
+				if inFlight == 0 {
+					return nil, r.err
+				}
+				continue
+			}
+			for i, cancel := range cancels {
+				if i != r.index {
+					cancel()
+				}
+			}
+			h.finish(time.Since(start), r.index > 0)
+			if timings != nil {
+				timings.set(holders[r.index].get())
+			}
+			go drain(results, inFlight)
+			r.resp.Body = &cancelOnClose{ReadCloser: r.resp.Body, cancel: cancels[r.index]}
+			return r.resp, nil
+		}
+	}
+}
+
+// drain discards the responses of the n cancelled requests that lost.
+func drain(results <-chan hedgeResult, n int) {
+	for ; n > 0; n-- {
+		r := <-results
+		if r.resp != nil {
+			r.resp.Body.Close()
+		}
+	}
+}
+
+// cancelOnClose releases the context of a winning request with its body.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// begin counts a request, earns budget and returns the hedging delay, 0 for
+// none.
+func (h *hedger) begin() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stats.Requests++
+	h.tokens += h.policy.Budget
+	if h.tokens > hedgeMaxTokens {
+		h.tokens = hedgeMaxTokens
+	}
+	if h.policy.Percentile <= 0 || h.samples < hedgeMinSamples {
+		return h.policy.Delay
+	}
+	if h.delay == 0 || h.samples-h.sampledAt >= hedgeSamples/8 {
+		n := h.samples
+		if n > hedgeSamples {
+			n = hedgeSamples
+		}
+		sorted := make([]time.Duration, n)
+		copy(sorted, h.latencies[:n])
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		i := int(h.policy.Percentile / 100 * float64(n))
+		if i >= n {
+			i = n - 1
+		}
+		h.delay, h.sampledAt = sorted[i], h.samples
+	}
+	return h.delay
+}
+
+// take spends budget on a hedge.
+func (h *hedger) take() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.tokens < 1 {
+		h.stats.Denied++
+		return false
+	}
+	h.tokens--
+	h.stats.Hedges++
+	return true
+}
+
+func (h *hedger) finish(latency time.Duration, hedgeWon bool) {
+	h.record(latency)
+	if hedgeWon {
+		h.mu.Lock()
+		h.stats.Wins++
+		h.mu.Unlock()
+	}
+}
+
+func (h *hedger) record(latency time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.latencies[h.samples%hedgeSamples] = latency
+	h.samples++
+}
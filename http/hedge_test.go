@@ -0,0 +1,105 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowFirstServer answers its first request after slow, or when the request
+// is cancelled, and the others at once.
+type slowFirstServer struct {
+	slow time.Duration
+
+	mu        sync.Mutex
+	requests  int
+	cancelled chan struct{}
+}
+
+func (s *slowFirstServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests++
+	first := s.requests == 1
+	s.mu.Unlock()
+	if first {
+		select {
+		case <-time.After(s.slow):
+		case <-r.Context().Done():
+			close(s.cancelled)
+			return
+		}
+	}
+	w.Write([]byte(fmt.Sprintf("answer %v", first)))
+}
+
+func TestHedgedGetWins(t *testing.T) {
+	fmt.Println("TestHedgedGetWins")
+	s := &slowFirstServer{slow: 2 * time.Second, cancelled: make(chan struct{})}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	client := NewHTTPClient(WithHedging(HedgePolicy{Delay: 20 * time.Millisecond}))
+	start := time.Now()
+	body, err := client.Get(context.Background(), server.URL)
+	if err != nil || string(body) != "answer false" {
+		t.Fatalf("unexpected response %q, %v", body, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("hedged request took %s", elapsed)
+	}
+	select {
+	case <-s.cancelled:
+	case <-time.After(time.Second):
+		t.Fatalf("the slow request was not cancelled")
+	}
+	if stats := client.HedgeStats(); stats != (HedgeStats{Requests: 1, Hedges: 1, Wins: 1}) {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	// POST requests are never hedged.
+	s2 := &slowFirstServer{slow: 100 * time.Millisecond, cancelled: make(chan struct{})}
+	server2 := httptest.NewServer(s2)
+	defer server2.Close()
+	if body, err := client.Post(context.Background(), server2.URL, []byte("{}")); err != nil || string(body) != "answer true" {
+		t.Fatalf("unexpected response %q, %v", body, err)
+	}
+}
+
+func TestHedgeBudget(t *testing.T) {
+	fmt.Println("TestHedgeBudget")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(WithHedging(HedgePolicy{Delay: time.Millisecond, Budget: 0.1}))
+	for i := 0; i < 10; i++ {
+		if _, err := client.Get(context.Background(), server.URL); err != nil {
+			t.Fatalf("failed to get: %v", err)
+		}
+	}
+	// One saved-up token, then one per ten requests.
+	stats := client.HedgeStats()
+	if stats.Requests != 10 || stats.Hedges != 2 || stats.Denied != 8 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestHedgePercentileDelay(t *testing.T) {
+	fmt.Println("TestHedgePercentileDelay")
+	h := &hedger{policy: HedgePolicy{Delay: time.Second, Percentile: 90, Budget: 1}}
+	if d := h.begin(); d != time.Second {
+		t.Fatalf("expected the fixed delay before samples, got %s", d)
+	}
+	for i := 1; i <= 100; i++ {
+		h.record(time.Duration(i) * time.Millisecond)
+	}
+	if d := h.begin(); d != 91*time.Millisecond {
+		t.Fatalf("expected the p90 delay, got %s", d)
+	}
+}
@@ -0,0 +1,163 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+const (
+	protocolHTTP2 = "h2"
+	protocolH2C   = "h2c"
+)
+
+// KeepAlive tunes how the client keeps connections open. Zero fields keep
+// the transport's settings.
+type KeepAlive struct {
+	// TCP is the interval between TCP keep-alive probes; negative disables
+	// them.
+	TCP time.Duration
+	// IdleTimeout closes connections left idle for longer.
+	IdleTimeout time.Duration
+	// MaxIdleConns and MaxIdleConnsPerHost cap the idle connections kept,
+	// in total and per host. They do not apply to forced HTTP/2, which
+	// keeps one connection per host.
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	// PingInterval sends an HTTP/2 ping on connections nothing was read
+	// from for this long, and PingTimeout closes them when it is not
+	// answered in time, 15s by default.
+	PingInterval time.Duration
+	PingTimeout  time.Duration
+}
+
+// WithHTTP2 sends every request over HTTP/2, failing with servers that do
+// not negotiate it. Requests to http URLs need WithH2C too. Forced HTTP/2
+// does not go through proxies.
+func WithHTTP2() Option {
+	return func(c *HTTPClient) {
+		if c.protocol == "" {
+			c.protocol = protocolHTTP2
+		}
+	}
+}
+
+// WithH2C sends requests to http URLs over cleartext HTTP/2 with prior
+// knowledge, as internal services expecting h2c require, and requests to
+// https URLs over HTTP/2.
+func WithH2C() Option {
+	return func(c *HTTPClient) {
+		c.protocol = protocolH2C
+	}
+}
+
+// WithKeepAlive tunes connection reuse. Without WithHTTP2 or WithH2C, a
+// ping interval also enables HTTP/2 for servers that negotiate it.
+func WithKeepAlive(keepAlive KeepAlive) Option {
+	return func(c *HTTPClient) {
+		c.keepAlive = &keepAlive
+	}
+}
+
+// baseTransport builds the transport requests are finally sent with. The
+// protocol and keep-alive options apply to *http.Transport only; other
+// transports are used as they are.
+func (c *HTTPClient) baseTransport() http.RoundTripper {
+	transport := c.Transport()
+	base, ok := transport.(*http.Transport)
+	if !ok || c.protocol == "" && c.keepAlive == nil {
+		return transport
+	}
+	t := base.Clone()
+	var keepAlive KeepAlive
+	if c.keepAlive != nil {
+		keepAlive = *c.keepAlive
+	}
+	if keepAlive.TCP != 0 {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: keepAlive.TCP}
+		t.DialContext = dialer.DialContext
+	}
+	if keepAlive.IdleTimeout > 0 {
+		t.IdleConnTimeout = keepAlive.IdleTimeout
+	}
+	if keepAlive.MaxIdleConns > 0 {
+		t.MaxIdleConns = keepAlive.MaxIdleConns
+	}
+	if keepAlive.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = keepAlive.MaxIdleConnsPerHost
+	}
+
+	if c.protocol == "" {
+		if keepAlive.PingInterval > 0 {
+			t.ForceAttemptHTTP2 = true
+			// Only fails for transports already set up for HTTP/2, which
+			// then keep their pings.
+			if h2, err := http2.ConfigureTransports(t); err == nil {
+				h2.ReadIdleTimeout = keepAlive.PingInterval
+				h2.PingTimeout = keepAlive.PingTimeout
+			}
+		}
+		return t
+	}
+
+	dial := t.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: 30 * time.Second}).DialContext
+	}
+	newH2 := func() *http2.Transport {
+		return &http2.Transport{
+			TLSClientConfig:    t.TLSClientConfig,
+			DisableCompression: t.DisableCompression,
+			IdleConnTimeout:    t.IdleConnTimeout,
+			ReadIdleTimeout:    keepAlive.PingInterval,
+			PingTimeout:        keepAlive.PingTimeout,
+		}
+	}
+	h2 := newH2()
+	// Dial with the transport's dialer, and so with its TCP keep-alive. The
+	// handshake is reported to the request's trace, as http.Transport does.
+	h2.DialTLSContext = func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		tlsConn := tls.Client(conn, cfg)
+		trace := httptrace.ContextClientTrace(ctx)
+		if trace != nil && trace.TLSHandshakeStart != nil {
+			trace.TLSHandshakeStart()
+		}
+		err = tlsConn.HandshakeContext(ctx)
+		if trace != nil && trace.TLSHandshakeDone != nil {
+			trace.TLSHandshakeDone(tlsConn.ConnectionState(), err)
+		}
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if p := tlsConn.ConnectionState().NegotiatedProtocol; p != http2.NextProtoTLS {
+			conn.Close()
+			return nil, fmt.Errorf("http2: unexpected ALPN protocol %q; want %q", p, http2.NextProtoTLS)
+		}
+		return tlsConn, nil
+	}
+	if c.protocol == protocolHTTP2 {
+		return h2
+	}
+	h2c := newH2()
+	h2c.AllowHTTP = true
+	h2c.DialTLSContext = func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+		return dial(ctx, network, addr)
+	}
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Scheme == "http" {
+			return h2c.RoundTrip(req)
+		}
+		return h2.RoundTrip(req)
+	})
+}
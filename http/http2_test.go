@@ -0,0 +1,108 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func protoHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+}
+
+func TestH2C(t *testing.T) {
+	fmt.Println("TestH2C")
+	server := httptest.NewServer(h2c.NewHandler(protoHandler(), &http2.Server{}))
+	defer server.Close()
+	ctx := context.Background()
+
+	body, err := NewHTTPClient().GetWithHeader(ctx, server.URL, nil)
+	if err != nil || string(body) != "HTTP/1.1" {
+		t.Fatalf("expected HTTP/1.1 by default, got %q, %v", body, err)
+	}
+	body, err = NewHTTPClient(WithH2C()).GetWithHeader(ctx, server.URL, nil)
+	if err != nil || string(body) != "HTTP/2.0" {
+		t.Fatalf("expected h2c, got %q, %v", body, err)
+	}
+}
+
+func TestWithHTTP2(t *testing.T) {
+	fmt.Println("TestWithHTTP2")
+	server := httptest.NewUnstartedServer(protoHandler())
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	ctx := context.Background()
+
+	client := NewHTTPClient(WithTransport(server.Client().Transport), WithHTTP2())
+	body, err := client.GetWithHeader(ctx, server.URL, nil)
+	if err != nil || string(body) != "HTTP/2.0" {
+		t.Fatalf("expected HTTP/2, got %q, %v", body, err)
+	}
+
+	// Connections are dialed with the transport's dialer.
+	var dials int32
+	base := server.Client().Transport.(*http.Transport).Clone()
+	base.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		atomic.AddInt32(&dials, 1)
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	}
+	client = NewHTTPClient(WithTransport(base), WithHTTP2(), WithTimings())
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := client.DoV2(ctx, req)
+	if err != nil || string(resp.Body) != "HTTP/2.0" || atomic.LoadInt32(&dials) != 1 {
+		t.Fatalf("expected HTTP/2 over the transport's dialer, got %+v, %d dials, %v", resp, dials, err)
+	}
+	// The handshake the dialer does is timed.
+	if resp.Timings == nil || resp.Timings.TLS <= 0 {
+		t.Fatalf("expected the TLS handshake to be timed, got %+v", resp.Timings)
+	}
+
+	// Forced HTTP/2 fails with servers that only speak HTTP/1.1.
+	h1 := httptest.NewUnstartedServer(protoHandler())
+	h1.Config.ErrorLog = log.New(io.Discard, "", 0)
+	h1.StartTLS()
+	defer h1.Close()
+	client = NewHTTPClient(WithTransport(h1.Client().Transport), WithHTTP2())
+	if _, err := client.GetWithHeader(ctx, h1.URL, nil); err == nil {
+		t.Fatalf("expected HTTP/2 to be required")
+	}
+}
+
+func TestWithKeepAlive(t *testing.T) {
+	fmt.Println("TestWithKeepAlive")
+	client := NewHTTPClient(WithKeepAlive(KeepAlive{IdleTimeout: time.Minute, MaxIdleConnsPerHost: 32}))
+	transport, ok := client.baseTransport().(*http.Transport)
+	if !ok || transport == http.DefaultTransport || transport.IdleConnTimeout != time.Minute || transport.MaxIdleConnsPerHost != 32 {
+		t.Fatalf("unexpected transport %+v", transport)
+	}
+	if http.DefaultTransport.(*http.Transport).MaxIdleConnsPerHost == 32 {
+		t.Fatalf("default transport was modified")
+	}
+
+	// A ping interval enables HTTP/2 where servers negotiate it.
+	server := httptest.NewUnstartedServer(protoHandler())
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	base := server.Client().Transport.(*http.Transport).Clone()
+	base.ForceAttemptHTTP2 = false
+	base.TLSNextProto = nil
+	client = NewHTTPClient(WithTransport(base), WithKeepAlive(KeepAlive{PingInterval: time.Second}))
+	body, err := client.GetWithHeader(context.Background(), server.URL, nil)
+	if err != nil || string(body) != "HTTP/2.0" {
+		t.Fatalf("expected HTTP/2, got %q, %v", body, err)
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
-	"time"
 )
 
 type HTTPRequester interface {
@@ -23,60 +22,371 @@ type HTTPRequester interface {
 }
 
 type HTTPClient struct {
+	coalescer   *coalescer
+	codecs      *CodecRegistry
+	contentType string
+	transport   http.RoundTripper
+	middleware  []Middleware
+	client      *http.Client
+
+	redirectPolicies []RedirectPolicy
+	sensitiveHeaders []string
+	hedger           *hedger
+	schemas          *SchemaRegistry
+	schemaWarn       func(*SchemaError)
+	metrics          *Metrics
+	protocol         string
+	keepAlive        *KeepAlive
+	tracer           *tracer
 }
 
-func NewHTTPClient() *HTTPClient {
-	return &HTTPClient{}
+// Option configures an HTTPClient.
+type Option func(*HTTPClient)
+
+// Middleware wraps the transport that sends the client's requests, e.g. to
+// record, sign or modify them.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts a function to http.RoundTripper, for middleware.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// WithTransport sets the transport requests are finally sent with,
+// http.DefaultTransport by default.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *HTTPClient) {
+		c.transport = transport
+	}
+}
+
+// WithMiddleware adds middleware around the transport. The first one given
+// sees requests first and responses last.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(c *HTTPClient) {
+		c.middleware = append(c.middleware, middleware...)
+	}
+}
+
+// WithCoalescing makes concurrent identical GET requests share a single upstream call.
+// Requests are considered identical when method, URL and the values of the given headers match.
+func WithCoalescing(headers ...string) Option {
+	return func(c *HTTPClient) {
+		c.coalescer = newCoalescer(headers)
+	}
+}
+
+// WithCodecs replaces the codecs used by GetDecoded and SendEncoded, which
+// default to DefaultCodecs().
+func WithCodecs(codecs *CodecRegistry) Option {
+	return func(c *HTTPClient) {
+		c.codecs = codecs
+	}
+}
+
+// WithContentType sets the media type SendEncoded encodes request bodies with
+// when the headers do not name one. The default is application/json.
+func WithContentType(contentType string) Option {
+	return func(c *HTTPClient) {
+		c.contentType = contentType
+	}
+}
+
+func NewHTTPClient(opts ...Option) *HTTPClient {
+	c := &HTTPClient{codecs: DefaultCodecs(), contentType: JSONContentType}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	transport := c.baseTransport()
+	if c.tracer != nil {
+		c.tracer.next = transport
+		transport = c.tracer
+	}
+	// Hedging sits below the middleware, which sees one request however
+	// many are sent.
+	if c.hedger != nil {
+		c.hedger.next = transport
+		transport = c.hedger
+	}
+	// Metrics sit between the middleware and hedging, so they count every
+	// attempt the middleware makes once.
+	if c.metrics != nil {
+		transport = c.metrics.transport(transport)
+		if c.hedger != nil {
+			c.metrics.addHedger(c.hedger)
+		}
+	}
+	transport = c.wrap(transport)
+	if c.schemas != nil {
+		transport = c.validating(transport)
+	}
+	c.client = &http.Client{Transport: transport, CheckRedirect: c.checkRedirect}
+	return c
+}
+
+// wrap puts the client's middleware around transport.
+func (c *HTTPClient) wrap(transport http.RoundTripper) http.RoundTripper {
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		transport = c.middleware[i](transport)
+	}
+	return transport
+}
+
+// Transport returns the transport requests are finally sent with, below the
+// middleware.
+func (c *HTTPClient) Transport() http.RoundTripper {
+	if c.transport == nil {
+		return http.DefaultTransport
+	}
+	return c.transport
+}
+
+// Prepare passes req through the client's middleware without sending it and
+// returns the request that would have been sent, e.g. to authorize a
+// WebSocket handshake the same way. The middleware sees a 101 Switching
+// Protocols response, so middleware that authorizes only after a challenge,
+// like digest, adds nothing.
+func (c *HTTPClient) Prepare(req *http.Request) (*http.Request, error) {
+	var prepared *http.Request
+	capture := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		prepared = req
+		return &http.Response{
+			Status:     "101 Switching Protocols",
+			StatusCode: http.StatusSwitchingProtocols,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{},
+			Body:       http.NoBody,
+			Request:    req,
+		}, nil
+	})
+	resp, err := c.wrap(capture).RoundTrip(req)
+	if err != nil {
+		return nil, fmt.Errorf("prepare request failed: %s", err)
+	}
+	resp.Body.Close()
+	if prepared == nil {
+		return nil, fmt.Errorf("prepare request failed: middleware did not send it")
+	}
+	return prepared, nil
+}
+
+// httpClient returns the client requests are sent with. Clients not created by
+// NewHTTPClient send with a plain http.Client.
+func (c *HTTPClient) httpClient() *http.Client {
+	if c.client == nil {
+		return &http.Client{}
+	}
+	return c.client
 }
 
 func (c *HTTPClient) Get(ctx context.Context, url string) ([]byte, error) {
-	return HttpGet(ctx, url)
+	if c.coalescer != nil {
+		return c.coalescer.do(ctx, c.coalescer.key(http.MethodGet, url, nil), func(ctx context.Context) ([]byte, error) {
+			return httpGet(ctx, c.httpClient(), url)
+		})
+	}
+	return httpGet(ctx, c.httpClient(), url)
 }
 
 func (c *HTTPClient) GetWithHeader(ctx context.Context, url string, headers map[string]string) ([]byte, error) {
-	return HttpGetWithHeader(ctx, url, headers)
+	if c.coalescer != nil {
+		return c.coalescer.do(ctx, c.coalescer.key(http.MethodGet, url, headers), func(ctx context.Context) ([]byte, error) {
+			return httpGetWithHeader(ctx, c.httpClient(), url, headers)
+		})
+	}
+	return httpGetWithHeader(ctx, c.httpClient(), url, headers)
 }
 
 func (c *HTTPClient) DeleteWithHeader(ctx context.Context, url string, headers map[string]string) ([]byte, error) {
-	return HttpDeleteWithHeader(ctx, url, headers)
+	return httpDeleteWithHeader(ctx, c.httpClient(), url, headers)
 }
 
 func (c *HTTPClient) PutWithHeader(ctx context.Context, url string, request []byte,
 	headers map[string]string) ([]byte, error) {
-	return HttpPutWithHeader(ctx, url, request, headers)
+	return httpPostOrPatchWithHeader(ctx, c.httpClient(), url, http.MethodPut, request, headers)
 }
 
 func (c *HTTPClient) PostWithHeader(ctx context.Context, url string, request []byte,
 	headers map[string]string) ([]byte, error) {
-	return HttpPostWithHeader(ctx, url, request, headers)
+	return httpPostOrPatchWithHeader(ctx, c.httpClient(), url, http.MethodPost, request, headers)
 }
 
 func (c *HTTPClient) PatchWithHeader(ctx context.Context, url string, request []byte,
 	headers map[string]string) ([]byte, error) {
-	return HttpPatchWithHeader(ctx, url, request, headers)
+	return httpPostOrPatchWithHeader(ctx, c.httpClient(), url, http.MethodPatch, request, headers)
 }
 
 func (c *HTTPClient) Post(ctx context.Context, url string, request []byte) ([]byte, error) {
-	return HttpPost(ctx, url, request)
+	return httpPostOrPatchWithHeader(ctx, c.httpClient(), url, http.MethodPost, request, nil)
 }
 
 func (c *HTTPClient) PostURLEncoded(ctx context.Context, apiUrl string, data url.Values) ([]byte, error) {
-	return HttpPostUrlEncoded(ctx, apiUrl, data)
+	return httpPostUrlEncoded(ctx, c.httpClient(), apiUrl, data)
+}
+
+// Do sends a prepared request, such as one built by ParseCurl, and returns the response body.
+func (c *HTTPClient) Do(ctx context.Context, req *http.Request) ([]byte, error) {
+	resp, err := c.DoV2(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return resp.Body, newStatusError(resp.StatusCode, resp.Header, resp.Body)
+	}
+
+	return resp.Body, nil
+}
+
+// DoV2 sends a prepared request and returns the response whatever its status
+// code, along with the redirects followed to get it.
+func (c *HTTPClient) DoV2(ctx context.Context, req *http.Request) (*HttpResponse, error) {
+	if route := RouteFromContext(req.Context()); route != "" && RouteFromContext(ctx) == "" {
+		ctx = WithRoute(ctx, route)
+	}
+	ctx, cancel := withRequestTimeout(ctx)
+	defer cancel()
+	ctx, redirects := withRedirects(ctx)
+	var timings *timingsHolder
+	if c.tracer != nil {
+		ctx, timings = withTimings(ctx)
+	}
+
+	reqWithTimeout := req.WithContext(ctx)
+	if err := replayableBody(reqWithTimeout); err != nil {
+		return nil, err
+	}
+
+	client := c.httpClient()
+	resp, err := client.Do(reqWithTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("send http request failed %w", err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response body failed: %s", err)
+	}
+
+	return &HttpResponse{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       bodyBytes,
+		Redirects:  *redirects,
+		Timings:    timings.get(),
+	}, nil
+}
+
+// Codecs returns the registry used by GetDecoded and SendEncoded, on which
+// custom codecs can be registered. It is nil for clients not made by
+// NewHTTPClient, which use the default codecs.
+func (c *HTTPClient) Codecs() *CodecRegistry {
+	return c.codecs
+}
+
+// defaultCodecs serves clients not made by NewHTTPClient.
+var defaultCodecs = DefaultCodecs()
+
+func (c *HTTPClient) codecRegistry() *CodecRegistry {
+	if c.codecs == nil {
+		return defaultCodecs
+	}
+	return c.codecs
+}
+
+// GetDecoded sends a GET request and decodes the response into out.
+func (c *HTTPClient) GetDecoded(ctx context.Context, url string, headers map[string]string, out interface{}) error {
+	return c.SendEncoded(ctx, http.MethodGet, url, nil, headers, out)
+}
+
+// SendEncoded encodes in with the codec for the Content-Type in headers, or the
+// client's default content type, and decodes the response into out with the
+// codec matching the response Content-Type. A nil in sends no body and a nil
+// out discards the response. Unless headers set Accept, the request accepts
+// every registered media type, preferring the request's.
+func (c *HTTPClient) SendEncoded(ctx context.Context, method string, url string, in interface{},
+	headers map[string]string, out interface{}) error {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	codecs := c.codecRegistry()
+	contentType := req.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = c.contentType
+	}
+	if contentType == "" {
+		contentType = JSONContentType
+	}
+	reqCodec, ok := codecs.Lookup(contentType)
+	if !ok {
+		return fmt.Errorf("no codec for content type %q", contentType)
+	}
+	if in != nil {
+		body, err := reqCodec.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("encode request body failed: %s", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+		req.ContentLength = int64(len(body))
+		if req.Header.Get("Content-Type") == "" {
+			req.Header.Set("Content-Type", reqCodec.ContentType())
+		}
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", codecs.Accept(reqCodec.ContentType()))
+	}
+
+	resp, err := c.DoV2(ctx, req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return newStatusError(resp.StatusCode, resp.Header, resp.Body)
+	}
+	if out == nil || len(resp.Body) == 0 {
+		return nil
+	}
+
+	respCodec := reqCodec
+	if respType := resp.Header.Get("Content-Type"); respType != "" {
+		if respCodec, ok = codecs.Lookup(respType); !ok {
+			return fmt.Errorf("no codec for response content type %q", respType)
+		}
+	}
+	if err := respCodec.Unmarshal(resp.Body, out); err != nil {
+		return fmt.Errorf("decode response body failed: %s", err)
+	}
+	return nil
 }
 
 func HttpGet(ctx context.Context, url string) ([]byte, error) {
+	return httpGet(ctx, &http.Client{}, url)
+}
+
+func httpGet(ctx context.Context, client *http.Client, url string) ([]byte, error) {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
-	req = req.WithContext(ctx)
-
-	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(withAttempts(ctx))
 
-	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("send http request failed %s", err)
+		return nil, fmt.Errorf("send http request failed %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -86,33 +396,34 @@ func HttpGet(ctx context.Context, url string) ([]byte, error) {
 	}
 
 	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
-		return nil, fmt.Errorf("response failed: %d, %s", resp.StatusCode, string(bodyBytes))
+		return nil, newStatusError(resp.StatusCode, resp.Header, bodyBytes)
 	}
 
 	return bodyBytes, nil
 }
 
 func HttpGetWithHeader(ctx context.Context, url string, headers map[string]string) ([]byte, error) {
+	return httpGetWithHeader(ctx, &http.Client{}, url, headers)
+}
+
+func httpGetWithHeader(ctx context.Context, client *http.Client, url string, headers map[string]string) ([]byte, error) {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-
 	for key, value := range headers {
 		req.Header.Set(key, value)
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	ctx, cancel := withRequestTimeout(ctx)
 	defer cancel()
 
 	reqWithTimeout := req.WithContext(ctx)
 
-	client := &http.Client{}
 	resp, err := client.Do(reqWithTimeout)
 	if err != nil {
-		return nil, fmt.Errorf("send http request failed %s\n", err)
+		return nil, fmt.Errorf("send http request failed %w\n", err)
 	}
 	defer resp.Body.Close()
 
@@ -122,15 +433,46 @@ func HttpGetWithHeader(ctx context.Context, url string, headers map[string]strin
 	}
 
 	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
-		return bodyBytes, fmt.Errorf("response failed: %d, %s", resp.StatusCode, string(bodyBytes))
+		return bodyBytes, newStatusError(resp.StatusCode, resp.Header, bodyBytes)
 	}
 
 	return bodyBytes, nil
 }
 
+// StatusError is returned when the server answers with a non-2xx status code.
+type StatusError struct {
+	StatusCode int
+	Body       []byte
+	// Problem is set when the body is an application/problem+json document.
+	Problem *Problem
+}
+
+func newStatusError(statusCode int, header http.Header, body []byte) *StatusError {
+	return &StatusError{StatusCode: statusCode, Body: body, Problem: parseProblem(header, body)}
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("response failed: %d, %s", e.StatusCode, string(e.Body))
+}
+
+// Unwrap exposes the problem details, if any, to errors.As.
+func (e *StatusError) Unwrap() error {
+	if e.Problem == nil {
+		return nil
+	}
+	return e.Problem
+}
+
 type HttpResponse struct {
 	StatusCode int
+	Header     http.Header
 	Body       []byte
+	// Redirects lists the redirects followed, oldest first. Only
+	// HTTPClient.DoV2 records them.
+	Redirects []Redirect
+	// Timings are the phases of the request that got the response, when
+	// the client was created WithTimings. Only HTTPClient.DoV2 records them.
+	Timings *Timings
 }
 
 func HttpGetWithHeaderV2(ctx context.Context, url string, headers map[string]string) (*HttpResponse, error) {
@@ -139,13 +481,11 @@ func HttpGetWithHeaderV2(ctx context.Context, url string, headers map[string]str
 		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-
 	for key, value := range headers {
 		req.Header.Set(key, value)
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	ctx, cancel := withRequestTimeout(ctx)
 	defer cancel()
 
 	reqWithTimeout := req.WithContext(ctx)
@@ -153,7 +493,7 @@ func HttpGetWithHeaderV2(ctx context.Context, url string, headers map[string]str
 	client := &http.Client{}
 	resp, err := client.Do(reqWithTimeout)
 	if err != nil {
-		return nil, fmt.Errorf("send http request failed %s", err)
+		return nil, fmt.Errorf("send http request failed %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -164,13 +504,14 @@ func HttpGetWithHeaderV2(ctx context.Context, url string, headers map[string]str
 
 	return &HttpResponse{
 		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
 		Body:       bodyBytes,
 	}, nil
 }
 
 // Send http POST or PATCH request with header.
 func httpPostOrPatchWithHeader(
-	ctx context.Context, url string, method string, request []byte, headers map[string]string,
+	ctx context.Context, client *http.Client, url string, method string, request []byte, headers map[string]string,
 ) ([]byte, error) {
 
 	buffer := bytes.NewBuffer(request)
@@ -184,12 +525,12 @@ func httpPostOrPatchWithHeader(
 		headers["Content-Type"] = "application/json"
 	}
 
-	return httpPostOrPatchWithHeaderBuffer(ctx, url, method, buffer, headers)
+	return httpPostOrPatchWithHeaderBuffer(ctx, client, url, method, buffer, headers)
 }
 
 // Send http POST or PATCH request with header.
 func httpPostOrPatchWithHeaderBuffer(
-	ctx context.Context, url string, method string, buffer *bytes.Buffer, headers map[string]string,
+	ctx context.Context, client *http.Client, url string, method string, buffer *bytes.Buffer, headers map[string]string,
 ) ([]byte, error) {
 	req, err := http.NewRequest(method, url, buffer)
 	if err != nil {
@@ -200,15 +541,14 @@ func httpPostOrPatchWithHeaderBuffer(
 		req.Header.Set(key, value)
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	ctx, cancel := withRequestTimeout(ctx)
 	defer cancel()
 
 	reqWithTimeout := req.WithContext(ctx)
 
-	client := &http.Client{}
 	resp, err := client.Do(reqWithTimeout)
 	if err != nil {
-		return nil, fmt.Errorf("send http request failed %s", err)
+		return nil, fmt.Errorf("send http request failed %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -218,13 +558,17 @@ func httpPostOrPatchWithHeaderBuffer(
 	}
 
 	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
-		return bodyBytes, fmt.Errorf("response failed: %d, %s", resp.StatusCode, string(bodyBytes))
+		return bodyBytes, newStatusError(resp.StatusCode, resp.Header, bodyBytes)
 	}
 
 	return bodyBytes, nil
 }
 
 func HttpDeleteWithHeader(ctx context.Context, url string, headers map[string]string) ([]byte, error) {
+	return httpDeleteWithHeader(ctx, &http.Client{}, url, headers)
+}
+
+func httpDeleteWithHeader(ctx context.Context, client *http.Client, url string, headers map[string]string) ([]byte, error) {
 	req, err := http.NewRequest("DELETE", url, nil)
 	if err != nil {
 		return nil, err
@@ -234,15 +578,14 @@ func HttpDeleteWithHeader(ctx context.Context, url string, headers map[string]st
 		req.Header.Set(key, value)
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	ctx, cancel := withRequestTimeout(ctx)
 	defer cancel()
 
 	reqWithTimeout := req.WithContext(ctx)
 
-	client := &http.Client{}
 	resp, err := client.Do(reqWithTimeout)
 	if err != nil {
-		return nil, fmt.Errorf("send http request failed %s", err)
+		return nil, fmt.Errorf("send http request failed %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -252,34 +595,38 @@ func HttpDeleteWithHeader(ctx context.Context, url string, headers map[string]st
 	}
 
 	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
-		return bodyBytes, fmt.Errorf("response failed: %d, %s", resp.StatusCode, string(bodyBytes))
+		return bodyBytes, newStatusError(resp.StatusCode, resp.Header, bodyBytes)
 	}
 
 	return bodyBytes, nil
 }
 
 func HttpPutWithHeader(ctx context.Context, url string, request []byte, headers map[string]string) ([]byte, error) {
-	return httpPostOrPatchWithHeader(ctx, url, http.MethodPut, request, headers)
+	return httpPostOrPatchWithHeader(ctx, &http.Client{}, url, http.MethodPut, request, headers)
 }
 
 func HttpPostWithHeader(ctx context.Context, url string, request []byte, headers map[string]string) ([]byte, error) {
-	return httpPostOrPatchWithHeader(ctx, url, http.MethodPost, request, headers)
+	return httpPostOrPatchWithHeader(ctx, &http.Client{}, url, http.MethodPost, request, headers)
 }
 
 func HttpPostWithHeaderBuffer(ctx context.Context, url string, request *bytes.Buffer,
 	headers map[string]string) ([]byte, error) {
-	return httpPostOrPatchWithHeaderBuffer(ctx, url, http.MethodPost, request, headers)
+	return httpPostOrPatchWithHeaderBuffer(ctx, &http.Client{}, url, http.MethodPost, request, headers)
 }
 
 func HttpPatchWithHeader(ctx context.Context, url string, request []byte, headers map[string]string) ([]byte, error) {
-	return httpPostOrPatchWithHeader(ctx, url, http.MethodPatch, request, headers)
+	return httpPostOrPatchWithHeader(ctx, &http.Client{}, url, http.MethodPatch, request, headers)
 }
 
 func HttpPost(ctx context.Context, url string, request []byte) ([]byte, error) {
-	return httpPostOrPatchWithHeader(ctx, url, http.MethodPost, request, nil)
+	return httpPostOrPatchWithHeader(ctx, &http.Client{}, url, http.MethodPost, request, nil)
 }
 
 func HttpPostUrlEncoded(ctx context.Context, apiUrl string, data url.Values) ([]byte, error) {
+	return httpPostUrlEncoded(ctx, &http.Client{}, apiUrl, data)
+}
+
+func httpPostUrlEncoded(ctx context.Context, client *http.Client, apiUrl string, data url.Values) ([]byte, error) {
 	req, err := http.NewRequest("POST", apiUrl, strings.NewReader(data.Encode()))
 	if err != nil {
 		return nil, err
@@ -288,10 +635,9 @@ func HttpPostUrlEncoded(ctx context.Context, apiUrl string, data url.Values) ([]
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded;charset=utf-8")
 
-	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("send http request failed %s\n", err)
+		return nil, fmt.Errorf("send http request failed %w\n", err)
 	}
 	defer resp.Body.Close()
 
@@ -301,7 +647,7 @@ func HttpPostUrlEncoded(ctx context.Context, apiUrl string, data url.Values) ([]
 	}
 
 	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
-		return nil, fmt.Errorf("response failed: %d, %s", resp.StatusCode, string(bodyBytes))
+		return nil, newStatusError(resp.StatusCode, resp.Header, bodyBytes)
 	}
 
 	return bodyBytes, nil
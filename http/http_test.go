@@ -2,8 +2,13 @@ package http
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+
+	"http/shipos/shipostest"
 )
 
 func TestHttpGet(t *testing.T) {
@@ -11,10 +16,15 @@ func TestHttpGet(t *testing.T) {
 	ctx, cancelCtx := context.WithCancel(context.Background())
 	defer cancelCtx()
 
+	server := shipostest.NewServer("shipos_959", "194aa9287722361e0e9e22a950c2b98a")
+	defer server.Close()
+	server.AddOrder("odr-0878cf33-0fad-4834-a99e-253569cdf606",
+		shipostest.Label{TrackingNumber: "SP0878CF33", Content: shipostest.PDF("SP0878CF33")})
+
 	client := NewHTTPClient()
 
 	resp, err := client.PostWithHeader(ctx,
-		"https://api.shipos.cn/api/getLabels.php",
+		server.BaseURL()+"/getLabels.php",
 		[]byte(`{"orderid": "odr-0878cf33-0fad-4834-a99e-253569cdf606"}`),
 		map[string]string{"appkey": "shipos_959", "appsecret": "194aa9287722361e0e9e22a950c2b98a"})
 	if err != nil {
@@ -24,3 +34,77 @@ func TestHttpGet(t *testing.T) {
 	fmt.Println(string(resp))
 
 }
+
+func TestHttpGetWithHeaderStatusError(t *testing.T) {
+	fmt.Println("TestHttpGetWithHeaderStatusError")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("missing"))
+	}))
+	defer server.Close()
+
+	_, err := HttpGetWithHeader(context.Background(), server.URL, nil)
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("expected StatusError, got %v", err)
+	}
+	if statusErr.StatusCode != http.StatusNotFound || string(statusErr.Body) != "missing" {
+		t.Fatalf("unexpected status error %+v", statusErr)
+	}
+	if err.Error() != "response failed: 404, missing" {
+		t.Fatalf("unexpected error message %q", err.Error())
+	}
+}
+
+func TestWithMiddlewareOrder(t *testing.T) {
+	fmt.Println("TestWithMiddlewareOrder")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Trace")))
+	}))
+	defer server.Close()
+
+	tag := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				req.Header.Add("X-Trace", name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+	client := NewHTTPClient(WithMiddleware(tag("outer")), WithMiddleware(tag("inner")))
+
+	body, err := client.PostWithHeader(context.Background(), server.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to post: %v", err)
+	}
+	if string(body) != "outer" {
+		t.Fatalf("expected the outer middleware first, got %q", body)
+	}
+}
+
+func TestPrepare(t *testing.T) {
+	fmt.Println("TestPrepare")
+	sent := false
+	client := NewHTTPClient(
+		WithTransport(RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			sent = true
+			return nil, errors.New("unexpected request")
+		})),
+		WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				req = req.Clone(req.Context())
+				req.Header.Set("Authorization", "Bearer token")
+				return next.RoundTrip(req)
+			})
+		}))
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/stream", nil)
+	prepared, err := client.Prepare(req)
+	if err != nil || sent {
+		t.Fatalf("failed to prepare without sending: %v", err)
+	}
+	if prepared.Header.Get("Authorization") != "Bearer token" || req.Header.Get("Authorization") != "" {
+		t.Fatalf("unexpected headers %v", prepared.Header)
+	}
+}
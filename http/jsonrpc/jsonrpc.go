@@ -0,0 +1,200 @@
+// Package jsonrpc is a JSON-RPC 2.0 client on top of the http package's HTTPRequester.
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+
+	httpclient "http"
+)
+
+const Version = "2.0"
+
+// Error codes defined by the JSON-RPC 2.0 specification.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// Error is a JSON-RPC error object.
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message)
+}
+
+// Request is a JSON-RPC request envelope. Notifications have no ID.
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      *uint64         `json:"id,omitempty"`
+}
+
+// Response is a JSON-RPC response envelope.
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+	ID      *uint64         `json:"id"`
+}
+
+// Client calls methods of a single JSON-RPC endpoint.
+type Client struct {
+	endpoint  string
+	requester httpclient.HTTPRequester
+	nextID    uint64
+
+	// Headers are added to every request, e.g. for authorization.
+	Headers map[string]string
+}
+
+func NewClient(endpoint string, requester httpclient.HTTPRequester) *Client {
+	return &Client{endpoint: endpoint, requester: requester}
+}
+
+// Call invokes method with params and decodes its result into result, which may be nil.
+// RPC error objects are returned as *Error. A response for another request id fails.
+func (c *Client) Call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	req, err := c.newRequest(method, params, true)
+	if err != nil {
+		return err
+	}
+	body, err := c.post(ctx, req)
+	if err != nil {
+		return err
+	}
+	var resp Response
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("decode jsonrpc response failed: %s", err)
+	}
+	if resp.ID == nil && resp.Error != nil {
+		// Servers that cannot read the request answer errors with a null id.
+		return resp.Error
+	}
+	if resp.ID == nil {
+		return fmt.Errorf("jsonrpc response has no id, want %d", *req.ID)
+	}
+	if *resp.ID != *req.ID {
+		return fmt.Errorf("jsonrpc response id %d does not match request id %d", *resp.ID, *req.ID)
+	}
+	return resp.decode(result)
+}
+
+// Notify invokes method without waiting for a result.
+func (c *Client) Notify(ctx context.Context, method string, params interface{}) error {
+	req, err := c.newRequest(method, params, false)
+	if err != nil {
+		return err
+	}
+	_, err = c.post(ctx, req)
+	return err
+}
+
+// BatchCall is one element of a batch. Err is set once the batch completes.
+type BatchCall struct {
+	Method string
+	Params interface{}
+	// Result receives the decoded result; it may be nil.
+	Result interface{}
+	// Notify sends the call as a notification, which gets no response.
+	Notify bool
+	Err    error
+}
+
+// Batch sends calls in a single request and correlates responses by ID.
+// Per-call failures are reported in each call's Err field.
+func (c *Client) Batch(ctx context.Context, calls []*BatchCall) error {
+	reqs := make([]*Request, len(calls))
+	byID := make(map[uint64]*BatchCall)
+	for i, call := range calls {
+		req, err := c.newRequest(call.Method, call.Params, !call.Notify)
+		if err != nil {
+			return err
+		}
+		reqs[i] = req
+		if req.ID != nil {
+			byID[*req.ID] = call
+		}
+	}
+
+	body, err := c.post(ctx, reqs)
+	if err != nil {
+		return err
+	}
+	if len(byID) == 0 {
+		return nil
+	}
+
+	var resps []Response
+	if err := json.Unmarshal(body, &resps); err != nil {
+		// A failed batch may be answered with a single error response.
+		var resp Response
+		if json.Unmarshal(body, &resp) == nil && resp.Error != nil {
+			return resp.Error
+		}
+		return fmt.Errorf("decode jsonrpc batch response failed: %s", err)
+	}
+	for _, resp := range resps {
+		if resp.ID == nil {
+			continue
+		}
+		if call, ok := byID[*resp.ID]; ok {
+			call.Err = resp.decode(call.Result)
+			delete(byID, *resp.ID)
+		}
+	}
+	for id, call := range byID {
+		call.Err = fmt.Errorf("no response for request id %d", id)
+	}
+	return nil
+}
+
+func (c *Client) newRequest(method string, params interface{}, withID bool) (*Request, error) {
+	req := &Request{JSONRPC: Version, Method: method}
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("encode jsonrpc params failed: %s", err)
+		}
+		req.Params = raw
+	}
+	if withID {
+		id := atomic.AddUint64(&c.nextID, 1)
+		req.ID = &id
+	}
+	return req, nil
+}
+
+func (c *Client) post(ctx context.Context, payload interface{}) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("encode jsonrpc request failed: %s", err)
+	}
+	headers := map[string]string{"Content-Type": "application/json", "Accept": "application/json"}
+	for k, v := range c.Headers {
+		headers[k] = v
+	}
+	return c.requester.PostWithHeader(ctx, c.endpoint, data, headers)
+}
+
+func (r *Response) decode(result interface{}) error {
+	if r.Error != nil {
+		return r.Error
+	}
+	if result != nil && len(r.Result) > 0 {
+		if err := json.Unmarshal(r.Result, result); err != nil {
+			return fmt.Errorf("decode jsonrpc result failed: %s", err)
+		}
+	}
+	return nil
+}
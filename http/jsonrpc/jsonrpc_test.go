@@ -0,0 +1,163 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	httpclient "http"
+	"http/jsonrpc"
+	"http/jsonrpc/jsonrpctest"
+)
+
+type addParams struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+func newServer() *jsonrpctest.Server {
+	server := jsonrpctest.NewServer()
+	server.Handle("add", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var p addParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &jsonrpc.Error{Code: jsonrpc.CodeInvalidParams, Message: err.Error()}
+		}
+		return p.A + p.B, nil
+	})
+	server.Handle("fail", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return nil, &jsonrpc.Error{Code: 42, Message: "failed on purpose", Data: json.RawMessage(`{"retry":false}`)}
+	})
+	server.Handle("log", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return nil, nil
+	})
+	return server
+}
+
+func TestCall(t *testing.T) {
+	fmt.Println("TestCall")
+	server := newServer()
+	defer server.Close()
+
+	client := jsonrpc.NewClient(server.URL, httpclient.NewHTTPClient())
+	var sum int
+	if err := client.Call(context.Background(), "add", addParams{A: 1, B: 2}, &sum); err != nil {
+		t.Fatalf("failed to call: %v", err)
+	}
+	if sum != 3 {
+		t.Fatalf("expected 3, got %d", sum)
+	}
+}
+
+func TestCallError(t *testing.T) {
+	fmt.Println("TestCallError")
+	server := newServer()
+	defer server.Close()
+
+	client := jsonrpc.NewClient(server.URL, httpclient.NewHTTPClient())
+
+	var rpcErr *jsonrpc.Error
+	err := client.Call(context.Background(), "fail", nil, nil)
+	if !errors.As(err, &rpcErr) || rpcErr.Code != 42 || string(rpcErr.Data) != `{"retry":false}` {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	err = client.Call(context.Background(), "missing", nil, nil)
+	if !errors.As(err, &rpcErr) || rpcErr.Code != jsonrpc.CodeMethodNotFound {
+		t.Fatalf("expected method not found, got %v", err)
+	}
+}
+
+func TestCallChecksResponseID(t *testing.T) {
+	fmt.Println("TestCallChecksResponseID")
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+	client := jsonrpc.NewClient(server.URL, httpclient.NewHTTPClient())
+
+	var sum int
+	body = `{"jsonrpc": "2.0", "result": 3, "id": 7}`
+	if err := client.Call(context.Background(), "add", addParams{A: 1, B: 2}, &sum); err == nil ||
+		err.Error() != "jsonrpc response id 7 does not match request id 1" {
+		t.Fatalf("expected a mismatched id to fail, got %v", err)
+	}
+	body = `{"jsonrpc": "2.0", "result": 3}`
+	if err := client.Call(context.Background(), "add", addParams{A: 1, B: 2}, &sum); err == nil ||
+		err.Error() != "jsonrpc response has no id, want 2" {
+		t.Fatalf("expected a missing id to fail, got %v", err)
+	}
+	if sum != 0 {
+		t.Fatalf("expected no result to be decoded, got %d", sum)
+	}
+
+	// Errors the server could not correlate carry a null id.
+	body = `{"jsonrpc": "2.0", "error": {"code": -32700, "message": "parse error"}, "id": null}`
+	var rpcErr *jsonrpc.Error
+	if err := client.Call(context.Background(), "add", nil, nil); !errors.As(err, &rpcErr) || rpcErr.Code != jsonrpc.CodeParseError {
+		t.Fatalf("expected a parse error, got %v", err)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	fmt.Println("TestNotify")
+	server := newServer()
+	defer server.Close()
+
+	client := jsonrpc.NewClient(server.URL, httpclient.NewHTTPClient())
+	if err := client.Notify(context.Background(), "log", map[string]string{"msg": "hello"}); err != nil {
+		t.Fatalf("failed to notify: %v", err)
+	}
+	got := server.Notifications()
+	if len(got) != 1 || got[0].Method != "log" || string(got[0].Params) != `{"msg":"hello"}` {
+		t.Fatalf("unexpected notifications %+v", got)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	fmt.Println("TestBatch")
+	server := newServer()
+	defer server.Close()
+
+	client := jsonrpc.NewClient(server.URL, httpclient.NewHTTPClient())
+	var first, second int
+	calls := []*jsonrpc.BatchCall{
+		{Method: "add", Params: addParams{A: 1, B: 1}, Result: &first},
+		{Method: "log", Params: []string{"ignored"}, Notify: true},
+		{Method: "fail"},
+		{Method: "add", Params: addParams{A: 2, B: 3}, Result: &second},
+	}
+	if err := client.Batch(context.Background(), calls); err != nil {
+		t.Fatalf("failed to send batch: %v", err)
+	}
+	if calls[0].Err != nil || first != 2 || calls[3].Err != nil || second != 5 {
+		t.Fatalf("unexpected results %d, %d, %v, %v", first, second, calls[0].Err, calls[3].Err)
+	}
+	if calls[1].Err != nil {
+		t.Fatalf("expected notification to succeed, got %v", calls[1].Err)
+	}
+	var rpcErr *jsonrpc.Error
+	if !errors.As(calls[2].Err, &rpcErr) || rpcErr.Code != 42 {
+		t.Fatalf("expected rpc error, got %v", calls[2].Err)
+	}
+}
+
+func TestBatchOfNotifications(t *testing.T) {
+	fmt.Println("TestBatchOfNotifications")
+	server := newServer()
+	defer server.Close()
+
+	client := jsonrpc.NewClient(server.URL, httpclient.NewHTTPClient())
+	calls := []*jsonrpc.BatchCall{{Method: "log", Notify: true}, {Method: "log", Notify: true}}
+	if err := client.Batch(context.Background(), calls); err != nil {
+		t.Fatalf("failed to send batch: %v", err)
+	}
+	if n := len(server.Notifications()); n != 2 {
+		t.Fatalf("expected 2 notifications, got %d", n)
+	}
+}
@@ -0,0 +1,138 @@
+// Package jsonrpctest provides a JSON-RPC 2.0 server for testing jsonrpc clients.
+package jsonrpctest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"http/jsonrpc"
+)
+
+// HandlerFunc serves one method. Returning a *jsonrpc.Error sends it as is;
+// any other error is reported as an internal error.
+type HandlerFunc func(ctx context.Context, params json.RawMessage) (interface{}, error)
+
+// Server is a running JSON-RPC server listening on a local address.
+type Server struct {
+	*httptest.Server
+
+	mu            sync.Mutex
+	handlers      map[string]HandlerFunc
+	notifications []jsonrpc.Request
+}
+
+// NewServer starts a server with no methods registered. Callers should Close it.
+func NewServer() *Server {
+	s := &Server{handlers: make(map[string]HandlerFunc)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Handle registers the handler of method.
+func (s *Server) Handle(method string, h HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method] = h
+}
+
+// Notifications returns the notifications received so far.
+func (s *Server) Notifications() []jsonrpc.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]jsonrpc.Request, len(s.notifications))
+	copy(out, s.notifications)
+	return out
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	var body bytes.Buffer
+	if _, err := body.ReadFrom(r.Body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	data := bytes.TrimSpace(body.Bytes())
+
+	if len(data) > 0 && data[0] == '[' {
+		var reqs []jsonrpc.Request
+		if err := json.Unmarshal(data, &reqs); err != nil || len(reqs) == 0 {
+			writeJSON(w, errorResponse(nil, jsonrpc.CodeInvalidRequest, "invalid batch"))
+			return
+		}
+		var resps []*jsonrpc.Response
+		for i := range reqs {
+			if resp := s.dispatch(r.Context(), &reqs[i]); resp != nil {
+				resps = append(resps, resp)
+			}
+		}
+		if len(resps) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSON(w, resps)
+		return
+	}
+
+	var req jsonrpc.Request
+	if err := json.Unmarshal(data, &req); err != nil {
+		writeJSON(w, errorResponse(nil, jsonrpc.CodeParseError, "parse error"))
+		return
+	}
+	resp := s.dispatch(r.Context(), &req)
+	if resp == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+// dispatch runs a single request. It returns nil for notifications.
+func (s *Server) dispatch(ctx context.Context, req *jsonrpc.Request) *jsonrpc.Response {
+	if req.JSONRPC != jsonrpc.Version || req.Method == "" {
+		return errorResponse(req.ID, jsonrpc.CodeInvalidRequest, "invalid request")
+	}
+
+	s.mu.Lock()
+	h, ok := s.handlers[req.Method]
+	if req.ID == nil {
+		s.notifications = append(s.notifications, *req)
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		if req.ID == nil {
+			return nil
+		}
+		return errorResponse(req.ID, jsonrpc.CodeMethodNotFound, "method not found")
+	}
+
+	result, err := h(ctx, req.Params)
+	if req.ID == nil {
+		return nil
+	}
+	if err != nil {
+		var rpcErr *jsonrpc.Error
+		if errors.As(err, &rpcErr) {
+			return &jsonrpc.Response{JSONRPC: jsonrpc.Version, Error: rpcErr, ID: req.ID}
+		}
+		return errorResponse(req.ID, jsonrpc.CodeInternalError, err.Error())
+	}
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return errorResponse(req.ID, jsonrpc.CodeInternalError, err.Error())
+	}
+	return &jsonrpc.Response{JSONRPC: jsonrpc.Version, Result: raw, ID: req.ID}
+}
+
+func errorResponse(id *uint64, code int, message string) *jsonrpc.Response {
+	return &jsonrpc.Response{JSONRPC: jsonrpc.Version, Error: &jsonrpc.Error{Code: code, Message: message}, ID: id}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
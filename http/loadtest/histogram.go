@@ -0,0 +1,164 @@
+package loadtest
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// subBucketBits sets the histogram precision: every power-of-two range is split
+// into 2^subBucketBits linear buckets, bounding the error to about 1.5%.
+const subBucketBits = 6
+
+// linearLimit is the value below which every microsecond has its own bucket.
+const linearLimit = 2 << subBucketBits
+
+// Histogram records latencies with bounded memory and relative precision.
+// Values are tracked in microseconds. It is not safe for concurrent use.
+type Histogram struct {
+	counts []uint64
+	total  uint64
+	sum    time.Duration
+	min    time.Duration
+	max    time.Duration
+}
+
+func bucketIndex(us uint64) int {
+	if us < linearLimit {
+		return int(us)
+	}
+	msb := bits.Len64(us) - 1
+	shift := msb - subBucketBits
+	top := us >> uint(shift)
+	return linearLimit + (msb-subBucketBits-1)<<subBucketBits + int(top) - 1<<subBucketBits
+}
+
+// bucketUpperBound returns the largest value, in microseconds, held by bucket i.
+func bucketUpperBound(i int) uint64 {
+	if i < linearLimit {
+		return uint64(i)
+	}
+	offset := i - linearLimit
+	msb := offset>>subBucketBits + subBucketBits + 1
+	top := uint64(offset&(1<<subBucketBits-1)) + 1<<subBucketBits
+	shift := uint(msb - subBucketBits)
+	return (top+1)<<shift - 1
+}
+
+// Record adds a latency to the histogram.
+func (h *Histogram) Record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	i := bucketIndex(uint64(d / time.Microsecond))
+	if i >= len(h.counts) {
+		grown := make([]uint64, i+1)
+		copy(grown, h.counts)
+		h.counts = grown
+	}
+	h.counts[i]++
+	if h.total == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.total++
+	h.sum += d
+}
+
+// Merge adds the values recorded by other.
+func (h *Histogram) Merge(other *Histogram) {
+	if other.total == 0 {
+		return
+	}
+	if len(other.counts) > len(h.counts) {
+		grown := make([]uint64, len(other.counts))
+		copy(grown, h.counts)
+		h.counts = grown
+	}
+	for i, c := range other.counts {
+		h.counts[i] += c
+	}
+	if h.total == 0 || other.min < h.min {
+		h.min = other.min
+	}
+	if other.max > h.max {
+		h.max = other.max
+	}
+	h.total += other.total
+	h.sum += other.sum
+}
+
+func (h *Histogram) Count() uint64 {
+	return h.total
+}
+
+func (h *Histogram) Min() time.Duration {
+	return h.min
+}
+
+func (h *Histogram) Max() time.Duration {
+	return h.max
+}
+
+func (h *Histogram) Mean() time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	return h.sum / time.Duration(h.total)
+}
+
+// Percentile returns the latency at or below which q (0 to 100) percent of the
+// values fall.
+func (h *Histogram) Percentile(q float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q / 100 * float64(h.total)))
+	if rank == 0 {
+		rank = 1
+	}
+	var seen uint64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			d := time.Duration(bucketUpperBound(i)) * time.Microsecond
+			if d > h.max {
+				d = h.max
+			}
+			if d < h.min {
+				d = h.min
+			}
+			return d
+		}
+	}
+	return h.max
+}
+
+// Bucket is a histogram bar: Count values are below UpperBound.
+type Bucket struct {
+	UpperBound time.Duration
+	Count      uint64
+}
+
+// Buckets summarizes the distribution in power-of-two bars, skipping empty ones.
+func (h *Histogram) Buckets() []Bucket {
+	var out []Bucket
+	var count uint64
+	bound := uint64(1)
+	for i, c := range h.counts {
+		for bucketUpperBound(i) >= bound {
+			if count > 0 {
+				out = append(out, Bucket{UpperBound: time.Duration(bound) * time.Microsecond, Count: count})
+				count = 0
+			}
+			bound <<= 1
+		}
+		count += c
+	}
+	if count > 0 {
+		out = append(out, Bucket{UpperBound: time.Duration(bound) * time.Microsecond, Count: count})
+	}
+	return out
+}
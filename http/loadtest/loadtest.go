@@ -0,0 +1,203 @@
+// Package loadtest drives a request template through HTTPClient at a fixed rate
+// or concurrency and reports latency percentiles, status codes and errors.
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	httpclient "http"
+)
+
+// Config describes a load test.
+type Config struct {
+	// Request is the template sent on every call. Its body must be replayable,
+	// which is the case for requests built with http.NewRequest from a byte
+	// slice, string or bytes.Reader.
+	Request *http.Request
+	// Client sends the requests; NewHTTPClient() when nil.
+	Client *httpclient.HTTPClient
+	// Duration bounds how long new requests are started.
+	Duration time.Duration
+	// Rate is the number of requests started per second. When zero, Concurrency
+	// workers send requests back to back instead.
+	Rate float64
+	// Concurrency is the number of workers. With a Rate it caps the requests in
+	// flight. Defaults to 1 without a Rate and 64 with one.
+	Concurrency int
+	// CorrectCoordinatedOmission measures latency from the time a request was
+	// scheduled rather than sent, so stalls in a fixed-rate test are not hidden
+	// by requests that queued behind them. It only applies with a Rate.
+	CorrectCoordinatedOmission bool
+}
+
+// Run executes the load test and waits for requests in flight to complete.
+func Run(ctx context.Context, cfg Config) (*Report, error) {
+	if cfg.Request == nil {
+		return nil, fmt.Errorf("load test needs a request template")
+	}
+	if cfg.Duration <= 0 {
+		return nil, fmt.Errorf("load test needs a positive duration")
+	}
+	if cfg.Request.Body != nil && cfg.Request.Body != http.NoBody && cfg.Request.GetBody == nil {
+		return nil, fmt.Errorf("request template body cannot be replayed")
+	}
+	if cfg.Client == nil {
+		cfg.Client = httpclient.NewHTTPClient()
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+		if cfg.Rate > 0 {
+			cfg.Concurrency = 64
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Workers receive the time each request was scheduled for.
+	schedule := make(chan time.Time, cfg.Concurrency)
+	results := make([]*recorder, cfg.Concurrency)
+	var wg sync.WaitGroup
+	for i := range results {
+		results[i] = newRecorder()
+		wg.Add(1)
+		go func(rec *recorder) {
+			defer wg.Done()
+			for intended := range schedule {
+				rec.record(send(ctx, cfg, intended))
+			}
+		}(results[i])
+	}
+
+	start := time.Now()
+	deadline := start.Add(cfg.Duration)
+	if cfg.Rate > 0 {
+		scheduleFixedRate(ctx, schedule, start, deadline, cfg.Rate)
+	} else {
+		scheduleClosedLoop(ctx, schedule, deadline)
+	}
+	close(schedule)
+	wg.Wait()
+
+	report := newReport(time.Since(start))
+	for _, rec := range results {
+		report.merge(rec)
+	}
+	return report, nil
+}
+
+// scheduleFixedRate emits one slot every 1/rate seconds. Slots keep their
+// planned time even when workers are busy and pick them up late.
+func scheduleFixedRate(ctx context.Context, schedule chan<- time.Time, start time.Time, deadline time.Time, rate float64) {
+	interval := time.Duration(float64(time.Second) / rate)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for i := 0; ; i++ {
+		next := start.Add(time.Duration(i) * interval)
+		if !next.Before(deadline) {
+			return
+		}
+		timer.Reset(time.Until(next))
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case schedule <- next:
+		}
+	}
+}
+
+// scheduleClosedLoop hands out slots as fast as workers take them.
+func scheduleClosedLoop(ctx context.Context, schedule chan<- time.Time, deadline time.Time) {
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return
+		case schedule <- time.Now():
+		}
+	}
+}
+
+// result is the outcome of a single request.
+type result struct {
+	latency time.Duration
+	status  int
+	err     error
+}
+
+func send(ctx context.Context, cfg Config, intended time.Time) result {
+	req := cfg.Request.Clone(ctx)
+	if cfg.Request.GetBody != nil {
+		body, err := cfg.Request.GetBody()
+		if err != nil {
+			return result{err: err}
+		}
+		req.Body = body
+	}
+
+	started := time.Now()
+	resp, err := cfg.Client.DoV2(ctx, req)
+	finished := time.Now()
+
+	from := started
+	if cfg.CorrectCoordinatedOmission && cfg.Rate > 0 {
+		from = intended
+	}
+	r := result{latency: finished.Sub(from), err: err}
+	if resp != nil {
+		r.status = resp.StatusCode
+	}
+	return r
+}
+
+// recorder aggregates the results of one worker.
+type recorder struct {
+	latency  Histogram
+	statuses map[int]int
+	errors   map[string]int
+}
+
+func newRecorder() *recorder {
+	return &recorder{statuses: make(map[int]int), errors: make(map[string]int)}
+}
+
+func (r *recorder) record(res result) {
+	r.latency.Record(res.latency)
+	if res.err != nil {
+		r.errors[classifyError(res.err)]++
+		return
+	}
+	r.statuses[res.status]++
+}
+
+// classifyError groups transport errors into a few readable kinds.
+func classifyError(err error) string {
+	msg := err.Error()
+	for _, kind := range []struct{ match, name string }{
+		{"deadline exceeded", "timeout"},
+		{"Client.Timeout", "timeout"},
+		{"connection refused", "connection refused"},
+		{"connection reset", "connection reset"},
+		{"no such host", "dns lookup failed"},
+		{"EOF", "connection closed"},
+		{"tls:", "tls error"},
+		{"x509:", "tls error"},
+	} {
+		if strings.Contains(msg, kind.match) {
+			return kind.name
+		}
+	}
+	if i := strings.LastIndex(msg, ": "); i >= 0 {
+		return msg[i+2:]
+	}
+	return msg
+}
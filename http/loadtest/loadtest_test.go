@@ -0,0 +1,145 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHistogramPercentiles(t *testing.T) {
+	fmt.Println("TestHistogramPercentiles")
+	var h Histogram
+	for i := 1; i <= 1000; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+	for _, tc := range []struct {
+		q    float64
+		want time.Duration
+	}{{50, 500 * time.Millisecond}, {90, 900 * time.Millisecond}, {99, 990 * time.Millisecond}, {100, time.Second}} {
+		got := h.Percentile(tc.q)
+		if diff := float64(got-tc.want) / float64(tc.want); diff < 0 || diff > 0.02 {
+			t.Fatalf("p%v: expected about %s, got %s", tc.q, tc.want, got)
+		}
+	}
+	if h.Min() != time.Millisecond || h.Max() != time.Second || h.Count() != 1000 {
+		t.Fatalf("unexpected min %s, max %s, count %d", h.Min(), h.Max(), h.Count())
+	}
+
+	var total uint64
+	for _, b := range h.Buckets() {
+		total += b.Count
+	}
+	if total != 1000 {
+		t.Fatalf("expected buckets to hold every value, got %d", total)
+	}
+}
+
+func TestHistogramBucketBounds(t *testing.T) {
+	fmt.Println("TestHistogramBucketBounds")
+	for _, us := range []uint64{0, 1, 127, 128, 129, 255, 256, 1000, 123456, 1 << 40} {
+		i := bucketIndex(us)
+		if bucketUpperBound(i) < us || (i > 0 && bucketUpperBound(i-1) >= us) {
+			t.Fatalf("value %d is not within bucket %d", us, i)
+		}
+	}
+}
+
+func TestRunClosedLoop(t *testing.T) {
+	fmt.Println("TestRunClosedLoop")
+	var n int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("appkey") != "k" {
+			t.Errorf("unexpected request %s %v", r.Method, r.Header)
+		}
+		if atomic.AddInt32(&n, 1)%4 == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{"a":1}`))
+	req.Header.Set("appkey", "k")
+	report, err := Run(context.Background(), Config{Request: req, Concurrency: 4, Duration: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("failed to run load test: %v", err)
+	}
+	if report.Requests == 0 || report.Errors != 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if report.StatusCodes[200]+report.StatusCodes[503] != int(report.Requests) || report.StatusCodes[503] == 0 {
+		t.Fatalf("unexpected status codes %v", report.StatusCodes)
+	}
+
+	var text, js bytes.Buffer
+	report.WriteText(&text)
+	report.WriteJSON(&js)
+	if !strings.Contains(text.String(), "p99") || !strings.Contains(text.String(), "503:") {
+		t.Fatalf("unexpected text report:\n%s", text.String())
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil || decoded["status_codes"] == nil {
+		t.Fatalf("unexpected json report %s: %v", js.String(), err)
+	}
+}
+
+func TestRunFixedRate(t *testing.T) {
+	fmt.Println("TestRunFixedRate")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	report, err := Run(context.Background(), Config{Request: req, Rate: 100, Duration: 300 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("failed to run load test: %v", err)
+	}
+	if report.Requests != 30 {
+		t.Fatalf("expected 30 requests at 100/s for 300ms, got %d", report.Requests)
+	}
+}
+
+func TestRunCorrectsCoordinatedOmission(t *testing.T) {
+	fmt.Println("TestRunCorrectsCoordinatedOmission")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	cfg := Config{Request: req, Rate: 200, Concurrency: 1, Duration: 100 * time.Millisecond}
+
+	naive, _ := Run(context.Background(), cfg)
+	cfg.CorrectCoordinatedOmission = true
+	corrected, _ := Run(context.Background(), cfg)
+
+	// A single worker falls behind a 5ms schedule with 20ms responses, so the
+	// corrected latencies include the queueing delay the naive ones hide.
+	if naive.Latency.Max() > 60*time.Millisecond {
+		t.Fatalf("expected naive latency close to service time, got %s", naive.Latency.Max())
+	}
+	if corrected.Latency.Max() < 2*naive.Latency.Max() {
+		t.Fatalf("expected corrected max %s to exceed naive max %s", corrected.Latency.Max(), naive.Latency.Max())
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	fmt.Println("TestRunErrors")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	report, err := Run(context.Background(), Config{Request: req, Duration: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("failed to run load test: %v", err)
+	}
+	if report.Errors == 0 || report.ErrorKinds["connection refused"] != int(report.Errors) {
+		t.Fatalf("unexpected errors %v", report.ErrorKinds)
+	}
+}
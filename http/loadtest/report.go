@@ -0,0 +1,152 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Report summarizes a load test.
+type Report struct {
+	Requests uint64
+	Errors   uint64
+	Elapsed  time.Duration
+	// Latency holds the latency of every request, failed ones included.
+	Latency     Histogram
+	StatusCodes map[int]int
+	// ErrorKinds counts transport errors by kind; see classifyError.
+	ErrorKinds map[string]int
+}
+
+func newReport(elapsed time.Duration) *Report {
+	return &Report{Elapsed: elapsed, StatusCodes: make(map[int]int), ErrorKinds: make(map[string]int)}
+}
+
+func (r *Report) merge(rec *recorder) {
+	r.Latency.Merge(&rec.latency)
+	r.Requests += rec.latency.Count()
+	for code, n := range rec.statuses {
+		r.StatusCodes[code] += n
+	}
+	for kind, n := range rec.errors {
+		r.ErrorKinds[kind] += n
+		r.Errors += uint64(n)
+	}
+}
+
+// Throughput is the number of completed requests per second.
+func (r *Report) Throughput() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Requests) / r.Elapsed.Seconds()
+}
+
+// WriteText prints a human readable summary with a latency histogram.
+func (r *Report) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "requests:   %d in %s (%.1f/s), %d errors\n", r.Requests, r.Elapsed.Round(time.Millisecond), r.Throughput(), r.Errors)
+	fmt.Fprintf(&b, "latency:    min %s, mean %s, p50 %s, p90 %s, p99 %s, max %s\n",
+		r.Latency.Min(), r.Latency.Mean(), r.Latency.Percentile(50), r.Latency.Percentile(90), r.Latency.Percentile(99), r.Latency.Max())
+
+	if buckets := r.Latency.Buckets(); len(buckets) > 0 {
+		b.WriteString("histogram:\n")
+		var peak uint64
+		for _, bucket := range buckets {
+			if bucket.Count > peak {
+				peak = bucket.Count
+			}
+		}
+		for _, bucket := range buckets {
+			bar := strings.Repeat("#", int(bucket.Count*40/peak))
+			fmt.Fprintf(&b, "  < %-10s %8d %s\n", bucket.UpperBound, bucket.Count, bar)
+		}
+	}
+
+	if len(r.StatusCodes) > 0 {
+		b.WriteString("status codes:\n")
+		codes := make([]int, 0, len(r.StatusCodes))
+		for code := range r.StatusCodes {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			fmt.Fprintf(&b, "  %d: %d\n", code, r.StatusCodes[code])
+		}
+	}
+	if len(r.ErrorKinds) > 0 {
+		b.WriteString("errors:\n")
+		kinds := make([]string, 0, len(r.ErrorKinds))
+		for kind := range r.ErrorKinds {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		for _, kind := range kinds {
+			fmt.Fprintf(&b, "  %s: %d\n", kind, r.ErrorKinds[kind])
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type jsonReport struct {
+	Requests    uint64         `json:"requests"`
+	Errors      uint64         `json:"errors"`
+	ElapsedMs   float64        `json:"elapsed_ms"`
+	Throughput  float64        `json:"throughput"`
+	Latency     jsonLatency    `json:"latency_ms"`
+	Histogram   []jsonBucket   `json:"histogram"`
+	StatusCodes map[string]int `json:"status_codes"`
+	ErrorKinds  map[string]int `json:"errors_by_kind"`
+}
+
+type jsonLatency struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+type jsonBucket struct {
+	UpperBoundMs float64 `json:"lt_ms"`
+	Count        uint64  `json:"count"`
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// WriteJSON prints the report as JSON, with durations in milliseconds.
+func (r *Report) WriteJSON(w io.Writer) error {
+	out := jsonReport{
+		Requests:   r.Requests,
+		Errors:     r.Errors,
+		ElapsedMs:  ms(r.Elapsed),
+		Throughput: r.Throughput(),
+		Latency: jsonLatency{
+			Min:  ms(r.Latency.Min()),
+			Mean: ms(r.Latency.Mean()),
+			P50:  ms(r.Latency.Percentile(50)),
+			P90:  ms(r.Latency.Percentile(90)),
+			P99:  ms(r.Latency.Percentile(99)),
+			Max:  ms(r.Latency.Max()),
+		},
+		Histogram:   []jsonBucket{},
+		StatusCodes: make(map[string]int, len(r.StatusCodes)),
+		ErrorKinds:  r.ErrorKinds,
+	}
+	for _, bucket := range r.Latency.Buckets() {
+		out.Histogram = append(out.Histogram, jsonBucket{UpperBoundMs: ms(bucket.UpperBound), Count: bucket.Count})
+	}
+	for code, n := range r.StatusCodes {
+		out.StatusCodes[fmt.Sprint(code)] = n
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,327 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics counts the requests of the clients it is given to with
+// WithMetrics. It is a prometheus.Collector:
+//
+//	metrics := httpclient.NewMetrics("shipos")
+//	prometheus.MustRegister(metrics)
+//	client := httpclient.NewHTTPClient(httpclient.WithMetrics(metrics))
+//
+// Every attempt is counted, including those made by middleware such as
+// digest's retry after a challenge, but not hedge requests, which are
+// counted apart.
+type Metrics struct {
+	buckets []float64
+	descs   metricsDescs
+
+	mu       sync.Mutex
+	requests map[RequestLabels]*latencyStats
+	retries  map[RequestLabels]int64
+	inFlight map[string]int64
+	sent     map[string]int64
+	received map[string]int64
+	hedgers  []*hedger
+}
+
+// RequestLabels identify a series of requests. StatusClass is "1xx" to
+// "5xx", or "error" when no response came back.
+type RequestLabels struct {
+	Host        string
+	Method      string
+	Route       string
+	StatusClass string
+}
+
+// Histogram is a latency distribution.
+type Histogram struct {
+	Count uint64
+	Sum   time.Duration
+	// Buckets counts the requests no slower than each upper bound, in
+	// seconds.
+	Buckets map[float64]uint64
+}
+
+// MetricsSnapshot is a copy of the values of a Metrics.
+type MetricsSnapshot struct {
+	// Requests counts finished requests.
+	Requests map[RequestLabels]int64
+	Latency  map[RequestLabels]Histogram
+	// Retries counts repeated attempts of a call, without StatusClass.
+	Retries map[RequestLabels]int64
+	// InFlight, BytesSent and BytesReceived are by host.
+	InFlight      map[string]int64
+	BytesSent     map[string]int64
+	BytesReceived map[string]int64
+	Hedges        HedgeStats
+}
+
+type latencyStats struct {
+	count   uint64
+	sum     time.Duration
+	buckets []uint64 // not cumulative
+}
+
+type metricsDescs struct {
+	requests, latency, retries, inFlight, sent, received *prometheus.Desc
+	hedgeRequests, hedges, hedgeWins, hedgesDenied       *prometheus.Desc
+}
+
+// NewMetrics creates metrics labelled client="name", so that several
+// clients can be told apart in one registry. Latency buckets, in seconds,
+// default to prometheus.DefBuckets.
+func NewMetrics(name string, buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	constLabels := prometheus.Labels{"client": name}
+	desc := func(name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc("http_client_"+name, help, labels, constLabels)
+	}
+	return &Metrics{
+		buckets: buckets,
+		descs: metricsDescs{
+			requests:      desc("requests_total", "Requests finished, by status class.", "host", "method", "route", "status_class"),
+			latency:       desc("request_duration_seconds", "Time from sending a request to reading its response body.", "host", "method", "route", "status_class"),
+			retries:       desc("retries_total", "Repeated attempts of a call.", "host", "method", "route"),
+			inFlight:      desc("requests_in_flight", "Requests sent and not finished.", "host"),
+			sent:          desc("sent_bytes_total", "Request body bytes sent.", "host"),
+			received:      desc("received_bytes_total", "Response body bytes received.", "host"),
+			hedgeRequests: desc("hedgeable_requests_total", "Requests that could be hedged."),
+			hedges:        desc("hedges_total", "Hedge requests sent."),
+			hedgeWins:     desc("hedge_wins_total", "Hedge requests that answered first."),
+			hedgesDenied:  desc("hedges_denied_total", "Hedges skipped for lack of budget."),
+		},
+		requests: map[RequestLabels]*latencyStats{},
+		retries:  map[RequestLabels]int64{},
+		inFlight: map[string]int64{},
+		sent:     map[string]int64{},
+		received: map[string]int64{},
+	}
+}
+
+// WithMetrics records the client's requests in metrics. One Metrics may be
+// shared by several clients.
+func WithMetrics(metrics *Metrics) Option {
+	return func(c *HTTPClient) {
+		c.metrics = metrics
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		m.descs.requests, m.descs.latency, m.descs.retries, m.descs.inFlight, m.descs.sent, m.descs.received,
+		m.descs.hedgeRequests, m.descs.hedges, m.descs.hedgeWins, m.descs.hedgesDenied,
+	} {
+		ch <- d
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	s := m.Snapshot()
+	for l, n := range s.Requests {
+		ch <- prometheus.MustNewConstMetric(m.descs.requests, prometheus.CounterValue, float64(n), l.Host, l.Method, l.Route, l.StatusClass)
+	}
+	for l, h := range s.Latency {
+		ch <- prometheus.MustNewConstHistogram(m.descs.latency, h.Count, h.Sum.Seconds(), h.Buckets, l.Host, l.Method, l.Route, l.StatusClass)
+	}
+	for l, n := range s.Retries {
+		ch <- prometheus.MustNewConstMetric(m.descs.retries, prometheus.CounterValue, float64(n), l.Host, l.Method, l.Route)
+	}
+	for host, n := range s.InFlight {
+		ch <- prometheus.MustNewConstMetric(m.descs.inFlight, prometheus.GaugeValue, float64(n), host)
+	}
+	for host, n := range s.BytesSent {
+		ch <- prometheus.MustNewConstMetric(m.descs.sent, prometheus.CounterValue, float64(n), host)
+	}
+	for host, n := range s.BytesReceived {
+		ch <- prometheus.MustNewConstMetric(m.descs.received, prometheus.CounterValue, float64(n), host)
+	}
+	ch <- prometheus.MustNewConstMetric(m.descs.hedgeRequests, prometheus.CounterValue, float64(s.Hedges.Requests))
+	ch <- prometheus.MustNewConstMetric(m.descs.hedges, prometheus.CounterValue, float64(s.Hedges.Hedges))
+	ch <- prometheus.MustNewConstMetric(m.descs.hedgeWins, prometheus.CounterValue, float64(s.Hedges.Wins))
+	ch <- prometheus.MustNewConstMetric(m.descs.hedgesDenied, prometheus.CounterValue, float64(s.Hedges.Denied))
+}
+
+// Snapshot returns a copy of the current values.
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := MetricsSnapshot{
+		Requests:      make(map[RequestLabels]int64, len(m.requests)),
+		Latency:       make(map[RequestLabels]Histogram, len(m.requests)),
+		Retries:       make(map[RequestLabels]int64, len(m.retries)),
+		InFlight:      make(map[string]int64, len(m.inFlight)),
+		BytesSent:     make(map[string]int64, len(m.sent)),
+		BytesReceived: make(map[string]int64, len(m.received)),
+	}
+	for l, stats := range m.requests {
+		s.Requests[l] = int64(stats.count)
+		h := Histogram{Count: stats.count, Sum: stats.sum, Buckets: make(map[float64]uint64, len(m.buckets))}
+		var cumulative uint64
+		for i, bound := range m.buckets {
+			cumulative += stats.buckets[i]
+			h.Buckets[bound] = cumulative
+		}
+		s.Latency[l] = h
+	}
+	for l, n := range m.retries {
+		s.Retries[l] = n
+	}
+	for host, n := range m.inFlight {
+		s.InFlight[host] = n
+	}
+	for host, n := range m.sent {
+		s.BytesSent[host] = n
+	}
+	for host, n := range m.received {
+		s.BytesReceived[host] = n
+	}
+	for _, h := range m.hedgers {
+		h.mu.Lock()
+		s.Hedges.Requests += h.stats.Requests
+		s.Hedges.Hedges += h.stats.Hedges
+		s.Hedges.Wins += h.stats.Wins
+		s.Hedges.Denied += h.stats.Denied
+		h.mu.Unlock()
+	}
+	return s
+}
+
+func (m *Metrics) addHedger(h *hedger) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hedgers = append(m.hedgers, h)
+}
+
+type attemptsKey struct{}
+
+// withAttempts starts counting the attempts of one call, so that attempts
+// after the first are counted as retries.
+func withAttempts(ctx context.Context) context.Context {
+	return context.WithValue(ctx, attemptsKey{}, new(int32))
+}
+
+// transport records the requests sent through next.
+func (m *Metrics) transport(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		host := req.URL.Host
+		labels := RequestLabels{Host: host, Method: req.Method, Route: RouteFromContext(req.Context())}
+		// Redirects are new requests of the same call, not retries.
+		if attempts, ok := req.Context().Value(attemptsKey{}).(*int32); ok && req.Response == nil {
+			if atomic.AddInt32(attempts, 1) > 1 {
+				m.add(m.retries, labels, 1)
+			}
+		}
+
+		sent := &countingReader{}
+		if req.Body != nil && req.Body != http.NoBody {
+			counted := req.Clone(req.Context())
+			sent.ReadCloser = req.Body
+			counted.Body = sent
+			req = counted
+		}
+		m.addInFlight(host, 1)
+		start := time.Now()
+		resp, err := next.RoundTrip(req)
+		m.addBytes(m.sent, host, atomic.LoadInt64(&sent.n))
+		if err != nil {
+			labels.StatusClass = "error"
+			m.finish(labels, time.Since(start))
+			return nil, err
+		}
+		labels.StatusClass = strconv.Itoa(resp.StatusCode/100) + "xx"
+		resp.Body = &metricsBody{ReadCloser: resp.Body, done: func(received int64) {
+			m.addBytes(m.received, host, received)
+			m.finish(labels, time.Since(start))
+		}}
+		return resp, nil
+	})
+}
+
+func (m *Metrics) add(counts map[RequestLabels]int64, labels RequestLabels, n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counts[labels] += n
+}
+
+func (m *Metrics) addBytes(counts map[string]int64, host string, n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counts[host] += n
+}
+
+func (m *Metrics) addInFlight(host string, n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight[host] += n
+}
+
+// finish records a request whose response body was read or closed.
+func (m *Metrics) finish(labels RequestLabels, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight[labels.Host]--
+	stats, ok := m.requests[labels]
+	if !ok {
+		stats = &latencyStats{buckets: make([]uint64, len(m.buckets))}
+		m.requests[labels] = stats
+	}
+	stats.count++
+	stats.sum += latency
+	seconds := latency.Seconds()
+	if i := sort.SearchFloat64s(m.buckets, seconds); i < len(m.buckets) {
+		stats.buckets[i]++
+	}
+}
+
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	atomic.AddInt64(&r.n, int64(n))
+	return n, err
+}
+
+// metricsBody calls done once, when the body is read to the end or closed.
+type metricsBody struct {
+	io.ReadCloser
+	n    int64
+	once sync.Once
+	done func(received int64)
+}
+
+func (b *metricsBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if err != nil {
+		b.once.Do(func() { b.done(b.n) })
+	}
+	return n, err
+}
+
+func (b *metricsBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.done(b.n) })
+	return err
+}
@@ -0,0 +1,156 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// retryOnce is middleware resending requests answered 503 once.
+func retryOnce(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := next.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusServiceUnavailable {
+			return resp, err
+		}
+		resp.Body.Close()
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+		return next.RoundTrip(req)
+	})
+}
+
+func newMetricsServer() *httptest.Server {
+	var mu sync.Mutex
+	flaky := 0
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/orders/1":
+			w.Write([]byte("order"))
+		case "/flaky":
+			mu.Lock()
+			flaky++
+			first := flaky == 1
+			mu.Unlock()
+			if first {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("ok"))
+		case "/moved":
+			http.Redirect(w, r, "/orders/1", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestMetrics(t *testing.T) {
+	fmt.Println("TestMetrics")
+	server := newMetricsServer()
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	metrics := NewMetrics("test")
+	client := NewHTTPClient(WithMetrics(metrics), WithMiddleware(retryOnce))
+	ctx := context.Background()
+
+	vars := map[string]interface{}{"base": server.URL, "id": 1}
+	if _, err := client.GetWithTemplate(ctx, "{+base}/orders/{id}", vars, nil); err != nil {
+		t.Fatalf("failed to get order: %v", err)
+	}
+	if _, err := client.PostWithHeader(ctx, server.URL+"/flaky", []byte(`{"a":1}`), nil); err != nil {
+		t.Fatalf("failed to post: %v", err)
+	}
+	if _, err := client.GetWithHeader(ctx, server.URL+"/moved", nil); err != nil {
+		t.Fatalf("failed to follow redirect: %v", err)
+	}
+	if _, err := client.GetWithHeader(ctx, server.URL+"/missing", nil); err == nil {
+		t.Fatalf("expected a 404")
+	}
+	if _, err := client.GetWithHeader(ctx, "http://127.0.0.1:1/down", nil); err == nil {
+		t.Fatalf("expected a connection error")
+	}
+
+	s := metrics.Snapshot()
+	want := map[RequestLabels]int64{
+		{Host: host, Method: "GET", Route: "{+base}/orders/{id}", StatusClass: "2xx"}: 1,
+		{Host: host, Method: "POST", StatusClass: "5xx"}:                              1,
+		{Host: host, Method: "POST", StatusClass: "2xx"}:                              1,
+		{Host: host, Method: "GET", StatusClass: "3xx"}:                               1,
+		{Host: host, Method: "GET", StatusClass: "2xx"}:                               1,
+		{Host: host, Method: "GET", StatusClass: "4xx"}:                               1,
+		{Host: "127.0.0.1:1", Method: "GET", StatusClass: "error"}:                    1,
+	}
+	if len(s.Requests) != len(want) {
+		t.Fatalf("unexpected requests %v", s.Requests)
+	}
+	for labels, n := range want {
+		if s.Requests[labels] != n || s.Latency[labels].Count != uint64(n) || s.Latency[labels].Buckets[10] != uint64(n) {
+			t.Fatalf("unexpected count for %+v: %v", labels, s.Requests)
+		}
+	}
+	// The redirect is not a retry.
+	if len(s.Retries) != 1 || s.Retries[RequestLabels{Host: host, Method: "POST"}] != 1 {
+		t.Fatalf("unexpected retries %v", s.Retries)
+	}
+	if s.InFlight[host] != 0 || s.BytesSent[host] != 14 || s.BytesReceived[host] < int64(len("order")*2+len("ok")) {
+		t.Fatalf("unexpected gauges %v %v %v", s.InFlight, s.BytesSent, s.BytesReceived)
+	}
+}
+
+func TestMetricsCollector(t *testing.T) {
+	fmt.Println("TestMetricsCollector")
+	server := newMetricsServer()
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+	metrics := NewMetrics("test")
+	registry := prometheus.NewPedanticRegistry()
+	if err := registry.Register(metrics); err != nil {
+		t.Fatalf("failed to register: %v", err)
+	}
+	// Metrics of another client share the registry.
+	if err := registry.Register(NewMetrics("other")); err != nil {
+		t.Fatalf("failed to register a second client: %v", err)
+	}
+
+	client := NewHTTPClient(WithMetrics(metrics), WithMiddleware(retryOnce), WithHedging(HedgePolicy{Delay: 0}))
+	if _, err := client.PostWithHeader(context.Background(), server.URL+"/flaky", nil, nil); err != nil {
+		t.Fatalf("failed to post: %v", err)
+	}
+
+	expected := fmt.Sprintf(`
+# HELP http_client_requests_total Requests finished, by status class.
+# TYPE http_client_requests_total counter
+http_client_requests_total{client="test",host=%[1]q,method="POST",route="",status_class="2xx"} 1
+http_client_requests_total{client="test",host=%[1]q,method="POST",route="",status_class="5xx"} 1
+# HELP http_client_retries_total Repeated attempts of a call.
+# TYPE http_client_retries_total counter
+http_client_retries_total{client="test",host=%[1]q,method="POST",route=""} 1
+# HELP http_client_hedges_total Hedge requests sent.
+# TYPE http_client_hedges_total counter
+http_client_hedges_total{client="other"} 0
+http_client_hedges_total{client="test"} 0
+`, u.Host)
+	err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"http_client_requests_total", "http_client_retries_total", "http_client_hedges_total")
+	if err != nil {
+		t.Fatalf("unexpected metrics: %v", err)
+	}
+	if n, err := testutil.GatherAndCount(registry, "http_client_request_duration_seconds"); err != nil || n != 2 {
+		t.Fatalf("expected 2 latency histograms, got %d, %v", n, err)
+	}
+}
@@ -0,0 +1,449 @@
+// Package outbox delivers HTTP requests reliably: requests are persisted
+// before they are sent, so a failing partner or a restart of the process does
+// not lose them. Background workers retry with exponential backoff and move
+// entries that keep failing to a dead-letter list, from which they can be
+// replayed or purged.
+//
+// Delivery is at least once. Every request carries an Idempotency-Key header
+// holding the entry ID, unless the caller set one, so receivers can drop
+// duplicates.
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	mathrand "math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	httpclient "http"
+)
+
+// Entry is a request in the outbox.
+type Entry struct {
+	ID          string            `json:"id"`
+	Method      string            `json:"method"`
+	URL         string            `json:"url"`
+	Header      map[string]string `json:"header,omitempty"`
+	Body        []byte            `json:"body,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	Attempts    int               `json:"attempts"`
+	NextAttempt time.Time         `json:"next_attempt"`
+	LastError   string            `json:"last_error,omitempty"`
+}
+
+// Outbox delivers the pending entries of a store.
+type Outbox struct {
+	store        Store
+	client       *httpclient.HTTPClient
+	workers      int
+	maxAttempts  int
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	pollInterval time.Duration
+	onError      func(error)
+	now          func() time.Time
+
+	wake   chan struct{}
+	cancel context.CancelFunc
+	done   sync.WaitGroup
+
+	mu       sync.Mutex
+	inFlight map[string]*flight
+	rand     *mathrand.Rand
+}
+
+// flight is an entry being delivered.
+type flight struct {
+	cancel context.CancelFunc
+	purged bool
+}
+
+// Option configures an Outbox.
+type Option func(*Outbox)
+
+// WithClient sets the client that sends requests, NewHTTPClient() by default.
+func WithClient(client *httpclient.HTTPClient) Option {
+	return func(o *Outbox) {
+		o.client = client
+	}
+}
+
+// WithWorkers sets how many requests are delivered concurrently, 4 by default.
+func WithWorkers(n int) Option {
+	return func(o *Outbox) {
+		o.workers = n
+	}
+}
+
+// WithMaxAttempts sets after how many failed attempts an entry is moved to the
+// dead-letter list, 10 by default.
+func WithMaxAttempts(n int) Option {
+	return func(o *Outbox) {
+		o.maxAttempts = n
+	}
+}
+
+// WithBackoff sets the delay before the first retry and the cap of the
+// exponentially growing delays, 1s and 5m by default. Delays are jittered.
+func WithBackoff(min time.Duration, max time.Duration) Option {
+	return func(o *Outbox) {
+		o.minBackoff = min
+		o.maxBackoff = max
+	}
+}
+
+// WithPollInterval sets how often the store is checked for entries that
+// became due, 1s by default. Enqueued entries are picked up immediately.
+func WithPollInterval(d time.Duration) Option {
+	return func(o *Outbox) {
+		o.pollInterval = d
+	}
+}
+
+// WithErrorHandler is called with the store errors of the workers, which
+// have no caller to return them to. They are logged by default.
+func WithErrorHandler(fn func(error)) Option {
+	return func(o *Outbox) {
+		o.onError = fn
+	}
+}
+
+func New(store Store, opts ...Option) *Outbox {
+	o := &Outbox{
+		store:        store,
+		workers:      4,
+		maxAttempts:  10,
+		minBackoff:   time.Second,
+		maxBackoff:   5 * time.Minute,
+		pollInterval: time.Second,
+		now:          time.Now,
+		wake:         make(chan struct{}, 1),
+		inFlight:     make(map[string]*flight),
+		rand:         mathrand.New(mathrand.NewSource(time.Now().UnixNano())),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.client == nil {
+		o.client = httpclient.NewHTTPClient()
+	}
+	if o.onError == nil {
+		o.onError = func(err error) {
+			log.Printf("outbox: %s", err)
+		}
+	}
+	return o
+}
+
+// Enqueue persists a request for delivery and returns its entry ID. The
+// request is sent once a worker is free, including entries left over from a
+// previous run.
+func (o *Outbox) Enqueue(ctx context.Context, method string, url string, body []byte,
+	headers map[string]string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	now := o.now()
+	e := &Entry{
+		ID:          newID(now),
+		Method:      method,
+		URL:         url,
+		Header:      headers,
+		Body:        body,
+		CreatedAt:   now,
+		NextAttempt: now,
+	}
+	if err := o.store.Save(Pending, e); err != nil {
+		return "", fmt.Errorf("save outbox entry failed: %s", err)
+	}
+	o.notify()
+	return e.ID, nil
+}
+
+// PostWithHeader enqueues a POST request.
+func (o *Outbox) PostWithHeader(ctx context.Context, url string, request []byte,
+	headers map[string]string) (string, error) {
+	return o.Enqueue(ctx, http.MethodPost, url, request, headers)
+}
+
+// Start launches the workers. They run until Close is called.
+func (o *Outbox) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	o.cancel = cancel
+	work := make(chan *Entry)
+	o.done.Add(o.workers + 1)
+	for i := 0; i < o.workers; i++ {
+		go func() {
+			defer o.done.Done()
+			for e := range work {
+				o.deliver(ctx, e)
+			}
+		}()
+	}
+	go func() {
+		defer o.done.Done()
+		defer close(work)
+		o.dispatch(ctx, work)
+	}()
+}
+
+// Close stops the workers and waits for them. Requests in flight are
+// cancelled and stay pending without counting as an attempt.
+func (o *Outbox) Close() {
+	if o.cancel != nil {
+		o.cancel()
+	}
+	o.done.Wait()
+}
+
+// Get returns an entry from list.
+func (o *Outbox) Get(list List, id string) (*Entry, error) {
+	return o.store.Get(list, id)
+}
+
+// Entries returns the entries of list in the order they were enqueued.
+func (o *Outbox) Entries(list List) ([]*Entry, error) {
+	return o.store.Entries(list)
+}
+
+// Replay moves a dead entry back to the pending list with a fresh attempt
+// count, to be delivered right away.
+func (o *Outbox) Replay(id string) error {
+	e, err := o.store.Get(Dead, id)
+	if err != nil {
+		return err
+	}
+	e.Attempts = 0
+	e.NextAttempt = o.now()
+	e.LastError = ""
+	if err := o.store.Save(Pending, e); err != nil {
+		return err
+	}
+	o.notify()
+	return nil
+}
+
+// Purge deletes an entry from list. Purging a pending entry that is being
+// delivered cancels the request.
+func (o *Outbox) Purge(list List, id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if f, ok := o.inFlight[id]; ok && list == Pending {
+		f.purged = true
+		f.cancel()
+	}
+	return o.store.Delete(list, id)
+}
+
+// PurgeAll deletes every entry of list and returns how many were deleted.
+func (o *Outbox) PurgeAll(list List) (int, error) {
+	entries, err := o.store.Entries(list)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, e := range entries {
+		err := o.Purge(list, e.ID)
+		if errors.Is(err, ErrNotFound) {
+			// Delivered or moved meanwhile.
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (o *Outbox) notify() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch hands due entries to the workers, sleeping until the next one is
+// due, the poll interval passes or an entry is enqueued.
+func (o *Outbox) dispatch(ctx context.Context, work chan<- *Entry) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		wait := o.pollInterval
+		entries, err := o.store.Entries(Pending)
+		if err != nil {
+			o.onError(fmt.Errorf("list pending outbox entries failed: %s", err))
+		} else {
+			now := o.now()
+			for _, e := range entries {
+				if delay := e.NextAttempt.Sub(now); delay > 0 {
+					if delay < wait {
+						wait = delay
+					}
+					continue
+				}
+				if !o.claim(e.ID) {
+					continue
+				}
+				select {
+				case work <- e:
+				case <-ctx.Done():
+					o.mu.Lock()
+					delete(o.inFlight, e.ID)
+					o.mu.Unlock()
+					return
+				}
+			}
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+		select {
+		case <-ctx.Done():
+			return
+		case <-o.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// claim marks an entry as in flight unless it already is.
+func (o *Outbox) claim(id string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, ok := o.inFlight[id]; ok {
+		return false
+	}
+	o.inFlight[id] = &flight{cancel: func() {}}
+	return true
+}
+
+func (o *Outbox) deliver(ctx context.Context, e *Entry) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	o.mu.Lock()
+	f := o.inFlight[e.ID]
+	f.cancel = cancel
+	o.mu.Unlock()
+	// Let the dispatcher hand this worker the next due entry.
+	defer o.notify()
+
+	// The entry may have been purged since the dispatcher listed it.
+	_, err := o.store.Get(Pending, e.ID)
+	if err == nil {
+		err = o.send(ctx, e)
+	} else {
+		o.mu.Lock()
+		f.purged = true
+		o.mu.Unlock()
+	}
+	backoff := o.backoff(e.Attempts + 1)
+
+	// The outcome is saved under the lock so that Purge cannot interleave and
+	// see a purged entry come back.
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.inFlight, e.ID)
+	switch {
+	case f.purged:
+		return
+	case err == nil:
+		// Should the delete fail, the entry is delivered again later, which
+		// at-least-once delivery allows.
+		if err := o.store.Delete(Pending, e.ID); err != nil {
+			o.onError(fmt.Errorf("delete delivered outbox entry %s failed: %s", e.ID, err))
+		}
+	case ctx.Err() != nil:
+		// Shutting down; the entry is retried on the next start.
+	default:
+		e.Attempts++
+		e.LastError = err.Error()
+		list := Pending
+		if !retryable(err) || e.Attempts >= o.maxAttempts {
+			list = Dead
+		} else {
+			e.NextAttempt = o.now().Add(backoff)
+		}
+		if err := o.store.Save(list, e); err != nil {
+			o.onError(fmt.Errorf("save outbox entry %s to %s failed: %s", e.ID, list, err))
+			// The store still holds the entry as it was before this
+			// attempt; keep it from being sent again before its backoff.
+			o.hold(e.ID, f, backoff)
+		}
+	}
+}
+
+// hold keeps an entry claimed for d. Must be called with o.mu held.
+func (o *Outbox) hold(id string, f *flight, d time.Duration) {
+	f.cancel = func() {}
+	o.inFlight[id] = f
+	time.AfterFunc(d, func() {
+		o.mu.Lock()
+		if o.inFlight[id] == f {
+			delete(o.inFlight, id)
+		}
+		o.mu.Unlock()
+		o.notify()
+	})
+}
+
+func (o *Outbox) send(ctx context.Context, e *Entry) error {
+	req, err := http.NewRequest(e.Method, e.URL, bytes.NewReader(e.Body))
+	if err != nil {
+		return err
+	}
+	for key, value := range e.Header {
+		req.Header.Set(key, value)
+	}
+	if req.Header.Get("Idempotency-Key") == "" {
+		req.Header.Set("Idempotency-Key", e.ID)
+	}
+	_, err = o.client.Do(ctx, req)
+	return err
+}
+
+// backoff returns the delay before retry attempt n: the minimum backoff
+// doubled n-1 times, capped, then jittered down by up to half.
+func (o *Outbox) backoff(n int) time.Duration {
+	d := o.minBackoff
+	for i := 1; i < n && d < o.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > o.maxBackoff {
+		d = o.maxBackoff
+	}
+	o.mu.Lock()
+	jitter := time.Duration(o.rand.Int63n(int64(d)/2 + 1))
+	o.mu.Unlock()
+	return d - jitter
+}
+
+// retryable reports whether a later attempt may succeed: transport errors,
+// 5xx, 408 and 429 responses are retried, other 4xx are not.
+func retryable(err error) bool {
+	var statusErr *httpclient.StatusError
+	if errors.As(err, &statusErr) {
+		code := statusErr.StatusCode
+		return code >= http.StatusInternalServerError || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// newID returns an ID that sorts by creation time.
+func newID(now time.Time) string {
+	var suffix [4]byte
+	rand.Read(suffix[:])
+	return fmt.Sprintf("%016x-%s", now.UnixNano(), hex.EncodeToString(suffix[:]))
+}
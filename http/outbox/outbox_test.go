@@ -0,0 +1,285 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func openStore(t *testing.T, path string) *BoltStore {
+	store, err := OpenBoltStore(path)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	return store
+}
+
+// waitFor polls cond until it holds or a few seconds passed.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func count(t *testing.T, o *Outbox, list List) int {
+	entries, err := o.Entries(list)
+	if err != nil {
+		t.Fatalf("failed to list %s: %v", list, err)
+	}
+	return len(entries)
+}
+
+// partner records the requests it receives and answers with the status codes
+// in order, then 200.
+type partner struct {
+	mu       sync.Mutex
+	statuses []int
+	keys     []string
+	bodies   []string
+}
+
+func (p *partner) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys = append(p.keys, r.Header.Get("Idempotency-Key"))
+	p.bodies = append(p.bodies, string(body))
+	status := http.StatusOK
+	if len(p.statuses) > 0 {
+		status, p.statuses = p.statuses[0], p.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (p *partner) received() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.keys)
+}
+
+func TestOutboxRetriesUntilDelivered(t *testing.T) {
+	fmt.Println("TestOutboxRetriesUntilDelivered")
+	p := &partner{statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+	server := httptest.NewServer(p)
+	defer server.Close()
+
+	store := openStore(t, filepath.Join(t.TempDir(), "outbox.db"))
+	defer store.Close()
+	o := New(store, WithBackoff(10*time.Millisecond, 20*time.Millisecond))
+	o.Start()
+	defer o.Close()
+
+	id, err := o.PostWithHeader(context.Background(), server.URL, []byte(`{"orderid":"odr-1"}`), map[string]string{"appkey": "k"})
+	if err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+	waitFor(t, "delivery", func() bool { return count(t, o, Pending) == 0 })
+
+	if p.received() != 3 {
+		t.Fatalf("expected 3 attempts, got %d", p.received())
+	}
+	for i, key := range p.keys {
+		if key != id || p.bodies[i] != `{"orderid":"odr-1"}` {
+			t.Fatalf("attempt %d sent key %q body %q", i, key, p.bodies[i])
+		}
+	}
+	if count(t, o, Dead) != 0 {
+		t.Fatalf("expected no dead letters")
+	}
+}
+
+func TestOutboxDeadLettersAndReplay(t *testing.T) {
+	fmt.Println("TestOutboxDeadLettersAndReplay")
+	p := &partner{statuses: []int{http.StatusBadRequest, http.StatusBadGateway, http.StatusBadGateway}}
+	server := httptest.NewServer(p)
+	defer server.Close()
+
+	store := openStore(t, filepath.Join(t.TempDir(), "outbox.db"))
+	defer store.Close()
+	o := New(store, WithMaxAttempts(2), WithBackoff(time.Millisecond, time.Millisecond))
+	o.Start()
+	defer o.Close()
+
+	// A 400 will not get better by retrying.
+	rejected, _ := o.Enqueue(context.Background(), http.MethodPut, server.URL, []byte("a"), nil)
+	waitFor(t, "dead letter", func() bool { return count(t, o, Dead) == 1 })
+	e, err := o.Get(Dead, rejected)
+	if err != nil {
+		t.Fatalf("failed to get dead letter: %v", err)
+	}
+	if e.Attempts != 1 || e.LastError != "response failed: 400, " {
+		t.Fatalf("unexpected dead letter %+v", e)
+	}
+
+	// 502s are retried until the attempts run out.
+	exhausted, _ := o.Enqueue(context.Background(), http.MethodPut, server.URL, []byte("b"), nil)
+	waitFor(t, "dead letter", func() bool { return count(t, o, Dead) == 2 })
+	if e, _ := o.Get(Dead, exhausted); e == nil || e.Attempts != 2 {
+		t.Fatalf("unexpected dead letter %+v", e)
+	}
+
+	if err := o.Replay(rejected); err != nil {
+		t.Fatalf("failed to replay: %v", err)
+	}
+	waitFor(t, "replayed delivery", func() bool { return count(t, o, Dead) == 1 && count(t, o, Pending) == 0 })
+	if p.received() != 4 || p.bodies[3] != "a" {
+		t.Fatalf("unexpected deliveries %q", p.bodies)
+	}
+
+	if n, err := o.PurgeAll(Dead); err != nil || n != 1 {
+		t.Fatalf("PurgeAll = %d, %v", n, err)
+	}
+	if err := o.Replay(exhausted); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound after purge, got %v", err)
+	}
+}
+
+func TestOutboxSurvivesRestart(t *testing.T) {
+	fmt.Println("TestOutboxSurvivesRestart")
+	p := &partner{}
+	server := httptest.NewServer(p)
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "outbox.db")
+
+	// Enqueued, but the process stops before delivering.
+	store := openStore(t, path)
+	id, err := New(store).PostWithHeader(context.Background(), server.URL, []byte("label"), nil)
+	if err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+	store.Close()
+
+	store = openStore(t, path)
+	defer store.Close()
+	o := New(store)
+	if e, err := o.Get(Pending, id); err != nil || string(e.Body) != "label" {
+		t.Fatalf("entry lost across restart: %+v, %v", e, err)
+	}
+	o.Start()
+	defer o.Close()
+	waitFor(t, "delivery", func() bool { return p.received() == 1 && count(t, o, Pending) == 0 })
+}
+
+func TestOutboxPurgeCancelsDelivery(t *testing.T) {
+	fmt.Println("TestOutboxPurgeCancelsDelivery")
+	started := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	store := openStore(t, filepath.Join(t.TempDir(), "outbox.db"))
+	defer store.Close()
+	o := New(store)
+	o.Start()
+	defer o.Close()
+
+	id, _ := o.Enqueue(context.Background(), http.MethodPost, server.URL, nil, nil)
+	<-started
+	if err := o.Purge(Pending, id); err != nil {
+		t.Fatalf("failed to purge: %v", err)
+	}
+	waitFor(t, "cancelled delivery", func() bool {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		return len(o.inFlight) == 0
+	})
+	if count(t, o, Pending) != 0 || count(t, o, Dead) != 0 {
+		t.Fatalf("purged entry came back")
+	}
+}
+
+// failingStore fails to save entries to one list.
+type failingStore struct {
+	*BoltStore
+	list List
+}
+
+func (s *failingStore) Save(list List, e *Entry) error {
+	if list == s.list {
+		return errors.New("disk full")
+	}
+	return s.BoltStore.Save(list, e)
+}
+
+func TestOutboxReportsSaveErrors(t *testing.T) {
+	fmt.Println("TestOutboxReportsSaveErrors")
+	p := &partner{statuses: []int{http.StatusBadRequest}}
+	server := httptest.NewServer(p)
+	defer server.Close()
+
+	bolt := openStore(t, filepath.Join(t.TempDir(), "outbox.db"))
+	defer bolt.Close()
+	var mu sync.Mutex
+	var reported []error
+	o := New(&failingStore{BoltStore: bolt, list: Dead}, WithBackoff(time.Hour, time.Hour),
+		WithErrorHandler(func(err error) {
+			mu.Lock()
+			reported = append(reported, err)
+			mu.Unlock()
+		}))
+	o.Start()
+	defer o.Close()
+
+	id, err := o.Enqueue(context.Background(), http.MethodPut, server.URL, []byte("a"), nil)
+	if err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+	waitFor(t, "reported error", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(reported) == 1
+	})
+	if !strings.Contains(reported[0].Error(), "save outbox entry "+id+" to dead failed: disk full") {
+		t.Fatalf("unexpected error %v", reported[0])
+	}
+	// The entry stays pending, and is not resent before its backoff.
+	time.Sleep(50 * time.Millisecond)
+	if count(t, o, Pending) != 1 || p.received() != 1 {
+		t.Fatalf("expected the entry held back, got %d deliveries", p.received())
+	}
+}
+
+func TestBoltStoreUnknownList(t *testing.T) {
+	fmt.Println("TestBoltStoreUnknownList")
+	store := openStore(t, filepath.Join(t.TempDir(), "outbox.db"))
+	defer store.Close()
+	archived := List("archived")
+	if err := store.Save(archived, &Entry{ID: "1"}); err == nil || !strings.Contains(err.Error(), `unknown outbox list "archived"`) {
+		t.Fatalf("expected an unknown list error, got %v", err)
+	}
+	if _, err := store.Get(archived, "1"); err == nil {
+		t.Fatalf("expected Get to fail")
+	}
+	if _, err := store.Entries(archived); err == nil {
+		t.Fatalf("expected Entries to fail")
+	}
+	if err := store.Delete(archived, "1"); err == nil {
+		t.Fatalf("expected Delete to fail")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	fmt.Println("TestBackoff")
+	o := New(nil, WithBackoff(time.Second, 10*time.Second))
+	for attempt, max := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 30: 10 * time.Second} {
+		for i := 0; i < 20; i++ {
+			if d := o.backoff(attempt); d < max/2 || d > max {
+				t.Fatalf("backoff(%d) = %s, want within [%s, %s]", attempt, d, max/2, max)
+			}
+		}
+	}
+}
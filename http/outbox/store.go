@@ -0,0 +1,145 @@
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// List names the lists an entry can be on.
+type List string
+
+const (
+	// Pending entries are waiting for delivery or for their next attempt.
+	Pending List = "pending"
+	// Dead entries ran out of attempts or failed permanently.
+	Dead List = "dead"
+)
+
+// ErrNotFound is returned for entries that are not on the requested list.
+var ErrNotFound = errors.New("outbox entry not found")
+
+// Store persists outbox entries. Implementations must be safe for concurrent
+// use.
+type Store interface {
+	// Save writes e to list, atomically removing it from the other list.
+	Save(list List, e *Entry) error
+	Get(list List, id string) (*Entry, error)
+	// Entries returns the entries of list in ID order, which is the order in
+	// which they were enqueued.
+	Entries(list List) ([]*Entry, error)
+	Delete(list List, id string) error
+}
+
+// BoltStore keeps entries in a bbolt database file, one bucket per list.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+var lists = []List{Pending, Dead}
+
+// OpenBoltStore opens or creates the database at path. The file is locked
+// while open, so only one process can own an outbox.
+func OpenBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open outbox store failed: %s", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, list := range lists {
+			if _, err := tx.CreateBucketIfNotExists([]byte(list)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("open outbox store failed: %s", err)
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *BoltStore) Save(list List, e *Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := bucket(tx, list)
+		if err != nil {
+			return err
+		}
+		for _, other := range lists {
+			if other != list {
+				if err := tx.Bucket([]byte(other)).Delete([]byte(e.ID)); err != nil {
+					return err
+				}
+			}
+		}
+		return b.Put([]byte(e.ID), data)
+	})
+}
+
+func (s *BoltStore) Get(list List, id string) (*Entry, error) {
+	var e *Entry
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b, err := bucket(tx, list)
+		if err != nil {
+			return err
+		}
+		data := b.Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		e = new(Entry)
+		return json.Unmarshal(data, e)
+	})
+	return e, err
+}
+
+func (s *BoltStore) Entries(list List) ([]*Entry, error) {
+	var entries []*Entry
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b, err := bucket(tx, list)
+		if err != nil {
+			return err
+		}
+		return b.ForEach(func(_, data []byte) error {
+			e := new(Entry)
+			if err := json.Unmarshal(data, e); err != nil {
+				return err
+			}
+			entries = append(entries, e)
+			return nil
+		})
+	})
+	return entries, err
+}
+
+func (s *BoltStore) Delete(list List, id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := bucket(tx, list)
+		if err != nil {
+			return err
+		}
+		if b.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+		return b.Delete([]byte(id))
+	})
+}
+
+func bucket(tx *bolt.Tx, list List) (*bolt.Bucket, error) {
+	if b := tx.Bucket([]byte(list)); b != nil {
+		return b, nil
+	}
+	return nil, fmt.Errorf("unknown outbox list %q", list)
+}
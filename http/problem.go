@@ -0,0 +1,126 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+)
+
+// ProblemContentType is the media type of RFC 9457 problem details.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 9457 problem details object. Helpers return it wrapped in a
+// StatusError when a server answers with application/problem+json, so callers
+// can reach it with errors.As. Services can send one with WriteProblem.
+type Problem struct {
+	Type     string
+	Title    string
+	Status   int
+	Detail   string
+	Instance string
+	// Extensions holds members beyond the standard ones.
+	Extensions map[string]interface{}
+}
+
+// NewProblem creates a problem for status, titled with the status text.
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{Title: http.StatusText(status), Status: status, Detail: detail}
+}
+
+func (p *Problem) Error() string {
+	title := p.Title
+	if title == "" {
+		title = http.StatusText(p.Status)
+	}
+	if p.Detail == "" {
+		return fmt.Sprintf("problem %d: %s", p.Status, title)
+	}
+	return fmt.Sprintf("problem %d: %s: %s", p.Status, title, p.Detail)
+}
+
+var problemMembers = []string{"type", "title", "status", "detail", "instance"}
+
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	// Standard members win over extensions of the same name.
+	for _, k := range problemMembers {
+		delete(m, k)
+	}
+	if p.Type != "" {
+		m["type"] = p.Type
+	}
+	if p.Title != "" {
+		m["title"] = p.Title
+	}
+	if p.Status != 0 {
+		m["status"] = p.Status
+	}
+	if p.Detail != "" {
+		m["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		m["instance"] = p.Instance
+	}
+	return json.Marshal(m)
+}
+
+func (p *Problem) UnmarshalJSON(data []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	*p = Problem{}
+	// Members with a wrong type are ignored, as RFC 9457 section 3.1 requires.
+	json.Unmarshal(m["type"], &p.Type)
+	json.Unmarshal(m["title"], &p.Title)
+	json.Unmarshal(m["status"], &p.Status)
+	json.Unmarshal(m["detail"], &p.Detail)
+	json.Unmarshal(m["instance"], &p.Instance)
+	for _, k := range problemMembers {
+		delete(m, k)
+	}
+	if len(m) > 0 {
+		p.Extensions = make(map[string]interface{}, len(m))
+		for k, raw := range m {
+			var v interface{}
+			if err := json.Unmarshal(raw, &v); err == nil {
+				p.Extensions[k] = v
+			}
+		}
+	}
+	return nil
+}
+
+// WriteProblem sends p as an application/problem+json response. A zero status
+// is sent as 500.
+func WriteProblem(w http.ResponseWriter, p *Problem) {
+	status := p.Status
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	body, err := json.Marshal(p)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// parseProblem decodes body when the content type announces problem details.
+func parseProblem(header http.Header, body []byte) *Problem {
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil || mediaType != ProblemContentType {
+		return nil
+	}
+	var p Problem
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil
+	}
+	return &p
+}
@@ -0,0 +1,78 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHttpPostWithHeaderProblem(t *testing.T) {
+	fmt.Println("TestHttpPostWithHeaderProblem")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteProblem(w, &Problem{
+			Type:       "https://example.com/probs/out-of-credit",
+			Title:      "You do not have enough credit.",
+			Status:     http.StatusForbidden,
+			Detail:     "Your current balance is 30, but that costs 50.",
+			Instance:   "/account/12345/msgs/abc",
+			Extensions: map[string]interface{}{"balance": 30, "accounts": []string{"/account/12345"}},
+		})
+	}))
+	defer server.Close()
+
+	_, err := HttpPostWithHeader(context.Background(), server.URL, []byte(`{}`), nil)
+
+	var problem *Problem
+	if !errors.As(err, &problem) {
+		t.Fatalf("expected a problem, got %v", err)
+	}
+	if problem.Status != http.StatusForbidden || problem.Type != "https://example.com/probs/out-of-credit" ||
+		problem.Instance != "/account/12345/msgs/abc" || problem.Extensions["balance"] != float64(30) {
+		t.Fatalf("unexpected problem %+v", problem)
+	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected the status error to stay reachable, got %v", err)
+	}
+}
+
+func TestStatusErrorWithoutProblem(t *testing.T) {
+	fmt.Println("TestStatusErrorWithoutProblem")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"title": "not a problem document"}`))
+	}))
+	defer server.Close()
+
+	_, err := HttpGetWithHeader(context.Background(), server.URL, nil)
+
+	var problem *Problem
+	if errors.As(err, &problem) {
+		t.Fatalf("expected no problem for plain json, got %+v", problem)
+	}
+}
+
+func TestProblemJSON(t *testing.T) {
+	fmt.Println("TestProblemJSON")
+	var p Problem
+	if err := json.Unmarshal([]byte(`{"status": "400", "title": "Bad", "trace": "x", "type": 7}`), &p); err != nil {
+		t.Fatalf("failed to decode problem: %v", err)
+	}
+	// Members with the wrong type are ignored rather than failing the decode.
+	if p.Status != 0 || p.Type != "" || p.Title != "Bad" || p.Extensions["trace"] != "x" {
+		t.Fatalf("unexpected problem %+v", p)
+	}
+
+	data, _ := json.Marshal(&Problem{Status: 404, Detail: "gone", Extensions: map[string]interface{}{"status": "shadowed", "id": 1}})
+	if string(data) != `{"detail":"gone","id":1,"status":404}` {
+		t.Fatalf("unexpected encoding %s", data)
+	}
+	if NewProblem(404, "album not found").Error() != "problem 404: Not Found: album not found" {
+		t.Fatalf("unexpected message %q", NewProblem(404, "album not found").Error())
+	}
+}
@@ -0,0 +1,173 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// RedirectPolicy decides whether the client follows a redirect to req; via
+// holds the requests made so far, oldest first. Returning ErrUseLastResponse
+// stops following and returns the redirect response itself; any other error
+// fails the request.
+type RedirectPolicy func(req *http.Request, via []*http.Request) error
+
+// ErrUseLastResponse is http.ErrUseLastResponse.
+var ErrUseLastResponse = http.ErrUseLastResponse
+
+// defaultMaxRedirects caps the redirects followed without a MaxRedirects
+// policy. The 11th redirect fails, where http.Client already fails the 10th.
+const defaultMaxRedirects = 10
+
+// NoRedirects returns redirect responses as they are.
+func NoRedirects() RedirectPolicy {
+	return func(req *http.Request, via []*http.Request) error {
+		return ErrUseLastResponse
+	}
+}
+
+// SameHostRedirects follows redirects that stay on the host of the original
+// request and returns the others as they are.
+func SameHostRedirects() RedirectPolicy {
+	return func(req *http.Request, via []*http.Request) error {
+		if !strings.EqualFold(req.URL.Host, via[0].URL.Host) {
+			return ErrUseLastResponse
+		}
+		return nil
+	}
+}
+
+// MaxRedirects fails requests redirected more than n times. It replaces the
+// default cap of 10 redirects.
+func MaxRedirects(n int) RedirectPolicy {
+	return func(req *http.Request, via []*http.Request) error {
+		if capped, ok := req.Context().Value(cappedKey{}).(*bool); ok {
+			*capped = true
+		}
+		if len(via) > n {
+			return fmt.Errorf("stopped after %d redirects", n)
+		}
+		return nil
+	}
+}
+
+// WithRedirectPolicy sets the policies redirects are checked against, in
+// order; a redirect is followed when all of them allow it. Unless one of them
+// is a MaxRedirects policy, at most 10 redirects are followed.
+//
+// 307 and 308 redirects always repeat the method and body of the original
+// request. Like http.Client, the client changes other methods to GET on 301,
+// 302 and 303.
+func WithRedirectPolicy(policies ...RedirectPolicy) Option {
+	return func(c *HTTPClient) {
+		c.redirectPolicies = append(c.redirectPolicies, policies...)
+	}
+}
+
+// WithSensitiveHeaders adds headers, such as API keys, that are dropped when
+// a redirect leaves the origin of the original request. Authorization,
+// Proxy-Authorization and Cookie always are.
+func WithSensitiveHeaders(headers ...string) Option {
+	return func(c *HTTPClient) {
+		c.sensitiveHeaders = append(c.sensitiveHeaders, headers...)
+	}
+}
+
+var defaultSensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// Redirect is one redirect followed on the way to a response.
+type Redirect struct {
+	StatusCode int
+	// From is the URL that answered with the redirect and To the URL it
+	// pointed to.
+	From string
+	To   string
+}
+
+type redirectsKey struct{}
+
+type cappedKey struct{}
+
+// withRedirects returns a context in which checkRedirect records the
+// redirects followed into the returned slice.
+func withRedirects(ctx context.Context) (context.Context, *[]Redirect) {
+	redirects := new([]Redirect)
+	return context.WithValue(ctx, redirectsKey{}, redirects), redirects
+}
+
+func (c *HTTPClient) checkRedirect(req *http.Request, via []*http.Request) error {
+	// A MaxRedirects policy among them, even wrapped in another, reports
+	// itself through the context.
+	capped := false
+	checked := req.WithContext(context.WithValue(req.Context(), cappedKey{}, &capped))
+	for _, policy := range c.redirectPolicies {
+		if err := policy(checked, via); err != nil {
+			return err
+		}
+	}
+	if !capped {
+		if err := MaxRedirects(defaultMaxRedirects)(req, via); err != nil {
+			return err
+		}
+	}
+
+	// Headers are copied from the original request on every hop, so the
+	// origin to compare with is the first one.
+	if !sameOrigin(req.URL, via[0].URL) {
+		for _, h := range defaultSensitiveHeaders {
+			req.Header.Del(h)
+		}
+		for _, h := range c.sensitiveHeaders {
+			req.Header.Del(h)
+		}
+	}
+
+	if redirects, ok := req.Context().Value(redirectsKey{}).(*[]Redirect); ok && req.Response != nil {
+		*redirects = append(*redirects, Redirect{
+			StatusCode: req.Response.StatusCode,
+			From:       via[len(via)-1].URL.String(),
+			To:         req.URL.String(),
+		})
+	}
+	return nil
+}
+
+func sameOrigin(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(hostPort(a), hostPort(b))
+}
+
+func hostPort(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http":
+		return u.Host + ":80"
+	case "https":
+		return u.Host + ":443"
+	}
+	return u.Host
+}
+
+// replayableBody buffers the body of req when it cannot be read again, so a
+// 307 or 308 redirect can resend it. http.Client returns those redirects
+// unfollowed otherwise.
+func replayableBody(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return fmt.Errorf("read request body failed: %s", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// seen records what each path received.
+type seen struct {
+	mu       sync.Mutex
+	requests map[string]*http.Request
+	bodies   map[string]string
+}
+
+func (s *seen) handler(routes map[string]func(w http.ResponseWriter, r *http.Request)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.requests[r.URL.Path] = r
+		s.bodies[r.URL.Path] = string(body)
+		s.mu.Unlock()
+		if route, ok := routes[r.URL.Path]; ok {
+			route(w, r)
+			return
+		}
+		w.Write([]byte(r.Method + " " + r.URL.Path))
+	})
+}
+
+func newRedirectServers() (*httptest.Server, *httptest.Server, *seen) {
+	s := &seen{requests: make(map[string]*http.Request), bodies: make(map[string]string)}
+	other := httptest.NewServer(s.handler(nil))
+	var origin *httptest.Server
+	origin = httptest.NewServer(s.handler(map[string]func(w http.ResponseWriter, r *http.Request){
+		"/start": func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/moved", http.StatusTemporaryRedirect)
+		},
+		"/moved": func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, other.URL+"/final", http.StatusPermanentRedirect)
+		},
+		"/away": func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, other.URL+"/final", http.StatusFound)
+		},
+	}))
+	return origin, other, s
+}
+
+func TestRedirectChainAndHeaders(t *testing.T) {
+	fmt.Println("TestRedirectChainAndHeaders")
+	origin, other, s := newRedirectServers()
+	defer origin.Close()
+	defer other.Close()
+
+	client := NewHTTPClient(WithSensitiveHeaders("appsecret"))
+	// The body can only be read once; 307 and 308 must still resend it.
+	req, _ := http.NewRequest(http.MethodPost, origin.URL+"/start", io.NopCloser(strings.NewReader(`{"n":1}`)))
+	req.Header.Set("Authorization", "Bearer t")
+	req.Header.Set("appsecret", "194aa")
+	req.Header.Set("appkey", "shipos_959")
+	resp, err := client.DoV2(context.Background(), req)
+	if err != nil || string(resp.Body) != "POST /final" {
+		t.Fatalf("unexpected response %+v, %v", resp, err)
+	}
+
+	if len(resp.Redirects) != 2 ||
+		resp.Redirects[0] != (Redirect{StatusCode: 307, From: origin.URL + "/start", To: origin.URL + "/moved"}) ||
+		resp.Redirects[1] != (Redirect{StatusCode: 308, From: origin.URL + "/moved", To: other.URL + "/final"}) {
+		t.Fatalf("unexpected redirects %+v", resp.Redirects)
+	}
+	for _, path := range []string{"/start", "/moved", "/final"} {
+		if s.bodies[path] != `{"n":1}` || s.requests[path].Method != http.MethodPost {
+			t.Fatalf("%s: got %s with body %q", path, s.requests[path].Method, s.bodies[path])
+		}
+	}
+	moved, final := s.requests["/moved"].Header, s.requests["/final"].Header
+	if moved.Get("Authorization") == "" || moved.Get("appsecret") == "" {
+		t.Fatalf("same-origin redirect lost headers: %v", moved)
+	}
+	if final.Get("Authorization") != "" || final.Get("appsecret") != "" || final.Get("appkey") != "shipos_959" {
+		t.Fatalf("cross-origin redirect kept sensitive headers: %v", final)
+	}
+}
+
+func TestRedirectPolicies(t *testing.T) {
+	fmt.Println("TestRedirectPolicies")
+	origin, other, _ := newRedirectServers()
+	defer origin.Close()
+	defer other.Close()
+	ctx := context.Background()
+
+	get := func(client *HTTPClient, path string) (*HttpResponse, error) {
+		req, _ := http.NewRequest(http.MethodGet, origin.URL+path, nil)
+		return client.DoV2(ctx, req)
+	}
+
+	resp, err := get(NewHTTPClient(WithRedirectPolicy(NoRedirects())), "/start")
+	if err != nil || resp.StatusCode != http.StatusTemporaryRedirect || resp.Header.Get("Location") != "/moved" || len(resp.Redirects) != 0 {
+		t.Fatalf("expected the first redirect, got %+v, %v", resp, err)
+	}
+
+	resp, err = get(NewHTTPClient(WithRedirectPolicy(SameHostRedirects())), "/start")
+	if err != nil || resp.StatusCode != http.StatusPermanentRedirect || len(resp.Redirects) != 1 {
+		t.Fatalf("expected to stop at the cross-host redirect, got %+v, %v", resp, err)
+	}
+
+	if _, err := get(NewHTTPClient(WithRedirectPolicy(MaxRedirects(1))), "/start"); err == nil ||
+		!strings.Contains(err.Error(), "stopped after 1 redirects") {
+		t.Fatalf("expected too many redirects, got %v", err)
+	}
+
+	// 302 turns a POST into a GET, as with http.Client.
+	body, err := NewHTTPClient().Post(ctx, origin.URL+"/away", []byte("{}"))
+	if err != nil || string(body) != "GET /final" {
+		t.Fatalf("unexpected response %q, %v", body, err)
+	}
+}
+
+func TestRedirectCap(t *testing.T) {
+	fmt.Println("TestRedirectCap")
+	var mu sync.Mutex
+	hops := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hops++
+		mu.Unlock()
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	}))
+	defer server.Close()
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		n := hops
+		hops = 0
+		return n
+	}
+
+	// Other policies keep the default cap on a same-host loop.
+	_, err := NewHTTPClient(WithRedirectPolicy(SameHostRedirects())).Get(context.Background(), server.URL)
+	if err == nil || !strings.Contains(err.Error(), "stopped after 10 redirects") || count() != 11 {
+		t.Fatalf("expected the default cap, got %v", err)
+	}
+
+	// A MaxRedirects policy replaces it.
+	_, err = NewHTTPClient(WithRedirectPolicy(SameHostRedirects(), MaxRedirects(12))).Get(context.Background(), server.URL)
+	if err == nil || !strings.Contains(err.Error(), "stopped after 12 redirects") || count() != 13 {
+		t.Fatalf("expected the policy's cap, got %v", err)
+	}
+}
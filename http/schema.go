@@ -0,0 +1,274 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// SchemaRegistry holds the JSON Schemas, draft 2020-12, that the responses
+// of endpoints must match. Register schemas, then pass the registry to
+// WithResponseSchemas:
+//
+//	schemas := httpclient.NewSchemaRegistry()
+//	err := schemas.Register(http.MethodGet, "/v1/orders/{id}", orderSchema)
+//	client := httpclient.NewHTTPClient(httpclient.WithResponseSchemas(schemas))
+type SchemaRegistry struct {
+	mu        sync.RWMutex
+	endpoints []schemaEndpoint
+}
+
+type schemaEndpoint struct {
+	method   string
+	endpoint string
+	host     string
+	segments []string
+	schema   *jsonschema.Schema
+}
+
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{}
+}
+
+// Register compiles schema for the responses of method, or of every method
+// when it is empty, to endpoint. An endpoint is a path like
+// "/v1/orders/{id}", where each {name} segment matches any one segment,
+// optionally prefixed with a scheme and host. It also matches requests whose
+// route label, see WithRoute, equals it. A later registration for the same
+// method and endpoint replaces the earlier one.
+func (r *SchemaRegistry) Register(method string, endpoint string, schema []byte) error {
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+	location := "schema.json"
+	if err := compiler.AddResource(location, bytes.NewReader(schema)); err != nil {
+		return fmt.Errorf("add schema for %s %s failed: %s", method, endpoint, err)
+	}
+	compiled, err := compiler.Compile(location)
+	if err != nil {
+		return fmt.Errorf("compile schema for %s %s failed: %s", method, endpoint, err)
+	}
+
+	e := schemaEndpoint{method: strings.ToUpper(method), endpoint: endpoint, schema: compiled}
+	path := endpoint
+	if i := strings.Index(path, "://"); i >= 0 {
+		path = path[i+3:]
+		if j := strings.IndexByte(path, '/'); j >= 0 {
+			e.host, path = path[:j], path[j:]
+		} else {
+			e.host, path = path, "/"
+		}
+	}
+	e.segments = strings.Split(strings.Trim(path, "/"), "/")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, existing := range r.endpoints {
+		if existing.method == e.method && existing.endpoint == endpoint {
+			r.endpoints[i] = e
+			return nil
+		}
+	}
+	r.endpoints = append(r.endpoints, e)
+	return nil
+}
+
+// lookup returns the endpoint a response to method and u, labelled with
+// route, must match. Exact route matches win over path matches, and
+// endpoints registered for method over those registered for every method.
+func (r *SchemaRegistry) lookup(method string, u *url.URL, route string) *schemaEndpoint {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var best *schemaEndpoint
+	bestScore := 0
+	for i := range r.endpoints {
+		e := &r.endpoints[i]
+		if e.method != "" && e.method != method {
+			continue
+		}
+		score := 0
+		if route != "" && e.endpoint == route {
+			score = 4
+		} else if e.matches(u) {
+			score = 2
+		}
+		if score == 0 {
+			continue
+		}
+		if e.method != "" {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = e, score
+		}
+	}
+	return best
+}
+
+func (e *schemaEndpoint) matches(u *url.URL) bool {
+	if e.host != "" && !strings.EqualFold(e.host, u.Host) {
+		return false
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(segments) != len(e.segments) {
+		return false
+	}
+	for i, segment := range e.segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			continue
+		}
+		if segment != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// SchemaViolation is one way a response breaks its schema.
+type SchemaViolation struct {
+	// Pointer is the JSON pointer of the offending value in the response
+	// body, "" for the whole body.
+	Pointer string
+	// Keyword is the JSON pointer of the failing keyword in the schema.
+	Keyword string
+	Message string
+}
+
+// SchemaError is returned when a response body does not match the schema
+// registered for its endpoint.
+type SchemaError struct {
+	Method     string
+	URL        string
+	Endpoint   string
+	Violations []SchemaViolation
+}
+
+func (e *SchemaError) Error() string {
+	parts := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		parts[i] = fmt.Sprintf("%q: %s", v.Pointer, v.Message)
+	}
+	return fmt.Sprintf("response of %s %s does not match the schema of %s: %s",
+		e.Method, e.URL, e.Endpoint, strings.Join(parts, "; "))
+}
+
+// WithResponseSchemas validates the successful response bodies of every
+// request the client sends, whichever helper sends it, against the schemas
+// registered for their endpoints. Responses without a schema, empty or in a
+// format with a non-JSON codec, like XML, are not checked. A mismatch fails
+// the request with an error wrapping a *SchemaError, unless
+// WithSchemaWarnings is given too.
+func WithResponseSchemas(schemas *SchemaRegistry) Option {
+	return func(c *HTTPClient) {
+		c.schemas = schemas
+	}
+}
+
+// WithSchemaWarnings reports schema mismatches to warn, or to the standard
+// logger when warn is nil, and decodes the response anyway. Use it to
+// observe drift in an upstream contract before enforcing it.
+func WithSchemaWarnings(warn func(*SchemaError)) Option {
+	return func(c *HTTPClient) {
+		if warn == nil {
+			warn = func(err *SchemaError) {
+				log.Printf("warning: %s", err)
+			}
+		}
+		c.schemaWarn = warn
+	}
+}
+
+// validating checks the responses coming back through next against the
+// client's schemas. It sits above the middleware, which never sees a
+// response rejected for its schema.
+func (c *HTTPClient) validating(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := next.RoundTrip(req)
+		if err != nil || resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+			return resp, err
+		}
+		if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+			if codec, ok := c.codecRegistry().Lookup(contentType); ok && codec.ContentType() != JSONContentType {
+				return resp, nil
+			}
+		}
+		route := RouteFromContext(req.Context())
+		if c.schemas.lookup(req.Method, req.URL, route) == nil {
+			return resp, nil
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read response body failed: %s", err)
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		if len(body) == 0 {
+			return resp, nil
+		}
+		if err := c.validateResponse(req.Method, req.URL, route, body); err != nil {
+			return nil, err
+		}
+		return resp, nil
+	})
+}
+
+// validateResponse checks body, a JSON response to method and u, against
+// its schema. It returns nil in warn-only mode.
+func (c *HTTPClient) validateResponse(method string, u *url.URL, route string, body []byte) error {
+	if c.schemas == nil {
+		return nil
+	}
+	endpoint := c.schemas.lookup(method, u, route)
+	if endpoint == nil {
+		return nil
+	}
+
+	var schemaErr *SchemaError
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		schemaErr = &SchemaError{Violations: []SchemaViolation{{Message: fmt.Sprintf("invalid json: %s", err)}}}
+	} else if err := endpoint.schema.Validate(doc); err != nil {
+		validationErr, ok := err.(*jsonschema.ValidationError)
+		if !ok {
+			return fmt.Errorf("validate response failed: %s", err)
+		}
+		found := violations(validationErr, nil)
+		sort.SliceStable(found, func(i, j int) bool { return found[i].Pointer < found[j].Pointer })
+		schemaErr = &SchemaError{Violations: found}
+	}
+	if schemaErr == nil {
+		return nil
+	}
+	schemaErr.Method, schemaErr.URL, schemaErr.Endpoint = method, u.String(), endpoint.endpoint
+	if c.schemaWarn != nil {
+		c.schemaWarn(schemaErr)
+		return nil
+	}
+	return schemaErr
+}
+
+// violations flattens the leaves of a validation error tree.
+func violations(err *jsonschema.ValidationError, out []SchemaViolation) []SchemaViolation {
+	if len(err.Causes) == 0 {
+		out = append(out, SchemaViolation{
+			Pointer: err.InstanceLocation,
+			Keyword: err.KeywordLocation,
+			Message: err.Message,
+		})
+	}
+	for _, cause := range err.Causes {
+		out = violations(cause, out)
+	}
+	return out
+}
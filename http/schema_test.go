@@ -0,0 +1,136 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const orderSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["id", "items"],
+	"properties": {
+		"id": {"type": "integer"},
+		"items": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"required": ["sku"],
+				"properties": {"sku": {"type": "string"}, "qty": {"type": "integer", "minimum": 1}}
+			}
+		}
+	}
+}`
+
+type order struct {
+	ID    interface{} `json:"id"`
+	Items []struct {
+		SKU string `json:"sku"`
+		Qty int    `json:"qty"`
+	} `json:"items"`
+}
+
+func newOrderServer(body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+}
+
+func TestResponseSchemas(t *testing.T) {
+	fmt.Println("TestResponseSchemas")
+	schemas := NewSchemaRegistry()
+	if err := schemas.Register(http.MethodGet, "/v1/orders/{id}", []byte(orderSchema)); err != nil {
+		t.Fatalf("failed to register schema: %v", err)
+	}
+	if err := schemas.Register("", "/v1/orders/{id}", []byte(`{"type": "array"}`)); err != nil {
+		t.Fatalf("failed to register schema: %v", err)
+	}
+	if err := schemas.Register(http.MethodGet, "/v1/orders/{id}", []byte(`{"type": 1}`)); err == nil {
+		t.Fatalf("expected an invalid schema to be rejected")
+	}
+	client := NewHTTPClient(WithResponseSchemas(schemas))
+	ctx := context.Background()
+
+	good := newOrderServer(`{"id": 7, "items": [{"sku": "A-1", "qty": 2}]}`)
+	defer good.Close()
+	var out order
+	if err := client.GetDecoded(ctx, good.URL+"/v1/orders/7", nil, &out); err != nil || len(out.Items) != 1 {
+		t.Fatalf("failed to get a valid order: %+v, %v", out, err)
+	}
+
+	drifted := newOrderServer(`{"id": "7", "items": [{"qty": 0}]}`)
+	defer drifted.Close()
+	err := client.GetDecoded(ctx, drifted.URL+"/v1/orders/7", nil, &out)
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) || schemaErr.Endpoint != "/v1/orders/{id}" || schemaErr.Method != http.MethodGet {
+		t.Fatalf("expected a schema error, got %v", err)
+	}
+	pointers := map[string]bool{}
+	for _, v := range schemaErr.Violations {
+		pointers[v.Pointer] = true
+	}
+	if len(schemaErr.Violations) != 3 || !pointers["/id"] || !pointers["/items/0"] || !pointers["/items/0/qty"] {
+		t.Fatalf("unexpected violations %+v", schemaErr.Violations)
+	}
+
+	// Other paths, and other methods without a schema of their own, fall to
+	// the method-less schema or none.
+	if err := client.GetDecoded(ctx, drifted.URL+"/v1/orders/7/items", nil, &out); err != nil {
+		t.Fatalf("expected an unregistered path to pass, got %v", err)
+	}
+	if err := client.SendEncoded(ctx, http.MethodPut, drifted.URL+"/v1/orders/7", nil, nil, nil); !errors.As(err, &schemaErr) ||
+		schemaErr.Violations[0].Keyword != "/type" {
+		t.Fatalf("expected the method-less schema to apply, got %v", err)
+	}
+}
+
+func TestResponseSchemaRoute(t *testing.T) {
+	fmt.Println("TestResponseSchemaRoute")
+	server := newOrderServer(`{"id": 7}`)
+	defer server.Close()
+	schemas := NewSchemaRegistry()
+	route := server.URL + "/v1/orders/{id}"
+	if err := schemas.Register(http.MethodGet, route, []byte(orderSchema)); err != nil {
+		t.Fatalf("failed to register schema: %v", err)
+	}
+	client := NewHTTPClient(WithResponseSchemas(schemas))
+
+	// The full URL pattern matches by host and path, and by route label.
+	var schemaErr *SchemaError
+	var out order
+	if err := client.GetDecoded(context.Background(), server.URL+"/v1/orders/7", nil, &out); !errors.As(err, &schemaErr) {
+		t.Fatalf("expected a schema error, got %v", err)
+	}
+	ctx := WithRoute(context.Background(), route)
+	if err := client.GetDecoded(ctx, server.URL+"/v2/orders?id=7", nil, &out); !errors.As(err, &schemaErr) ||
+		schemaErr.Violations[0].Pointer != "" {
+		t.Fatalf("expected a schema error for the route, got %v", err)
+	}
+}
+
+func TestSchemaWarnings(t *testing.T) {
+	fmt.Println("TestSchemaWarnings")
+	server := newOrderServer(`{"id": "7", "items": []}`)
+	defer server.Close()
+	schemas := NewSchemaRegistry()
+	if err := schemas.Register(http.MethodGet, "/v1/orders/{id}", []byte(orderSchema)); err != nil {
+		t.Fatalf("failed to register schema: %v", err)
+	}
+	var warnings []*SchemaError
+	client := NewHTTPClient(WithResponseSchemas(schemas), WithSchemaWarnings(func(err *SchemaError) {
+		warnings = append(warnings, err)
+	}))
+
+	var out order
+	if err := client.GetDecoded(context.Background(), server.URL+"/v1/orders/7", nil, &out); err != nil || out.ID != "7" {
+		t.Fatalf("expected the drifted order to decode, got %+v, %v", out, err)
+	}
+	if len(warnings) != 1 || warnings[0].Violations[0].Pointer != "/id" {
+		t.Fatalf("unexpected warnings %v", warnings)
+	}
+}
@@ -0,0 +1,92 @@
+package shipos
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Label is one shipping label. Content holds the file base64 encoded,
+// sometimes as a data URI.
+type Label struct {
+	TrackingNumber string `json:"tracking_number"`
+	Format         string `json:"format"`
+	Content        string `json:"label"`
+}
+
+var pdfMagic = []byte("%PDF-")
+
+// Decode returns the label file. PDF labels are checked to be PDF documents.
+func (l *Label) Decode() ([]byte, error) {
+	content := strings.TrimSpace(l.Content)
+	if strings.HasPrefix(content, "data:") {
+		_, data, ok := strings.Cut(content, ";base64,")
+		if !ok {
+			return nil, fmt.Errorf("label %s is not a base64 data uri", l.TrackingNumber)
+		}
+		content = data
+	}
+	data, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		// Some carriers send unpadded or URL-safe base64.
+		var rawErr error
+		if data, rawErr = base64.RawURLEncoding.DecodeString(strings.TrimRight(content, "=")); rawErr != nil {
+			return nil, fmt.Errorf("decode label %s failed: %s", l.TrackingNumber, err)
+		}
+	}
+	if l.extension() == "pdf" && !bytes.HasPrefix(data, pdfMagic) {
+		return nil, fmt.Errorf("label %s is not a pdf document", l.TrackingNumber)
+	}
+	return data, nil
+}
+
+// extension is the file extension of the label format, "bin" for formats
+// that cannot be used as one.
+func (l *Label) extension() string {
+	if l.Format == "" {
+		return "pdf"
+	}
+	for _, c := range l.Format {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return "bin"
+		}
+	}
+	return strings.ToLower(l.Format)
+}
+
+// WriteFile decodes the label into dir, naming it after its tracking number,
+// and returns the path written.
+func (l *Label) WriteFile(dir string) (string, error) {
+	data, err := l.Decode()
+	if err != nil {
+		return "", err
+	}
+	name := l.TrackingNumber
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid label tracking number %q", l.TrackingNumber)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("create label directory failed: %s", err)
+	}
+	path := filepath.Join(dir, name+"."+l.extension())
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", fmt.Errorf("write label failed: %s", err)
+	}
+	return path, nil
+}
+
+// SaveLabels writes every label of the response into dir.
+func (r *LabelsResponse) SaveLabels(dir string) ([]string, error) {
+	paths := make([]string, 0, len(r.Labels))
+	for i := range r.Labels {
+		path, err := r.Labels[i].WriteFile(dir)
+		if err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
@@ -0,0 +1,181 @@
+// Package shipos is a client for the shipos shipping label API on top of the
+// http package's HTTPRequester.
+//
+//	client := shipos.NewClient(shipos.DefaultBaseURL, shipos.CredentialsFromEnv(), httpclient.NewHTTPClient())
+//	labels, err := client.GetLabels(ctx, "odr-0878cf33")
+//	paths, err := labels.SaveLabels("labels")
+//
+// The shipostest package runs a fake of the API for tests.
+package shipos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	httpclient "http"
+)
+
+const DefaultBaseURL = "https://api.shipos.cn/api"
+
+// Vendor error codes reported in the response envelope.
+const (
+	CodeOK                 = 0
+	CodeInvalidCredentials = 1001
+	CodeInvalidRequest     = 1002
+	CodeOrderNotFound      = 2001
+	CodeLabelNotReady      = 2002
+	CodeRateLimited        = 4290
+	CodeInternal           = 5000
+)
+
+var (
+	ErrInvalidCredentials = errors.New("shipos: invalid credentials")
+	ErrOrderNotFound      = errors.New("shipos: order not found")
+	ErrLabelNotReady      = errors.New("shipos: label not ready")
+	ErrRateLimited        = errors.New("shipos: rate limited")
+)
+
+// codeErrors maps vendor codes to the sentinel errors an *APIError matches.
+var codeErrors = map[int]error{
+	CodeInvalidCredentials: ErrInvalidCredentials,
+	CodeOrderNotFound:      ErrOrderNotFound,
+	CodeLabelNotReady:      ErrLabelNotReady,
+	CodeRateLimited:        ErrRateLimited,
+}
+
+// APIError is a vendor error. errors.Is matches it against the Err*
+// sentinels of its code.
+type APIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"msg"`
+	// StatusCode is the HTTP status of the response, 200 for most vendor
+	// errors.
+	StatusCode int `json:"-"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("shipos error %d: %s", e.Code, e.Message)
+}
+
+func (e *APIError) Is(target error) bool {
+	return codeErrors[e.Code] == target
+}
+
+// Temporary reports whether the request may succeed when retried later.
+func (e *APIError) Temporary() bool {
+	return e.Code == CodeLabelNotReady || e.Code == CodeRateLimited || e.Code == CodeInternal
+}
+
+// envelope wraps every response.
+type envelope struct {
+	Code    int             `json:"code"`
+	Message string          `json:"msg"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// Credentials authenticate requests through the appkey and appsecret
+// headers.
+type Credentials struct {
+	AppKey    string
+	AppSecret string
+}
+
+// CredentialsFromEnv reads the credentials from SHIPOS_APPKEY and
+// SHIPOS_APPSECRET.
+func CredentialsFromEnv() Credentials {
+	return Credentials{AppKey: os.Getenv("SHIPOS_APPKEY"), AppSecret: os.Getenv("SHIPOS_APPSECRET")}
+}
+
+// Order is an order as shipos reports it.
+type Order struct {
+	ID             string    `json:"orderid"`
+	Status         string    `json:"status"`
+	Carrier        string    `json:"carrier"`
+	TrackingNumber string    `json:"tracking_number"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// LabelsRequest is the body of getLabels.php.
+type LabelsRequest struct {
+	OrderID string `json:"orderid"`
+	// Format is "pdf" (the default), "png" or "zpl".
+	Format string `json:"format,omitempty"`
+}
+
+// LabelsResponse is the data of a getLabels.php response.
+type LabelsResponse struct {
+	Order  Order   `json:"order"`
+	Labels []Label `json:"labels"`
+}
+
+// Client calls the shipos API.
+type Client struct {
+	baseURL     string
+	credentials Credentials
+	requester   httpclient.HTTPRequester
+}
+
+func NewClient(baseURL string, credentials Credentials, requester httpclient.HTTPRequester) *Client {
+	return &Client{baseURL: strings.TrimSuffix(baseURL, "/"), credentials: credentials, requester: requester}
+}
+
+// GetLabels fetches the shipping labels of an order.
+func (c *Client) GetLabels(ctx context.Context, orderID string) (*LabelsResponse, error) {
+	return c.GetLabelsWithRequest(ctx, &LabelsRequest{OrderID: orderID})
+}
+
+func (c *Client) GetLabelsWithRequest(ctx context.Context, req *LabelsRequest) (*LabelsResponse, error) {
+	var resp LabelsResponse
+	if err := c.call(ctx, "getLabels.php", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// call posts in to endpoint and decodes the data of the response into out.
+// Vendor errors are returned as *APIError, whatever the HTTP status.
+func (c *Client) call(ctx context.Context, endpoint string, in interface{}, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("encode shipos request failed: %s", err)
+	}
+	headers := map[string]string{
+		"Content-Type": "application/json",
+		"appkey":       c.credentials.AppKey,
+		"appsecret":    c.credentials.AppSecret,
+	}
+	resp, err := c.requester.PostWithHeader(ctx, c.baseURL+"/"+endpoint, body, headers)
+	statusCode := 200
+	if err != nil {
+		var statusErr *httpclient.StatusError
+		if !errors.As(err, &statusErr) {
+			return err
+		}
+		// Error statuses usually still carry the envelope.
+		var env envelope
+		if json.Unmarshal(statusErr.Body, &env) != nil || env.Code == CodeOK {
+			return err
+		}
+		resp, statusCode = statusErr.Body, statusErr.StatusCode
+	}
+
+	var env envelope
+	if err := json.Unmarshal(resp, &env); err != nil {
+		return fmt.Errorf("decode shipos response failed: %s", err)
+	}
+	if env.Code != CodeOK {
+		return &APIError{Code: env.Code, Message: env.Message, StatusCode: statusCode}
+	}
+	if out == nil || len(env.Data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(env.Data, out); err != nil {
+		return fmt.Errorf("decode shipos %s data failed: %s", endpoint, err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package shipos
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	httpclient "http"
+	"http/shipos/shipostest"
+)
+
+var testCredentials = Credentials{AppKey: "shipos_959", AppSecret: "secret"}
+
+func newTestClient(t *testing.T) (*Client, *shipostest.Server) {
+	server := shipostest.NewServer(testCredentials.AppKey, testCredentials.AppSecret)
+	t.Cleanup(server.Close)
+	return NewClient(server.BaseURL(), testCredentials, httpclient.NewHTTPClient()), server
+}
+
+func TestGetLabels(t *testing.T) {
+	fmt.Println("TestGetLabels")
+	client, server := newTestClient(t)
+	server.AddOrder("odr-1",
+		shipostest.Label{TrackingNumber: "SP001", Content: shipostest.PDF("SP001")},
+		shipostest.Label{TrackingNumber: "SP002", Format: "zpl", Content: []byte("^XA^FDSP002^FS^XZ")})
+
+	resp, err := client.GetLabels(context.Background(), "odr-1")
+	if err != nil {
+		t.Fatalf("failed to get labels: %v", err)
+	}
+	if resp.Order.ID != "odr-1" || resp.Order.TrackingNumber != "SP001" || resp.Order.CreatedAt.IsZero() || len(resp.Labels) != 2 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+
+	dir := t.TempDir()
+	paths, err := resp.SaveLabels(dir)
+	if err != nil || len(paths) != 2 || paths[0] != filepath.Join(dir, "SP001.pdf") || paths[1] != filepath.Join(dir, "SP002.zpl") {
+		t.Fatalf("unexpected paths %q, %v", paths, err)
+	}
+	if data, _ := os.ReadFile(paths[0]); !bytes.Equal(data, shipostest.PDF("SP001")) {
+		t.Fatalf("unexpected pdf %q", data)
+	}
+}
+
+func TestVendorErrors(t *testing.T) {
+	fmt.Println("TestVendorErrors")
+	client, server := newTestClient(t)
+	server.AddOrder("odr-pending")
+	ctx := context.Background()
+
+	_, err := client.GetLabels(ctx, "odr-missing")
+	if !errors.Is(err, ErrOrderNotFound) {
+		t.Fatalf("expected order not found, got %v", err)
+	}
+
+	_, err = client.GetLabels(ctx, "odr-pending")
+	var apiErr *APIError
+	if !errors.Is(err, ErrLabelNotReady) || !errors.As(err, &apiErr) || !apiErr.Temporary() {
+		t.Fatalf("expected a temporary label not ready error, got %v", err)
+	}
+
+	server.FailNext(http.StatusTooManyRequests, CodeRateLimited, "slow down")
+	_, err = client.GetLabels(ctx, "odr-pending")
+	if !errors.Is(err, ErrRateLimited) || !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected rate limited, got %v", err)
+	}
+
+	wrong := NewClient(server.BaseURL(), Credentials{AppKey: "shipos_959", AppSecret: "wrong"}, httpclient.NewHTTPClient())
+	if _, err := wrong.GetLabels(ctx, "odr-pending"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected invalid credentials, got %v", err)
+	}
+
+	// Error statuses without an envelope stay *httpclient.StatusError.
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte("<html>bad gateway</html>"))
+	}))
+	defer gateway.Close()
+	client = NewClient(gateway.URL, testCredentials, httpclient.NewHTTPClient())
+	var statusErr *httpclient.StatusError
+	if _, err := client.GetLabels(ctx, "odr-1"); !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected a 502 status error, got %v", err)
+	}
+}
+
+func TestLabelDecode(t *testing.T) {
+	fmt.Println("TestLabelDecode")
+	pdf := shipostest.PDF("x")
+	for _, content := range []string{
+		base64.StdEncoding.EncodeToString(pdf),
+		"data:application/pdf;base64," + base64.StdEncoding.EncodeToString(pdf),
+		base64.RawURLEncoding.EncodeToString(pdf),
+	} {
+		l := Label{TrackingNumber: "SP001", Content: content}
+		if data, err := l.Decode(); err != nil || !bytes.Equal(data, pdf) {
+			t.Fatalf("failed to decode %q: %v", content, err)
+		}
+	}
+
+	notPDF := Label{TrackingNumber: "SP001", Content: base64.StdEncoding.EncodeToString([]byte("<html>"))}
+	if _, err := notPDF.Decode(); err == nil {
+		t.Fatalf("expected a non pdf label to be rejected")
+	}
+	escape := Label{TrackingNumber: "../SP001", Content: base64.StdEncoding.EncodeToString(pdf)}
+	if _, err := escape.WriteFile(t.TempDir()); err == nil {
+		t.Fatalf("expected a path in the tracking number to be rejected")
+	}
+}
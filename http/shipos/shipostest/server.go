@@ -0,0 +1,191 @@
+// Package shipostest runs a fake of the shipos label API for tests that must
+// not reach api.shipos.cn. It only depends on the standard library, so tests
+// of the http package itself can use it.
+package shipostest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Vendor error codes, as in the shipos package.
+const (
+	codeOK                 = 0
+	codeInvalidCredentials = 1001
+	codeInvalidRequest     = 1002
+	codeOrderNotFound      = 2001
+	codeLabelNotReady      = 2002
+)
+
+// Label is a label served for an order.
+type Label struct {
+	TrackingNumber string
+	// Format defaults to "pdf".
+	Format  string
+	Content []byte
+}
+
+type order struct {
+	id     string
+	labels []Label
+	// ready is false for orders whose labels are still being generated.
+	ready   bool
+	created time.Time
+}
+
+// failure is a canned error response.
+type failure struct {
+	status  int
+	code    int
+	message string
+}
+
+// Server is a running fake listening on a local address. Its API base URL
+// is URL + "/api".
+type Server struct {
+	*httptest.Server
+
+	appKey    string
+	appSecret string
+
+	mu       sync.Mutex
+	orders   map[string]*order
+	failures []failure
+	requests int
+}
+
+// NewServer starts a fake that accepts the given credentials. Callers should
+// Close it.
+func NewServer(appKey string, appSecret string) *Server {
+	s := &Server{appKey: appKey, appSecret: appSecret, orders: make(map[string]*order)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// BaseURL is the API base URL to configure clients with.
+func (s *Server) BaseURL() string {
+	return s.URL + "/api"
+}
+
+// AddOrder registers an order with its labels. An order without labels
+// answers as not ready yet.
+func (s *Server) AddOrder(orderID string, labels ...Label) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.orders[orderID] = &order{id: orderID, labels: labels, ready: len(labels) > 0, created: time.Now().UTC().Truncate(time.Second)}
+}
+
+// FailNext makes the next request fail with the given HTTP status and vendor
+// error code. Failures queue up in the order they are added.
+func (s *Server) FailNext(status int, code int, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, failure{status: status, code: code, message: message})
+}
+
+// Requests returns the number of requests served.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// PDF returns a minimal one-page PDF document showing text.
+func PDF(text string) []byte {
+	stream := fmt.Sprintf("BT /F1 24 Tf 72 720 Td (%s) Tj ET", text)
+	return []byte(fmt.Sprintf("%%PDF-1.4\n"+
+		"1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj\n"+
+		"2 0 obj << /Type /Pages /Kids [3 0 R] /Count 1 >> endobj\n"+
+		"3 0 obj << /Type /Page /Parent 2 0 R /MediaBox [0 0 288 432] /Contents 4 0 R >> endobj\n"+
+		"4 0 obj << /Length %d >> stream\n%s\nendstream endobj\n"+
+		"trailer << /Root 1 0 R >>\n%%%%EOF\n", len(stream), stream))
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests++
+	var fail *failure
+	if len(s.failures) > 0 {
+		fail = &s.failures[0]
+		s.failures = s.failures[1:]
+	}
+	s.mu.Unlock()
+
+	if fail != nil {
+		writeEnvelope(w, fail.status, fail.code, fail.message, nil)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeEnvelope(w, http.StatusMethodNotAllowed, codeInvalidRequest, "method not allowed", nil)
+		return
+	}
+	if r.Header.Get("appkey") != s.appKey || r.Header.Get("appsecret") != s.appSecret {
+		writeEnvelope(w, http.StatusOK, codeInvalidCredentials, "invalid appkey or appsecret", nil)
+		return
+	}
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/getLabels.php"):
+		s.getLabels(w, r)
+	default:
+		writeEnvelope(w, http.StatusNotFound, codeInvalidRequest, "unknown endpoint", nil)
+	}
+}
+
+func (s *Server) getLabels(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		OrderID string `json:"orderid"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.OrderID == "" {
+		writeEnvelope(w, http.StatusOK, codeInvalidRequest, "orderid is required", nil)
+		return
+	}
+
+	s.mu.Lock()
+	o, ok := s.orders[req.OrderID]
+	s.mu.Unlock()
+	if !ok {
+		writeEnvelope(w, http.StatusOK, codeOrderNotFound, "order not found", nil)
+		return
+	}
+	if !o.ready {
+		writeEnvelope(w, http.StatusOK, codeLabelNotReady, "label is being generated", nil)
+		return
+	}
+
+	type label struct {
+		TrackingNumber string `json:"tracking_number"`
+		Format         string `json:"format"`
+		Label          string `json:"label"`
+	}
+	labels := make([]label, 0, len(o.labels))
+	for _, l := range o.labels {
+		format := l.Format
+		if format == "" {
+			format = "pdf"
+		}
+		labels = append(labels, label{TrackingNumber: l.TrackingNumber, Format: format, Label: base64.StdEncoding.EncodeToString(l.Content)})
+	}
+	writeEnvelope(w, http.StatusOK, codeOK, "success", map[string]interface{}{
+		"order": map[string]interface{}{
+			"orderid":         o.id,
+			"status":          "label_created",
+			"carrier":         "shipos",
+			"tracking_number": o.labels[0].TrackingNumber,
+			"created_at":      o.created,
+		},
+		"labels": labels,
+	})
+}
+
+func writeEnvelope(w http.ResponseWriter, status int, code int, message string, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"code": code, "msg": message, "data": data})
+}
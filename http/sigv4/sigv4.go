@@ -0,0 +1,365 @@
+// Package sigv4 signs requests with AWS Signature Version 4, for S3 and the
+// other endpoints that speak it:
+//
+//	signer := sigv4.NewSigner(sigv4.Credentials{AccessKeyID: id, SecretAccessKey: secret}, "us-east-1", "s3")
+//	client := httpclient.NewHTTPClient(httpclient.WithMiddleware(signer.Middleware))
+//
+// By default the payload is hashed into the signature. WithPayload switches
+// to UNSIGNED-PAYLOAD, which leaves the body unread, or to aws-chunked
+// streaming, which signs the body chunk by chunk as it is sent.
+package sigv4
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	httpclient "http"
+)
+
+const (
+	algorithm  = "AWS4-HMAC-SHA256"
+	timeFormat = "20060102T150405Z"
+	dateFormat = "20060102"
+
+	// UnsignedPayload is the payload hash of requests signed without their
+	// body.
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+	// StreamingPayload is the payload hash of aws-chunked requests.
+	StreamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+
+	emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// Payload says how the request body is covered by the signature.
+type Payload int
+
+const (
+	// SignedPayload hashes the whole body before the request is sent.
+	SignedPayload Payload = iota
+	// Unsigned signs the request without its body.
+	Unsigned
+	// Streaming sends the body in aws-chunked encoding, each chunk signed
+	// with the signature of the one before. The request needs a known
+	// ContentLength.
+	Streaming
+)
+
+// Credentials are the keys a request is signed with. SessionToken is set for
+// temporary credentials.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// ignoredHeaders are left out of the signature because proxies and the
+// transport may change them.
+var ignoredHeaders = map[string]bool{
+	"authorization":     true,
+	"user-agent":        true,
+	"x-amzn-trace-id":   true,
+	"expect":            true,
+	"connection":        true,
+	"accept-encoding":   true,
+	"content-length":    true,
+	"transfer-encoding": true,
+}
+
+// Signer signs requests for one region and service.
+type Signer struct {
+	credentials func() (Credentials, error)
+	region      string
+	service     string
+	payload     Payload
+	chunkSize   int
+	now         func() time.Time
+}
+
+type Option func(*Signer)
+
+// WithPayload sets how the body is signed, SignedPayload by default.
+func WithPayload(payload Payload) Option {
+	return func(s *Signer) {
+		s.payload = payload
+	}
+}
+
+// WithChunkSize sets the size of aws-chunked chunks, 64KiB by default. S3
+// requires at least 8KiB.
+func WithChunkSize(size int) Option {
+	return func(s *Signer) {
+		s.chunkSize = size
+	}
+}
+
+// WithCredentialsProvider fetches the credentials for every request, for
+// credentials that rotate.
+func WithCredentialsProvider(provider func() (Credentials, error)) Option {
+	return func(s *Signer) {
+		s.credentials = provider
+	}
+}
+
+// WithClock sets the clock the signing time is read from.
+func WithClock(now func() time.Time) Option {
+	return func(s *Signer) {
+		s.now = now
+	}
+}
+
+func NewSigner(credentials Credentials, region string, service string, opts ...Option) *Signer {
+	s := &Signer{
+		credentials: func() (Credentials, error) { return credentials, nil },
+		region:      region,
+		service:     service,
+		chunkSize:   64 << 10,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Middleware signs the requests sent through next. Its signature matches the
+// http package's Middleware.
+func (s *Signer) Middleware(next http.RoundTripper) http.RoundTripper {
+	return httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		signed := req.Clone(req.Context())
+		if err := s.Sign(signed); err != nil {
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, err
+		}
+		return next.RoundTrip(signed)
+	})
+}
+
+// Sign adds the X-Amz-Date, X-Amz-Security-Token, X-Amz-Content-Sha256 and
+// Authorization headers to req. A signed payload is read into memory and
+// put back; a streaming payload is wrapped in the chunk encoder.
+func (s *Signer) Sign(req *http.Request) error {
+	creds, err := s.credentials()
+	if err != nil {
+		return fmt.Errorf("get aws credentials failed: %s", err)
+	}
+	t := s.now().UTC()
+	scope := strings.Join([]string{t.Format(dateFormat), s.region, s.service, "aws4_request"}, "/")
+
+	var payloadHash string
+	switch s.payload {
+	case Unsigned:
+		payloadHash = UnsignedPayload
+	case Streaming:
+		payloadHash = StreamingPayload
+		if req.Body != nil && req.Body != http.NoBody && req.ContentLength <= 0 {
+			return fmt.Errorf("aws-chunked signing requires a known content length")
+		}
+		decoded := req.ContentLength
+		if decoded < 0 {
+			decoded = 0
+		}
+		req.Header.Set("Content-Encoding", appendEncoding(req.Header.Get("Content-Encoding")))
+		req.Header.Set("X-Amz-Decoded-Content-Length", strconv.FormatInt(decoded, 10))
+		req.ContentLength = chunkedLength(decoded, int64(s.chunkSize))
+	default:
+		body, err := readBody(req)
+		if err != nil {
+			return fmt.Errorf("read request body failed: %s", err)
+		}
+		payloadHash = hashHex(body)
+	}
+
+	req.Header.Set("X-Amz-Date", t.Format(timeFormat))
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	if s.service == "s3" || s.payload != SignedPayload {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	signedHeaders, canonicalHeaders := canonicalHeaders(req, s.payload == Streaming)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL, s.service != "s3"),
+		canonicalQuery(req.URL),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	key := signingKey(creds.SecretAccessKey, t.Format(dateFormat), s.region, s.service)
+	stringToSign := strings.Join([]string{algorithm, t.Format(timeFormat), scope, hashHex([]byte(canonicalRequest))}, "\n")
+	signature := hex.EncodeToString(hmacSHA256(key, []byte(stringToSign)))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, creds.AccessKeyID, scope, signedHeaders, signature))
+
+	if s.payload == Streaming {
+		var body io.Reader = http.NoBody
+		if req.Body != nil {
+			body = req.Body
+		}
+		req.Body = &chunkEncoder{
+			body:      body,
+			closer:    req.Body,
+			chunkSize: s.chunkSize,
+			key:       key,
+			prefix:    "AWS4-HMAC-SHA256-PAYLOAD\n" + t.Format(timeFormat) + "\n" + scope + "\n",
+			previous:  signature,
+		}
+		req.GetBody = nil
+	}
+	return nil
+}
+
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
+
+func appendEncoding(encoding string) string {
+	if encoding == "" {
+		return "aws-chunked"
+	}
+	return "aws-chunked," + encoding
+}
+
+// canonicalHeaders lists the headers covered by the signature. The content
+// length is only signed for aws-chunked requests, whose length S3 checks
+// against the signed value.
+func canonicalHeaders(req *http.Request, withLength bool) (signed string, canonical string) {
+	values := map[string][]string{"host": {hostHeader(req)}}
+	for name, vs := range req.Header {
+		name = strings.ToLower(name)
+		if ignoredHeaders[name] || name == "host" {
+			continue
+		}
+		values[name] = append(values[name], vs...)
+	}
+	if withLength {
+		values["content-length"] = []string{strconv.FormatInt(req.ContentLength, 10)}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		vs := make([]string, len(values[name]))
+		for i, v := range values[name] {
+			vs[i] = strings.Join(strings.Fields(v), " ")
+		}
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(strings.Join(vs, ","))
+		b.WriteByte('\n')
+	}
+	return strings.Join(names, ";"), b.String()
+}
+
+// hostHeader is the Host the transport will send, without a default port.
+func hostHeader(req *http.Request) string {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	if strings.HasSuffix(host, ":80") && req.URL.Scheme == "http" {
+		return strings.TrimSuffix(host, ":80")
+	}
+	if strings.HasSuffix(host, ":443") && req.URL.Scheme == "https" {
+		return strings.TrimSuffix(host, ":443")
+	}
+	return host
+}
+
+// canonicalURI escapes the path; services other than S3 expect it escaped
+// twice.
+func canonicalURI(u *url.URL, twice bool) string {
+	path := u.Path
+	if path == "" {
+		return "/"
+	}
+	path = escapePath(path)
+	if twice {
+		path = escapePath(path)
+	}
+	return path
+}
+
+func canonicalQuery(u *url.URL) string {
+	query, _ := url.ParseQuery(u.RawQuery)
+	pairs := make([]string, 0, len(query))
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, escape(key)+"="+escape(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// escape percent-encodes everything but the RFC 3986 unreserved characters.
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func signingKey(secret, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), []byte(date))
+	key = hmacSHA256(key, []byte(region))
+	key = hmacSHA256(key, []byte(service))
+	return hmacSHA256(key, []byte("aws4_request"))
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
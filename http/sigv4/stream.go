@@ -0,0 +1,87 @@
+package sigv4
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strconv"
+)
+
+// chunkHeaderLength is the length of ";chunk-signature=" plus the signature
+// and the CRLFs around the chunk data.
+const chunkHeaderLength = len(";chunk-signature=") + 64 + 2 + 2
+
+// chunkedLength is the size of the aws-chunked encoding of a body of the
+// given length.
+func chunkedLength(length, chunkSize int64) int64 {
+	chunk := func(size int64) int64 {
+		return int64(len(strconv.FormatInt(size, 16))+chunkHeaderLength) + size
+	}
+	full := length / chunkSize
+	total := full*chunk(chunkSize) + chunk(0)
+	if rest := length % chunkSize; rest > 0 {
+		total += chunk(rest)
+	}
+	return total
+}
+
+// chunkEncoder reads body in aws-chunked encoding. Every chunk is signed
+// over the signature of the previous one, starting with the request
+// signature, and the body ends with a signed empty chunk.
+type chunkEncoder struct {
+	body      io.Reader
+	closer    io.Closer
+	chunkSize int
+	key       []byte
+	prefix    string // algorithm, time and scope lines of the string to sign
+	previous  string
+
+	buf  bytes.Buffer
+	data []byte
+	done bool
+}
+
+func (e *chunkEncoder) Read(p []byte) (int, error) {
+	for e.buf.Len() == 0 {
+		if e.done {
+			return 0, io.EOF
+		}
+		if err := e.nextChunk(); err != nil {
+			return 0, err
+		}
+	}
+	return e.buf.Read(p)
+}
+
+func (e *chunkEncoder) nextChunk() error {
+	if e.data == nil {
+		e.data = make([]byte, e.chunkSize)
+	}
+	n, err := io.ReadFull(e.body, e.data)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	chunk := e.data[:n]
+	sum := sha256.Sum256(chunk)
+	stringToSign := e.prefix + e.previous + "\n" + emptySHA256 + "\n" + hex.EncodeToString(sum[:])
+	e.previous = hex.EncodeToString(hmacSHA256(e.key, []byte(stringToSign)))
+
+	e.buf.WriteString(strconv.FormatInt(int64(n), 16))
+	e.buf.WriteString(";chunk-signature=")
+	e.buf.WriteString(e.previous)
+	e.buf.WriteString("\r\n")
+	e.buf.Write(chunk)
+	e.buf.WriteString("\r\n")
+	if n == 0 {
+		e.done = true
+	}
+	return nil
+}
+
+func (e *chunkEncoder) Close() error {
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
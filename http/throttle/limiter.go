@@ -0,0 +1,143 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket of bytes. One Limiter shared by several
+// transfers, or several clients, caps their combined bandwidth.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64 // bytes per second, 0 for unlimited
+	burst  int
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// NewLimiter allows bytesPerSecond bytes per second, or any rate when it is
+// zero. Transfers are split into bursts of a tenth of a second, at least
+// 1KiB and at most 64KiB.
+func NewLimiter(bytesPerSecond int64) *Limiter {
+	l := &Limiter{now: time.Now}
+	l.SetLimit(bytesPerSecond)
+	l.tokens = float64(l.burst)
+	return l
+}
+
+// SetLimit changes the rate. Transfers already waiting keep their current
+// wait, which is at most one burst long.
+func (l *Limiter) SetLimit(bytesPerSecond int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.advance(l.now())
+	l.rate = float64(bytesPerSecond)
+	l.burst = int(bytesPerSecond / 10)
+	if l.burst < 1<<10 {
+		l.burst = 1 << 10
+	}
+	if l.burst > 64<<10 {
+		l.burst = 64 << 10
+	}
+	if l.tokens > float64(l.burst) {
+		l.tokens = float64(l.burst)
+	}
+}
+
+// Limit returns the rate in bytes per second, 0 when unlimited.
+func (l *Limiter) Limit() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int64(l.rate)
+}
+
+// Burst returns the most bytes transferred at once.
+func (l *Limiter) Burst() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.burst
+}
+
+// advance adds the tokens earned since the last call. l.mu is held.
+func (l *Limiter) advance(now time.Time) {
+	if elapsed := now.Sub(l.last); !l.last.IsZero() && elapsed > 0 && l.rate > 0 {
+		l.tokens += elapsed.Seconds() * l.rate
+		if l.tokens > float64(l.burst) {
+			l.tokens = float64(l.burst)
+		}
+	}
+	l.last = now
+}
+
+// reserve takes n tokens and returns how long to wait before they are
+// available.
+func (l *Limiter) reserve(n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate <= 0 {
+		return 0
+	}
+	l.advance(l.now())
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// cancel returns n reserved tokens.
+func (l *Limiter) cancel(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate > 0 {
+		l.tokens += float64(n)
+	}
+}
+
+// WaitN blocks until n bytes may be transferred or ctx is done. n should not
+// exceed Burst.
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	return waitAll(ctx, []*Limiter{l}, n)
+}
+
+// waitAll reserves n bytes from every limiter and waits for the slowest.
+func waitAll(ctx context.Context, limiters []*Limiter, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	var wait time.Duration
+	for _, l := range limiters {
+		if d := l.reserve(n); d > wait {
+			wait = d
+		}
+	}
+	if wait == 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		for _, l := range limiters {
+			l.cancel(n)
+		}
+		return ctx.Err()
+	}
+}
+
+// chunkSize is the most bytes to move at once under limiters. Unlimited
+// limiters do not split transfers.
+func chunkSize(limiters []*Limiter, max int) int {
+	for _, l := range limiters {
+		l.mu.Lock()
+		if l.rate > 0 && l.burst < max {
+			max = l.burst
+		}
+		l.mu.Unlock()
+	}
+	return max
+}
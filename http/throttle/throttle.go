@@ -0,0 +1,158 @@
+// Package throttle caps the bandwidth of HTTPClient transfers with token
+// buckets:
+//
+//	uplink := throttle.NewLimiter(512 << 10) // 512KiB/s
+//	t := throttle.New(uplink, nil)
+//	client := httpclient.NewHTTPClient(httpclient.WithMiddleware(t.Middleware))
+//
+//	// A single slow request, on top of the client limits:
+//	ctx = throttle.WithRequestLimits(ctx, nil, throttle.NewLimiter(64<<10))
+//
+// Limiters can be shared by clients and changed with SetLimit while
+// transfers run.
+package throttle
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	httpclient "http"
+)
+
+// Reader limits reads from r by limiters; ctx cancels waits.
+func Reader(ctx context.Context, r io.Reader, limiters ...*Limiter) io.Reader {
+	return &reader{ctx: ctx, r: r, limiters: compact(limiters)}
+}
+
+// Writer limits writes to w by limiters; ctx cancels waits.
+func Writer(ctx context.Context, w io.Writer, limiters ...*Limiter) io.Writer {
+	return &writer{ctx: ctx, w: w, limiters: compact(limiters)}
+}
+
+func compact(limiters []*Limiter) []*Limiter {
+	out := make([]*Limiter, 0, len(limiters))
+	for _, l := range limiters {
+		if l != nil {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+type reader struct {
+	ctx      context.Context
+	r        io.Reader
+	limiters []*Limiter
+}
+
+// Read waits for the bytes it read, so the first burst goes through at once.
+func (r *reader) Read(p []byte) (int, error) {
+	if len(r.limiters) == 0 {
+		return r.r.Read(p)
+	}
+	if size := chunkSize(r.limiters, len(p)); size < len(p) {
+		p = p[:size]
+	}
+	n, err := r.r.Read(p)
+	if waitErr := waitAll(r.ctx, r.limiters, n); waitErr != nil {
+		return n, waitErr
+	}
+	return n, err
+}
+
+type writer struct {
+	ctx      context.Context
+	w        io.Writer
+	limiters []*Limiter
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		size := chunkSize(w.limiters, len(p))
+		if err := waitAll(w.ctx, w.limiters, size); err != nil {
+			return written, err
+		}
+		n, err := w.w.Write(p[:size])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[size:]
+	}
+	return written, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type requestLimitsKey struct{}
+
+type requestLimits struct {
+	upload, download *Limiter
+}
+
+// WithRequestLimits returns a context whose requests are also limited by
+// upload and download, either of which may be nil. They apply in addition
+// to the limits of the Throttle the request goes through.
+func WithRequestLimits(ctx context.Context, upload, download *Limiter) context.Context {
+	return context.WithValue(ctx, requestLimitsKey{}, requestLimits{upload: upload, download: download})
+}
+
+// Throttle limits the request bodies and response bodies of a client.
+type Throttle struct {
+	upload   *Limiter
+	download *Limiter
+}
+
+// New limits uploads and downloads, either of which may be nil for no
+// limit. The same limiters may be given to several Throttles.
+func New(upload, download *Limiter) *Throttle {
+	return &Throttle{upload: upload, download: download}
+}
+
+// Upload returns the upload limiter, which may be nil.
+func (t *Throttle) Upload() *Limiter {
+	return t.upload
+}
+
+// Download returns the download limiter, which may be nil.
+func (t *Throttle) Download() *Limiter {
+	return t.download
+}
+
+// Middleware throttles the requests sent through next. Its signature
+// matches the http package's Middleware.
+func (t *Throttle) Middleware(next http.RoundTripper) http.RoundTripper {
+	return httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		ctx := req.Context()
+		perRequest, _ := ctx.Value(requestLimitsKey{}).(requestLimits)
+		upload := compact([]*Limiter{t.upload, perRequest.upload})
+		download := compact([]*Limiter{t.download, perRequest.download})
+
+		if len(upload) > 0 && req.Body != nil && req.Body != http.NoBody {
+			limited := req.Clone(ctx)
+			limited.Body = readCloser{Reader(ctx, req.Body, upload...), req.Body}
+			if req.GetBody != nil {
+				limited.GetBody = func() (io.ReadCloser, error) {
+					body, err := req.GetBody()
+					if err != nil {
+						return nil, err
+					}
+					return readCloser{Reader(ctx, body, upload...), body}, nil
+				}
+			}
+			req = limited
+		}
+
+		resp, err := next.RoundTrip(req)
+		if err != nil || len(download) == 0 {
+			return resp, err
+		}
+		resp.Body = readCloser{Reader(ctx, resp.Body, download...), resp.Body}
+		return resp, nil
+	})
+}
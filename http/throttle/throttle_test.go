@@ -0,0 +1,127 @@
+package throttle
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	httpclient "http"
+)
+
+func TestLimiterReserve(t *testing.T) {
+	fmt.Println("TestLimiterReserve")
+	now := time.Unix(0, 0)
+	l := NewLimiter(10 << 10)
+	l.now = func() time.Time { return now }
+
+	if l.Burst() != 1<<10 {
+		t.Fatalf("unexpected burst %d", l.Burst())
+	}
+	if d := l.reserve(1 << 10); d != 0 {
+		t.Fatalf("expected the first burst at once, waited %s", d)
+	}
+	if d := l.reserve(1 << 10); d != 100*time.Millisecond {
+		t.Fatalf("expected to wait 100ms, got %s", d)
+	}
+	now = now.Add(time.Second)
+	// The bucket refilled to one burst, which the pending reservation took.
+	if d := l.reserve(1 << 10); d != 0 {
+		t.Fatalf("expected no wait after a second, got %s", d)
+	}
+
+	l.SetLimit(0)
+	if d := l.reserve(1 << 20); d != 0 || l.Limit() != 0 {
+		t.Fatalf("expected no limit, waited %s", d)
+	}
+}
+
+func newServer(payload []byte) (*httptest.Server, *bytes.Buffer, *sync.Mutex) {
+	var mu sync.Mutex
+	var received bytes.Buffer
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		io.Copy(&received, r.Body)
+		mu.Unlock()
+		w.Write(payload)
+	}))
+	return server, &received, &mu
+}
+
+func TestThrottleDownloadsShareALimit(t *testing.T) {
+	fmt.Println("TestThrottleDownloadsShareALimit")
+	payload := bytes.Repeat([]byte("x"), 40<<10)
+	server, _, _ := newServer(payload)
+	defer server.Close()
+
+	// 200KiB/s with 20KiB bursts: two 40KiB downloads take at least 300ms.
+	download := NewLimiter(200 << 10)
+	client := httpclient.NewHTTPClient(httpclient.WithMiddleware(New(nil, download).Middleware))
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if body, err := client.Get(context.Background(), server.URL); err != nil || len(body) != len(payload) {
+				t.Errorf("unexpected download of %d bytes: %v", len(body), err)
+			}
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond || elapsed > 3*time.Second {
+		t.Fatalf("downloads took %s", elapsed)
+	}
+
+	download.SetLimit(0)
+	start = time.Now()
+	if _, err := client.Get(context.Background(), server.URL); err != nil || time.Since(start) > 200*time.Millisecond {
+		t.Fatalf("expected an unlimited download, took %s: %v", time.Since(start), err)
+	}
+}
+
+func TestThrottleRequestLimits(t *testing.T) {
+	fmt.Println("TestThrottleRequestLimits")
+	server, received, mu := newServer([]byte("ok"))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(httpclient.WithMiddleware(New(nil, nil).Middleware))
+	body := bytes.Repeat([]byte("y"), 30<<10)
+
+	start := time.Now()
+	if _, err := client.Post(context.Background(), server.URL, body); err != nil || time.Since(start) > 200*time.Millisecond {
+		t.Fatalf("expected an unlimited upload, took %s: %v", time.Since(start), err)
+	}
+
+	// 100KiB/s with 10KiB bursts: 30KiB take at least 200ms.
+	ctx := WithRequestLimits(context.Background(), NewLimiter(100<<10), nil)
+	start = time.Now()
+	if _, err := client.Post(ctx, server.URL, body); err != nil {
+		t.Fatalf("failed to upload: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("upload took %s", elapsed)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if received.Len() != 2*len(body) {
+		t.Fatalf("server received %d bytes", received.Len())
+	}
+}
+
+func TestWriterAndCancel(t *testing.T) {
+	fmt.Println("TestWriterAndCancel")
+	var out bytes.Buffer
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	l := NewLimiter(10 << 10)
+	n, err := Writer(ctx, &out, l).Write(bytes.Repeat([]byte("z"), 10<<10))
+	if err != context.DeadlineExceeded || n == 0 || n >= 10<<10 || out.Len() != n {
+		t.Fatalf("expected a partial write cut by the deadline, wrote %d: %v", n, err)
+	}
+}
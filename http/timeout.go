@@ -0,0 +1,33 @@
+package http
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultRequestTimeout bounds every request sent by the package's helpers
+// unless the context says otherwise.
+const DefaultRequestTimeout = 10 * time.Second
+
+type requestTimeoutKey struct{}
+
+// WithRequestTimeout returns a context whose requests are bounded by timeout
+// instead of DefaultRequestTimeout, e.g. for long polls. A timeout of zero
+// leaves them bounded by the context alone.
+func WithRequestTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, requestTimeoutKey{}, timeout)
+}
+
+// withRequestTimeout bounds one call of a helper, and starts counting its
+// attempts for Metrics.
+func withRequestTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx = withAttempts(ctx)
+	timeout, ok := ctx.Value(requestTimeoutKey{}).(time.Duration)
+	if !ok {
+		timeout = DefaultRequestTimeout
+	}
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
@@ -0,0 +1,48 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWithRequestTimeout(t *testing.T) {
+	fmt.Println("TestWithRequestTimeout")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	var deadline time.Time
+	var hasDeadline bool
+	client := NewHTTPClient(WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			deadline, hasDeadline = req.Context().Deadline()
+			return next.RoundTrip(req)
+		})
+	}))
+
+	for _, tc := range []struct {
+		ctx  context.Context
+		want time.Duration
+	}{
+		{context.Background(), DefaultRequestTimeout},
+		{WithRequestTimeout(context.Background(), time.Minute), time.Minute},
+		{WithRequestTimeout(context.Background(), 0), 0},
+	} {
+		start := time.Now()
+		if _, err := client.GetWithHeader(tc.ctx, server.URL, nil); err != nil {
+			t.Fatalf("failed to get: %v", err)
+		}
+		if tc.want == 0 {
+			if hasDeadline {
+				t.Fatalf("expected no deadline, got %s", deadline)
+			}
+			continue
+		}
+		if left := deadline.Sub(start); !hasDeadline || left < tc.want || left > tc.want+time.Second {
+			t.Fatalf("expected a deadline in %s, got %s", tc.want, left)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timings are the phases of one request, measured with httptrace. Phases
+// that did not happen, like DNS for an IP address or all of them on a
+// reused connection, are zero.
+type Timings struct {
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+	// FirstByte is the time from sending the request, including the phases
+	// above, to the first byte of the response.
+	FirstByte time.Duration
+	// Reused reports whether the request went over a kept-alive connection.
+	Reused bool
+	// IdleTime is how long a reused connection had been idle.
+	IdleTime time.Duration
+	// Protocol is the protocol of the response, e.g. "HTTP/2.0".
+	Protocol   string
+	RemoteAddr string
+}
+
+// ConnStats counts the connections requests were sent over.
+type ConnStats struct {
+	New    int64
+	Reused int64
+}
+
+// WithTimings traces the client's requests. DoV2 attaches the timings of
+// the request that got the response to HttpResponse.Timings, and ConnStats
+// counts connection reuse.
+func WithTimings() Option {
+	return func(c *HTTPClient) {
+		c.tracer = &tracer{}
+	}
+}
+
+// ConnStats returns how many requests went over new and reused
+// connections, zero without WithTimings.
+func (c *HTTPClient) ConnStats() ConnStats {
+	if c.tracer == nil {
+		return ConnStats{}
+	}
+	c.tracer.mu.Lock()
+	defer c.tracer.mu.Unlock()
+	return c.tracer.stats
+}
+
+type timingsKey struct{}
+
+// timingsHolder keeps the timings of the last request of a call that got a
+// response.
+type timingsHolder struct {
+	mu      sync.Mutex
+	timings *Timings
+}
+
+func (h *timingsHolder) get() *Timings {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.timings
+}
+
+func (h *timingsHolder) set(timings *Timings) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.timings = timings
+}
+
+func withTimings(ctx context.Context) (context.Context, *timingsHolder) {
+	holder := &timingsHolder{}
+	return context.WithValue(ctx, timingsKey{}, holder), holder
+}
+
+// tracer is the transport timing requests. It sits right above the base
+// transport, so hedges and retries are timed one by one; the hedger keeps
+// the timings of the request that won.
+type tracer struct {
+	next http.RoundTripper
+
+	mu    sync.Mutex
+	stats ConnStats
+}
+
+func (t *tracer) RoundTrip(req *http.Request) (*http.Response, error) {
+	var mu sync.Mutex
+	timings := &Timings{}
+	var dnsStart, connectStart, tlsStart time.Time
+	start := time.Now()
+	since := func(from time.Time) time.Duration {
+		if from.IsZero() {
+			return 0
+		}
+		return time.Since(from)
+	}
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			mu.Lock()
+			dnsStart = time.Now()
+			mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			mu.Lock()
+			timings.DNS = since(dnsStart)
+			mu.Unlock()
+		},
+		ConnectStart: func(string, string) {
+			mu.Lock()
+			connectStart = time.Now()
+			mu.Unlock()
+		},
+		ConnectDone: func(_, _ string, err error) {
+			mu.Lock()
+			if err == nil {
+				timings.Connect = since(connectStart)
+			}
+			mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			tlsStart = time.Now()
+			mu.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			mu.Lock()
+			timings.TLS = since(tlsStart)
+			mu.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			mu.Lock()
+			timings.Reused, timings.IdleTime = info.Reused, info.IdleTime
+			if info.Conn != nil {
+				timings.RemoteAddr = info.Conn.RemoteAddr().String()
+			}
+			mu.Unlock()
+			t.mu.Lock()
+			if info.Reused {
+				t.stats.Reused++
+			} else {
+				t.stats.New++
+			}
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			mu.Lock()
+			timings.FirstByte = time.Since(start)
+			mu.Unlock()
+		},
+	}
+
+	resp, err := t.next.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err != nil {
+		return nil, err
+	}
+	if holder, ok := req.Context().Value(timingsKey{}).(*timingsHolder); ok {
+		mu.Lock()
+		timings.Protocol = resp.Proto
+		copied := *timings
+		mu.Unlock()
+		holder.set(&copied)
+	}
+	return resp, nil
+}
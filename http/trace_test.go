@@ -0,0 +1,96 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithTimings(t *testing.T) {
+	fmt.Println("TestWithTimings")
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	client := NewHTTPClient(WithTransport(server.Client().Transport), WithTimings())
+	ctx := context.Background()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	first, err := client.DoV2(ctx, req)
+	if err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	timings := first.Timings
+	if timings == nil || timings.Reused || timings.Connect <= 0 || timings.TLS <= 0 ||
+		timings.FirstByte < timings.Connect+timings.TLS || timings.Protocol != "HTTP/1.1" || timings.RemoteAddr == "" {
+		t.Fatalf("unexpected timings of a new connection %+v", timings)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, server.URL, nil)
+	second, err := client.DoV2(ctx, req)
+	if err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	if timings := second.Timings; timings == nil || !timings.Reused || timings.Connect != 0 || timings.TLS != 0 || timings.FirstByte <= 0 {
+		t.Fatalf("unexpected timings of a reused connection %+v", timings)
+	}
+	if _, err := client.GetWithHeader(ctx, server.URL, nil); err != nil {
+		t.Fatalf("failed to get: %v", err)
+	}
+	if stats := client.ConnStats(); stats != (ConnStats{New: 1, Reused: 2}) {
+		t.Fatalf("unexpected connection stats %+v", stats)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, server.URL, nil)
+	if resp, err := NewHTTPClient(WithTransport(server.Client().Transport)).DoV2(ctx, req); err != nil || resp.Timings != nil {
+		t.Fatalf("expected no timings without WithTimings, got %+v, %v", resp, err)
+	}
+}
+
+// slowReader waits for done before its first read.
+type slowReader struct {
+	io.Reader
+	done <-chan struct{}
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	<-r.done
+	// Let the tracer record the late response first.
+	time.Sleep(20 * time.Millisecond)
+	return r.Reader.Read(p)
+}
+
+func TestTimingsOfHedgeWinner(t *testing.T) {
+	fmt.Println("TestTimingsOfHedgeWinner")
+	var calls int32
+	loserDone := make(chan struct{})
+	transport := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Request: req}
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// The original request answers late, once the hedge won.
+			<-req.Context().Done()
+			defer close(loserDone)
+			resp.Proto, resp.Body = "HTTP/1.0", io.NopCloser(strings.NewReader("late"))
+			return resp, nil
+		}
+		resp.Proto = "HTTP/2.0"
+		resp.Body = io.NopCloser(&slowReader{Reader: strings.NewReader("hedge"), done: loserDone})
+		return resp, nil
+	})
+	client := NewHTTPClient(WithTransport(transport), WithTimings(), WithHedging(HedgePolicy{Delay: 10 * time.Millisecond}))
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	resp, err := client.DoV2(context.Background(), req)
+	if err != nil || string(resp.Body) != "hedge" {
+		t.Fatalf("expected the hedge to win, got %+v, %v", resp, err)
+	}
+	if resp.Timings == nil || resp.Timings.Protocol != "HTTP/2.0" {
+		t.Fatalf("expected the timings of the hedge, got %+v", resp.Timings)
+	}
+}
@@ -0,0 +1,385 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// URITemplate is a parsed RFC 6570 URI template, up to level 4:
+//
+//	t, err := ParseURITemplate("/orders/{id}/labels{?format,size}")
+//	url, err := t.Expand(map[string]interface{}{"id": "odr 1", "format": "pdf"})
+//	// /orders/odr%201/labels?format=pdf
+//
+// Variables may be strings, numbers, booleans, slices (lists) or maps with
+// string keys (associative arrays, expanded in key order). Nil, empty slices
+// and empty maps are undefined and expand to nothing.
+type URITemplate struct {
+	raw   string
+	parts []templatePart
+}
+
+// templatePart is a literal or, when op is set, an expression.
+type templatePart struct {
+	literal string
+	op      *templateOp
+	vars    []templateVar
+}
+
+type templateVar struct {
+	name    string
+	prefix  int
+	explode bool
+}
+
+// templateOp describes an expression operator, see RFC 6570 appendix A.
+type templateOp struct {
+	first    string
+	sep      string
+	named    bool
+	ifEmpty  string
+	reserved bool
+}
+
+var templateOps = map[byte]*templateOp{
+	0:   {first: "", sep: ","},
+	'+': {first: "", sep: ",", reserved: true},
+	'#': {first: "#", sep: ",", reserved: true},
+	'.': {first: ".", sep: "."},
+	'/': {first: "/", sep: "/"},
+	';': {first: ";", sep: ";", named: true},
+	'?': {first: "?", sep: "&", named: true, ifEmpty: "="},
+	'&': {first: "&", sep: "&", named: true, ifEmpty: "="},
+}
+
+// ParseURITemplate parses a URI template.
+func ParseURITemplate(template string) (*URITemplate, error) {
+	t := &URITemplate{raw: template}
+	rest := template
+	for rest != "" {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			open = len(rest)
+		}
+		if close := strings.IndexByte(rest[:open], '}'); close >= 0 {
+			return nil, fmt.Errorf("parse uri template %q failed: unexpected '}'", template)
+		}
+		if open > 0 {
+			t.parts = append(t.parts, templatePart{literal: encodeTemplate(rest[:open], true)})
+		}
+		if open == len(rest) {
+			break
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("parse uri template %q failed: unclosed expression", template)
+		}
+		part, err := parseExpression(rest[open+1 : open+end])
+		if err != nil {
+			return nil, fmt.Errorf("parse uri template %q failed: %s", template, err)
+		}
+		t.parts = append(t.parts, part)
+		rest = rest[open+end+1:]
+	}
+	return t, nil
+}
+
+func parseExpression(expr string) (templatePart, error) {
+	var part templatePart
+	if expr == "" {
+		return part, fmt.Errorf("empty expression")
+	}
+	part.op = templateOps[0]
+	if op, ok := templateOps[expr[0]]; ok {
+		part.op = op
+		expr = expr[1:]
+	} else if strings.ContainsRune("=,!@|", rune(expr[0])) {
+		return part, fmt.Errorf("reserved operator %q", expr[0])
+	}
+	for _, spec := range strings.Split(expr, ",") {
+		v := templateVar{name: spec}
+		if strings.HasSuffix(spec, "*") {
+			v.name, v.explode = spec[:len(spec)-1], true
+		} else if name, prefix, ok := strings.Cut(spec, ":"); ok {
+			n, err := strconv.Atoi(prefix)
+			if err != nil || n <= 0 || n >= 10000 || prefix[0] == '0' {
+				return part, fmt.Errorf("invalid prefix %q", prefix)
+			}
+			v.name, v.prefix = name, n
+		}
+		if !validVarName(v.name) {
+			return part, fmt.Errorf("invalid variable name %q", v.name)
+		}
+		part.vars = append(part.vars, v)
+	}
+	return part, nil
+}
+
+func validVarName(name string) bool {
+	if name == "" || name[0] == '.' || name[len(name)-1] == '.' || strings.Contains(name, "..") {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '_', c == '.':
+		case c == '%' && i+2 < len(name) && isHex(name[i+1]) && isHex(name[i+2]):
+			i += 2
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the template as it was parsed.
+func (t *URITemplate) String() string {
+	return t.raw
+}
+
+// Expand expands the template with vars.
+func (t *URITemplate) Expand(vars map[string]interface{}) (string, error) {
+	var b strings.Builder
+	for _, part := range t.parts {
+		if part.op == nil {
+			b.WriteString(part.literal)
+			continue
+		}
+		if err := part.expand(&b, vars); err != nil {
+			return "", fmt.Errorf("expand uri template %q failed: %s", t.raw, err)
+		}
+	}
+	return b.String(), nil
+}
+
+// ExpandURITemplate parses and expands template.
+func ExpandURITemplate(template string, vars map[string]interface{}) (string, error) {
+	t, err := ParseURITemplate(template)
+	if err != nil {
+		return "", err
+	}
+	return t.Expand(vars)
+}
+
+func (p *templatePart) expand(b *strings.Builder, vars map[string]interface{}) error {
+	op := p.op
+	first := true
+	for _, v := range p.vars {
+		value := templateValueOf(vars[v.name])
+		if value == nil {
+			continue
+		}
+		if first {
+			b.WriteString(op.first)
+			first = false
+		} else {
+			b.WriteString(op.sep)
+		}
+
+		switch value := value.(type) {
+		case string:
+			if v.prefix > 0 {
+				value = truncateRunes(value, v.prefix)
+			}
+			writeNamed(b, op, v.name, value)
+		case []string:
+			if v.prefix > 0 {
+				return fmt.Errorf("prefix modifier on list %q", v.name)
+			}
+			if v.explode {
+				for i, item := range value {
+					if i > 0 {
+						b.WriteString(op.sep)
+					}
+					writeNamed(b, op, v.name, item)
+				}
+				continue
+			}
+			if op.named {
+				b.WriteString(v.name + "=")
+			}
+			for i, item := range value {
+				if i > 0 {
+					b.WriteByte(',')
+				}
+				b.WriteString(encodeTemplate(item, op.reserved))
+			}
+		case [][2]string:
+			if v.prefix > 0 {
+				return fmt.Errorf("prefix modifier on map %q", v.name)
+			}
+			if v.explode {
+				for i, kv := range value {
+					if i > 0 {
+						b.WriteString(op.sep)
+					}
+					b.WriteString(encodeTemplate(kv[0], op.reserved))
+					if kv[1] == "" && op.named {
+						b.WriteString(op.ifEmpty)
+						continue
+					}
+					b.WriteByte('=')
+					b.WriteString(encodeTemplate(kv[1], op.reserved))
+				}
+				continue
+			}
+			if op.named {
+				b.WriteString(v.name + "=")
+			}
+			for i, kv := range value {
+				if i > 0 {
+					b.WriteByte(',')
+				}
+				b.WriteString(encodeTemplate(kv[0], op.reserved))
+				b.WriteByte(',')
+				b.WriteString(encodeTemplate(kv[1], op.reserved))
+			}
+		}
+	}
+	return nil
+}
+
+// writeNamed writes a single value, preceded by its name for the operators
+// that name their values.
+func writeNamed(b *strings.Builder, op *templateOp, name, value string) {
+	if op.named {
+		b.WriteString(name)
+		if value == "" {
+			b.WriteString(op.ifEmpty)
+			return
+		}
+		b.WriteByte('=')
+	}
+	b.WriteString(encodeTemplate(value, op.reserved))
+}
+
+// templateValueOf converts a variable into a string, a list or an ordered
+// list of pairs. It returns nil for undefined variables.
+func templateValueOf(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return v
+	case fmt.Stringer:
+		return v.String()
+	case []string:
+		if len(v) == 0 {
+			return nil
+		}
+		return v
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if rv.Len() == 0 {
+			return nil
+		}
+		list := make([]string, rv.Len())
+		for i := range list {
+			list[i] = fmt.Sprint(rv.Index(i).Interface())
+		}
+		return list
+	case reflect.Map:
+		if rv.Len() == 0 {
+			return nil
+		}
+		pairs := make([][2]string, 0, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			pairs = append(pairs, [2]string{fmt.Sprint(iter.Key().Interface()), fmt.Sprint(iter.Value().Interface())})
+		}
+		sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+		return pairs
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return nil
+		}
+		return templateValueOf(rv.Elem().Interface())
+	}
+	return fmt.Sprint(value)
+}
+
+func truncateRunes(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
+
+const templateReserved = ":/?#[]@!$&'()*+,;="
+
+// encodeTemplate percent-encodes s, keeping unreserved characters and, when
+// reserved is set, reserved characters and existing percent-encodings.
+func encodeTemplate(s string, reserved bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '.', c == '_', c == '~':
+			b.WriteByte(c)
+		case reserved && strings.IndexByte(templateReserved, c) >= 0:
+			b.WriteByte(c)
+		case reserved && c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			b.WriteString(s[i : i+3])
+			i += 2
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+type routeKey struct{}
+
+// WithRoute returns a context whose requests are labelled with route, a low
+// cardinality name such as the URI template they were built from, for
+// metrics and tracing.
+func WithRoute(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, routeKey{}, route)
+}
+
+// RouteFromContext returns the route label set by WithRoute, or "".
+func RouteFromContext(ctx context.Context) string {
+	route, _ := ctx.Value(routeKey{}).(string)
+	return route
+}
+
+// NewTemplateRequest builds a request to the expansion of template. The
+// request's context carries the template as its route label.
+func NewTemplateRequest(ctx context.Context, method string, template string, vars map[string]interface{},
+	body io.Reader) (*http.Request, error) {
+	t, err := ParseURITemplate(template)
+	if err != nil {
+		return nil, err
+	}
+	url, err := t.Expand(vars)
+	if err != nil {
+		return nil, err
+	}
+	return http.NewRequestWithContext(WithRoute(ctx, t.String()), method, url, body)
+}
+
+// GetWithTemplate sends a GET request to the expansion of template, labelled
+// with the template as its route.
+func (c *HTTPClient) GetWithTemplate(ctx context.Context, template string, vars map[string]interface{},
+	headers map[string]string) ([]byte, error) {
+	req, err := NewTemplateRequest(ctx, http.MethodGet, template, vars, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	return c.Do(req.Context(), req)
+}
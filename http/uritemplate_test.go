@@ -0,0 +1,179 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// The examples of RFC 6570 section 3.2, with the keys associative array in
+// key order.
+func TestExpandURITemplateRFCExamples(t *testing.T) {
+	fmt.Println("TestExpandURITemplateRFCExamples")
+	vars := map[string]interface{}{
+		"count":      []string{"one", "two", "three"},
+		"dom":        []string{"example", "com"},
+		"dub":        "me/too",
+		"hello":      "Hello World!",
+		"half":       "50%",
+		"var":        "value",
+		"who":        "fred",
+		"base":       "http://example.com/home/",
+		"path":       "/foo/bar",
+		"list":       []string{"red", "green", "blue"},
+		"keys":       map[string]string{"semi": ";", "dot": ".", "comma": ","},
+		"v":          6,
+		"x":          1024,
+		"y":          "768",
+		"empty":      "",
+		"empty_keys": map[string]string{},
+		"undef":      nil,
+	}
+	for template, want := range map[string]string{
+		"{var}":               "value",
+		"{hello}":             "Hello%20World%21",
+		"{half}":              "50%25",
+		"O{empty}X":           "OX",
+		"O{undef}X":           "OX",
+		"{x,y}":               "1024,768",
+		"{x,hello,y}":         "1024,Hello%20World%21,768",
+		"?{x,empty}":          "?1024,",
+		"?{x,undef}":          "?1024",
+		"?{undef,y}":          "?768",
+		"{var:3}":             "val",
+		"{var:30}":            "value",
+		"{list}":              "red,green,blue",
+		"{list*}":             "red,green,blue",
+		"{keys}":              "comma,%2C,dot,.,semi,%3B",
+		"{keys*}":             "comma=%2C,dot=.,semi=%3B",
+		"{+var}":              "value",
+		"{+hello}":            "Hello%20World!",
+		"{+half}":             "50%25",
+		"{base}index":         "http%3A%2F%2Fexample.com%2Fhome%2Findex",
+		"{+base}index":        "http://example.com/home/index",
+		"O{+empty}X":          "OX",
+		"{+path}/here":        "/foo/bar/here",
+		"here?ref={+path}":    "here?ref=/foo/bar",
+		"up{+path}{var}/here": "up/foo/barvalue/here",
+		"{+x,hello,y}":        "1024,Hello%20World!,768",
+		"{+path,x}/here":      "/foo/bar,1024/here",
+		"{+path:6}/here":      "/foo/b/here",
+		"{+list}":             "red,green,blue",
+		"{+keys}":             "comma,,,dot,.,semi,;",
+		"{+keys*}":            "comma=,,dot=.,semi=;",
+		"{#var}":              "#value",
+		"{#hello}":            "#Hello%20World!",
+		"{#half}":             "#50%25",
+		"foo{#empty}":         "foo#",
+		"foo{#undef}":         "foo",
+		"{#x,hello,y}":        "#1024,Hello%20World!,768",
+		"{#path,x}/here":      "#/foo/bar,1024/here",
+		"{#path:6}/here":      "#/foo/b/here",
+		"{#list}":             "#red,green,blue",
+		"{#keys*}":            "#comma=,,dot=.,semi=;",
+		"{.who}":              ".fred",
+		"{.who,who}":          ".fred.fred",
+		"{.half,who}":         ".50%25.fred",
+		"www{.dom*}":          "www.example.com",
+		"X{.var}":             "X.value",
+		"X{.empty}":           "X.",
+		"X{.undef}":           "X",
+		"X{.var:3}":           "X.val",
+		"X{.list}":            "X.red,green,blue",
+		"X{.list*}":           "X.red.green.blue",
+		"X{.keys}":            "X.comma,%2C,dot,.,semi,%3B",
+		"X{.keys*}":           "X.comma=%2C.dot=..semi=%3B",
+		"X{.empty_keys}":      "X",
+		"X{.empty_keys*}":     "X",
+		"{/who}":              "/fred",
+		"{/who,who}":          "/fred/fred",
+		"{/half,who}":         "/50%25/fred",
+		"{/who,dub}":          "/fred/me%2Ftoo",
+		"{/var}":              "/value",
+		"{/var,empty}":        "/value/",
+		"{/var,undef}":        "/value",
+		"{/var,x}/here":       "/value/1024/here",
+		"{/var:1,var}":        "/v/value",
+		"{/list}":             "/red,green,blue",
+		"{/list*}":            "/red/green/blue",
+		"{/list*,path:4}":     "/red/green/blue/%2Ffoo",
+		"{/keys}":             "/comma,%2C,dot,.,semi,%3B",
+		"{/keys*}":            "/comma=%2C/dot=./semi=%3B",
+		"{;who}":              ";who=fred",
+		"{;half}":             ";half=50%25",
+		"{;empty}":            ";empty",
+		"{;v,empty,who}":      ";v=6;empty;who=fred",
+		"{;v,bar,who}":        ";v=6;who=fred",
+		"{;x,y}":              ";x=1024;y=768",
+		"{;x,y,empty}":        ";x=1024;y=768;empty",
+		"{;x,y,undef}":        ";x=1024;y=768",
+		"{;hello:5}":          ";hello=Hello",
+		"{;list}":             ";list=red,green,blue",
+		"{;list*}":            ";list=red;list=green;list=blue",
+		"{;keys}":             ";keys=comma,%2C,dot,.,semi,%3B",
+		"{;keys*}":            ";comma=%2C;dot=.;semi=%3B",
+		"{?who}":              "?who=fred",
+		"{?half}":             "?half=50%25",
+		"{?x,y}":              "?x=1024&y=768",
+		"{?x,y,empty}":        "?x=1024&y=768&empty=",
+		"{?x,y,undef}":        "?x=1024&y=768",
+		"{?var:3}":            "?var=val",
+		"{?list}":             "?list=red,green,blue",
+		"{?list*}":            "?list=red&list=green&list=blue",
+		"{?keys}":             "?keys=comma,%2C,dot,.,semi,%3B",
+		"{?keys*}":            "?comma=%2C&dot=.&semi=%3B",
+		"{&who}":              "&who=fred",
+		"{&half}":             "&half=50%25",
+		"?fixed=yes{&x}":      "?fixed=yes&x=1024",
+		"{&x,y,empty}":        "&x=1024&y=768&empty=",
+		"{&var:3}":            "&var=val",
+		"{&list}":             "&list=red,green,blue",
+		"{&list*}":            "&list=red&list=green&list=blue",
+		"{&keys}":             "&keys=comma,%2C,dot,.,semi,%3B",
+		"{&keys*}":            "&comma=%2C&dot=.&semi=%3B",
+	} {
+		got, err := ExpandURITemplate(template, vars)
+		if err != nil || got != want {
+			t.Fatalf("%s: expected %q, got %q, %v", template, want, got, err)
+		}
+	}
+}
+
+func TestParseURITemplateErrors(t *testing.T) {
+	fmt.Println("TestParseURITemplateErrors")
+	for _, template := range []string{"/orders/{id", "/orders/id}", "{}", "{=x}", "{x:0}", "{x:10000}", "{x:3*}", "{a..b}", "{x y}"} {
+		if _, err := ParseURITemplate(template); err == nil {
+			t.Fatalf("expected %q to be rejected", template)
+		}
+	}
+	if _, err := ExpandURITemplate("{list:2}", map[string]interface{}{"list": []string{"a"}}); err == nil {
+		t.Fatalf("expected a prefix on a list to be rejected")
+	}
+}
+
+func TestGetWithTemplate(t *testing.T) {
+	fmt.Println("TestGetWithTemplate")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RequestURI() + " " + r.Header.Get("appkey")))
+	}))
+	defer server.Close()
+
+	var route string
+	client := NewHTTPClient(WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			route = RouteFromContext(req.Context())
+			return next.RoundTrip(req)
+		})
+	}))
+	template := server.URL + "/orders/{id}/labels{?format,size}"
+	body, err := client.GetWithTemplate(context.Background(), template,
+		map[string]interface{}{"id": "odr/1 2", "format": "pdf"}, map[string]string{"appkey": "shipos_959"})
+	if err != nil || string(body) != "/orders/odr%2F1%202/labels?format=pdf shipos_959" {
+		t.Fatalf("unexpected response %q, %v", body, err)
+	}
+	if route != template {
+		t.Fatalf("expected route %q, got %q", template, route)
+	}
+}
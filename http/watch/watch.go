@@ -0,0 +1,251 @@
+// Package watch follows change-notification endpoints that answer blocking
+// queries, such as Consul's ?index=N&wait=30s, or that support conditional
+// GETs with ETags:
+//
+//	w := watch.New(client, "http://consul:8500/v1/kv/config?recurse",
+//		watch.WithIndexHeader("X-Consul-Index"))
+//	for event := range w.Watch(ctx) {
+//		apply(event.Body)
+//	}
+//
+// Each request blocks on the server until something changes or the wait
+// elapses. Only changed payloads are emitted; errors are retried with
+// backoff.
+package watch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	httpclient "http"
+)
+
+// Event is a changed payload.
+type Event struct {
+	// Index is the index the server reported, if any.
+	Index string
+	ETag  string
+	// Header is the header of the response that carried the change.
+	Header http.Header
+	Body   []byte
+}
+
+// Watcher repeatedly polls one URL.
+type Watcher struct {
+	client      *httpclient.HTTPClient
+	url         string
+	headers     map[string]string
+	wait        time.Duration
+	indexParam  string
+	waitParam   string
+	indexHeader string
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	onError     func(error)
+
+	index    string
+	etag     string
+	last     []byte
+	started  bool
+	failures int
+}
+
+type Option func(*Watcher)
+
+// WithWait sets how long the server may hold each request, 30s by default.
+func WithWait(wait time.Duration) Option {
+	return func(w *Watcher) {
+		w.wait = wait
+	}
+}
+
+// WithQueryParams names the query parameters carrying the index and the
+// wait, "index" and "wait" by default. An empty name leaves the parameter
+// out.
+func WithQueryParams(index string, wait string) Option {
+	return func(w *Watcher) {
+		w.indexParam, w.waitParam = index, wait
+	}
+}
+
+// WithIndexHeader names the response header reporting the index, "X-Index"
+// by default.
+func WithIndexHeader(name string) Option {
+	return func(w *Watcher) {
+		w.indexHeader = name
+	}
+}
+
+// WithHeaders adds headers to every request.
+func WithHeaders(headers map[string]string) Option {
+	return func(w *Watcher) {
+		w.headers = headers
+	}
+}
+
+// WithBackoff sets the bounds of the jittered exponential backoff between
+// failed requests, 1s and 1m by default.
+func WithBackoff(min, max time.Duration) Option {
+	return func(w *Watcher) {
+		w.minBackoff, w.maxBackoff = min, max
+	}
+}
+
+// WithErrorHandler is called with every failed request before backing off.
+func WithErrorHandler(fn func(error)) Option {
+	return func(w *Watcher) {
+		w.onError = fn
+	}
+}
+
+func New(client *httpclient.HTTPClient, url string, opts ...Option) *Watcher {
+	w := &Watcher{
+		client:      client,
+		url:         url,
+		wait:        30 * time.Second,
+		indexParam:  "index",
+		waitParam:   "wait",
+		indexHeader: "X-Index",
+		minBackoff:  time.Second,
+		maxBackoff:  time.Minute,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Watch emits changed payloads until ctx is cancelled, then closes the
+// channel. The first payload is always emitted.
+func (w *Watcher) Watch(ctx context.Context) <-chan Event {
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		for {
+			event, err := w.Next(ctx)
+			if err != nil {
+				return
+			}
+			select {
+			case events <- *event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events
+}
+
+// Next blocks until the payload changes and returns it. It only fails when
+// ctx is done. A Watcher is not safe for concurrent calls to Next.
+func (w *Watcher) Next(ctx context.Context) (*Event, error) {
+	for {
+		event, err := w.poll(ctx)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			if w.onError != nil {
+				w.onError(err)
+			}
+			w.failures++
+			timer := time.NewTimer(w.backoff())
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		w.failures = 0
+		if event != nil {
+			return event, nil
+		}
+	}
+}
+
+// poll sends one blocking query. It returns nil without error when nothing
+// changed.
+func (w *Watcher) poll(ctx context.Context) (*Event, error) {
+	u, err := url.Parse(w.url)
+	if err != nil {
+		return nil, fmt.Errorf("parse watch url failed: %s", err)
+	}
+	query := u.Query()
+	if w.indexParam != "" && w.index != "" {
+		query.Set(w.indexParam, w.index)
+	}
+	if w.waitParam != "" && w.wait > 0 {
+		query.Set(w.waitParam, w.wait.String())
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range w.headers {
+		req.Header.Set(key, value)
+	}
+	if w.etag != "" {
+		req.Header.Set("If-None-Match", w.etag)
+	}
+
+	// Servers add up to wait/16 of jitter to the wait.
+	ctx = httpclient.WithRequestTimeout(ctx, w.wait+w.wait/16+10*time.Second)
+	resp, err := w.client.DoV2(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, nil
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("watch %s failed: %d, %s", w.url, resp.StatusCode, resp.Body)
+	}
+
+	index := resp.Header.Get(w.indexHeader)
+	etag := resp.Header.Get("ETag")
+	changed := !w.started || w.changed(index, etag, resp.Body)
+	w.started = true
+	w.index, w.etag, w.last = index, etag, resp.Body
+	if !changed {
+		return nil, nil
+	}
+	return &Event{Index: index, ETag: etag, Header: resp.Header, Body: resp.Body}, nil
+}
+
+func (w *Watcher) changed(index, etag string, body []byte) bool {
+	if index != "" {
+		// An index going backwards means the server state was reset.
+		old, oldErr := strconv.ParseUint(w.index, 10, 64)
+		cur, curErr := strconv.ParseUint(index, 10, 64)
+		if oldErr == nil && curErr == nil && cur < old {
+			return true
+		}
+		return index != w.index
+	}
+	if etag != "" {
+		return etag != w.etag
+	}
+	return !bytes.Equal(body, w.last)
+}
+
+func (w *Watcher) backoff() time.Duration {
+	d := w.minBackoff
+	for i := 1; i < w.failures && d < w.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > w.maxBackoff {
+		d = w.maxBackoff
+	}
+	// Full jitter over the upper half keeps watchers of one server apart.
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
@@ -0,0 +1,178 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	httpclient "http"
+)
+
+// indexServer answers blocking queries like Consul: a request with an index
+// is held until the index moves past it or the wait elapses.
+type indexServer struct {
+	mu      sync.Mutex
+	index   int
+	value   string
+	changed chan struct{}
+	waits   []string
+}
+
+func newIndexServer(value string) *indexServer {
+	return &indexServer{index: 1, value: value, changed: make(chan struct{})}
+}
+
+func (s *indexServer) set(value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.index++
+	s.value = value
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *indexServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	wait, _ := time.ParseDuration(r.URL.Query().Get("wait"))
+	index, _ := strconv.Atoi(r.URL.Query().Get("index"))
+	s.mu.Lock()
+	s.waits = append(s.waits, r.URL.Query().Get("wait"))
+	s.mu.Unlock()
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		s.mu.Lock()
+		current, value, changed := s.index, s.value, s.changed
+		s.mu.Unlock()
+		if current > index {
+			w.Header().Set("X-Index", strconv.Itoa(current))
+			w.Write([]byte(value))
+			return
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			w.Header().Set("X-Index", strconv.Itoa(current))
+			w.Write([]byte(value))
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func TestWatchIndex(t *testing.T) {
+	fmt.Println("TestWatchIndex")
+	server := newIndexServer("a")
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	w := New(httpclient.NewHTTPClient(), ts.URL+"/v1/kv/config", WithWait(50*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	events := w.Watch(ctx)
+
+	if event := <-events; string(event.Body) != "a" || event.Index != "1" {
+		t.Fatalf("unexpected first event %+v", event)
+	}
+	// Several waits elapse without a change and emit nothing.
+	time.Sleep(200 * time.Millisecond)
+	server.set("b")
+	if event := <-events; string(event.Body) != "b" || event.Index != "2" {
+		t.Fatalf("unexpected second event %+v", event)
+	}
+	server.set("c")
+	if event := <-events; string(event.Body) != "c" || event.Index != "3" {
+		t.Fatalf("unexpected third event %+v", event)
+	}
+
+	cancel()
+	for range events {
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.waits) < 4 || server.waits[0] != "50ms" {
+		t.Fatalf("unexpected wait parameter %q", server.waits)
+	}
+}
+
+func TestWatchETag(t *testing.T) {
+	fmt.Println("TestWatchETag")
+	var mu sync.Mutex
+	version, polls := 1, 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		polls++
+		if polls == 3 {
+			version++
+		}
+		etag := fmt.Sprintf(`"v%d"`, version)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		fmt.Fprintf(w, "version %d", version)
+	}))
+	defer ts.Close()
+
+	w := New(httpclient.NewHTTPClient(), ts.URL, WithQueryParams("", ""))
+	ctx := context.Background()
+	for i, want := range []string{"version 1", "version 2"} {
+		event, err := w.Next(ctx)
+		if err != nil || string(event.Body) != want || event.ETag != fmt.Sprintf(`"v%d"`, i+1) {
+			t.Fatalf("unexpected event %d: %+v, %v", i, event, err)
+		}
+	}
+	if polls != 3 {
+		t.Fatalf("expected 3 polls, got %d", polls)
+	}
+}
+
+func TestWatchBackoff(t *testing.T) {
+	fmt.Println("TestWatchBackoff")
+	var mu sync.Mutex
+	var times []time.Time
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		times = append(times, time.Now())
+		if len(times) <= 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("X-Index", "7")
+		w.Write([]byte("up"))
+	}))
+	defer ts.Close()
+
+	var errs []error
+	w := New(httpclient.NewHTTPClient(), ts.URL,
+		WithBackoff(20*time.Millisecond, 40*time.Millisecond),
+		WithErrorHandler(func(err error) { errs = append(errs, err) }))
+	event, err := w.Next(context.Background())
+	if err != nil || string(event.Body) != "up" {
+		t.Fatalf("unexpected event %+v, %v", event, err)
+	}
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got %v", errs)
+	}
+	// Backoffs are jittered over the upper half: 10-20ms, 20-40ms, 20-40ms.
+	for i, min := range []time.Duration{10, 20, 20} {
+		if gap := times[i+1].Sub(times[i]); gap < min*time.Millisecond {
+			t.Fatalf("backoff %d too short: %s", i, gap)
+		}
+	}
+
+	// A cancelled context ends the backoff.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := w.Next(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context canceled, got %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package webhook
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// NonceStore remembers delivered nonces so that replays can be rejected. A
+// shared implementation, e.g. on Redis, lets several replicas agree.
+type NonceStore interface {
+	// Add records nonce until expiry and reports whether it was new. It must
+	// be atomic: of two concurrent calls with the same nonce only one is new.
+	Add(ctx context.Context, nonce string, expiry time.Time) (bool, error)
+	// Remove forgets nonce, so that a delivery whose handler failed can be
+	// retried.
+	Remove(ctx context.Context, nonce string) error
+}
+
+// MemoryNonceStore is a NonceStore for a single process.
+type MemoryNonceStore struct {
+	mu        sync.Mutex
+	nonces    map[string]time.Time
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{nonces: make(map[string]time.Time), now: time.Now}
+}
+
+func (s *MemoryNonceStore) Add(_ context.Context, nonce string, expiry time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if exp, ok := s.nonces[nonce]; ok && exp.After(now) {
+		return false, nil
+	}
+	// Expired nonces are pruned as new ones come in, at most once a minute.
+	if now.Sub(s.lastPrune) >= time.Minute {
+		for n, exp := range s.nonces {
+			if !exp.After(now) {
+				delete(s.nonces, n)
+			}
+		}
+		s.lastPrune = now
+	}
+	s.nonces[nonce] = expiry
+	return true, nil
+}
+
+func (s *MemoryNonceStore) Remove(_ context.Context, nonce string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.nonces, nonce)
+	return nil
+}
+
+// Len returns the number of nonces remembered.
+func (s *MemoryNonceStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.nonces)
+}
@@ -0,0 +1,153 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"strings"
+)
+
+// Encoding is how a signature is written in its header.
+type Encoding int
+
+const (
+	Hex Encoding = iota
+	Base64
+)
+
+// Scheme describes how a sender signs its webhooks.
+type Scheme struct {
+	// SignatureHeader holds the signature. It may list several space separated
+	// signatures, as senders do while rotating secrets; any one must match.
+	SignatureHeader string
+	// Prefix is stripped from every signature, e.g. "sha256=". Signatures
+	// without it are ignored.
+	Prefix string
+	// Hash is the HMAC hash function, sha256.New when nil.
+	Hash     func() hash.Hash
+	Encoding Encoding
+
+	// TimestampHeader, when set, holds the send time in Unix seconds. It is
+	// signed along with the body and checked against the receiver's tolerance.
+	TimestampHeader string
+	// NonceHeader, when set, holds a unique delivery id used to reject
+	// replays. When the id is not signed, or the scheme has none, the
+	// signature itself serves as the nonce, since an unsigned id can be
+	// changed by whoever replays a delivery.
+	NonceHeader string
+	// EventHeader, when set, names the event type. Otherwise it is read from
+	// the "type" member of the JSON payload.
+	EventHeader string
+
+	// SignedPayload builds the signed bytes. By default it is the body, or
+	// "timestamp.body" when the scheme has a TimestampHeader.
+	SignedPayload func(nonce string, timestamp string, body []byte) []byte
+}
+
+// GitHub is the X-Hub-Signature-256 scheme used by GitHub and many others.
+var GitHub = Scheme{
+	SignatureHeader: "X-Hub-Signature-256",
+	Prefix:          "sha256=",
+	Hash:            sha256.New,
+	Encoding:        Hex,
+	NonceHeader:     "X-GitHub-Delivery",
+	EventHeader:     "X-GitHub-Event",
+}
+
+// GitHubSHA1 is the legacy X-Hub-Signature scheme.
+var GitHubSHA1 = Scheme{
+	SignatureHeader: "X-Hub-Signature",
+	Prefix:          "sha1=",
+	Hash:            sha1.New,
+	Encoding:        Hex,
+	NonceHeader:     "X-GitHub-Delivery",
+	EventHeader:     "X-GitHub-Event",
+}
+
+// StandardWebhooks is the scheme of the Standard Webhooks specification. Its
+// secrets are distributed base64 encoded with a "whsec_" prefix; pass the
+// decoded bytes to the receiver.
+var StandardWebhooks = Scheme{
+	SignatureHeader: "webhook-signature",
+	Prefix:          "v1,",
+	Hash:            sha256.New,
+	Encoding:        Base64,
+	TimestampHeader: "webhook-timestamp",
+	NonceHeader:     "webhook-id",
+	SignedPayload: func(nonce string, timestamp string, body []byte) []byte {
+		return []byte(nonce + "." + timestamp + "." + string(body))
+	},
+}
+
+func (s Scheme) payload(nonce string, timestamp string, body []byte) []byte {
+	if s.SignedPayload != nil {
+		return s.SignedPayload(nonce, timestamp, body)
+	}
+	if s.TimestampHeader != "" {
+		return []byte(timestamp + "." + string(body))
+	}
+	return body
+}
+
+// Sign returns the signature header value for payload, as a sender would.
+func (s Scheme) Sign(secret []byte, nonce string, timestamp string, body []byte) string {
+	return s.Prefix + s.encode(s.mac(secret, s.payload(nonce, timestamp, body)))
+}
+
+func (s Scheme) mac(secret []byte, payload []byte) []byte {
+	newHash := s.Hash
+	if newHash == nil {
+		newHash = sha256.New
+	}
+	m := hmac.New(newHash, secret)
+	m.Write(payload)
+	return m.Sum(nil)
+}
+
+func (s Scheme) encode(sum []byte) string {
+	if s.Encoding == Base64 {
+		return base64.StdEncoding.EncodeToString(sum)
+	}
+	return hex.EncodeToString(sum)
+}
+
+func (s Scheme) decode(sig string) ([]byte, error) {
+	if s.Encoding == Base64 {
+		return base64.StdEncoding.DecodeString(sig)
+	}
+	return hex.DecodeString(sig)
+}
+
+// signsNonce reports whether the nonce is part of the signed payload.
+func (s Scheme) signsNonce() bool {
+	return s.NonceHeader != "" && !bytes.Equal(s.payload("a", "", nil), s.payload("b", "", nil))
+}
+
+// verify returns the first signature in header that matches one of the
+// secrets, in a canonical form: the same bytes spelled in another case or
+// padding give the same string.
+func (s Scheme) verify(header string, secrets [][]byte, payload []byte) (string, bool) {
+	sums := make([][]byte, len(secrets))
+	for i, secret := range secrets {
+		sums[i] = s.mac(secret, payload)
+	}
+	for _, field := range strings.Fields(header) {
+		if !strings.HasPrefix(field, s.Prefix) {
+			continue
+		}
+		got, err := s.decode(strings.TrimPrefix(field, s.Prefix))
+		if err != nil {
+			continue
+		}
+		for _, sum := range sums {
+			if hmac.Equal(got, sum) {
+				return s.Prefix + hex.EncodeToString(got), true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,301 @@
+// Package webhook receives signed webhooks: it verifies HMAC signatures,
+// rejects stale and replayed deliveries, and dispatches typed events to
+// registered handlers.
+//
+// Responses are chosen so that senders retry only what can succeed later:
+// 2xx for handled and unknown events, 4xx for deliveries that will never be
+// accepted, replays included, and 5xx when a handler or the nonce store failed.
+// Error responses are RFC 9457 problem details.
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	httpclient "http"
+)
+
+// Event is a verified delivery decoded into T.
+type Event[T any] struct {
+	Type string
+	// ID is the delivery id, or the signature when the scheme has no
+	// NonceHeader.
+	ID string
+	// Timestamp is zero when the scheme carries none.
+	Timestamp time.Time
+	Header    http.Header
+	Data      T
+}
+
+// Receiver is an http.Handler for webhooks signed with one scheme.
+type Receiver struct {
+	scheme       Scheme
+	secrets      [][]byte
+	tolerance    time.Duration
+	nonceTTL     time.Duration
+	nonces       NonceStore
+	maxBodyBytes int64
+	eventType    func(header http.Header, body []byte) (string, error)
+	now          func() time.Time
+
+	mu       sync.RWMutex
+	handlers map[string]func(ctx context.Context, event Event[json.RawMessage]) error
+}
+
+// Option configures a Receiver.
+type Option func(*Receiver)
+
+// WithTolerance sets how far a delivery timestamp may be from the current
+// time. The default is five minutes.
+func WithTolerance(d time.Duration) Option {
+	return func(r *Receiver) {
+		r.tolerance = d
+	}
+}
+
+// WithNonceStore replaces the in-memory nonce store.
+func WithNonceStore(store NonceStore) Option {
+	return func(r *Receiver) {
+		r.nonces = store
+	}
+}
+
+// WithNonceTTL sets how long nonces are remembered for schemes without a
+// timestamp. With a timestamp they are kept until it falls out of tolerance.
+// The default is 24 hours.
+func WithNonceTTL(d time.Duration) Option {
+	return func(r *Receiver) {
+		r.nonceTTL = d
+	}
+}
+
+// WithMaxBodyBytes limits the payload size; larger ones are answered 413.
+// The default is 1 MiB.
+func WithMaxBodyBytes(n int64) Option {
+	return func(r *Receiver) {
+		r.maxBodyBytes = n
+	}
+}
+
+// WithEventType replaces how the event type is read from a delivery.
+func WithEventType(fn func(header http.Header, body []byte) (string, error)) Option {
+	return func(r *Receiver) {
+		r.eventType = fn
+	}
+}
+
+// NewReceiver creates a receiver accepting deliveries signed with any of
+// secrets, so that secrets can be rotated without downtime.
+func NewReceiver(scheme Scheme, secrets [][]byte, opts ...Option) *Receiver {
+	r := &Receiver{
+		scheme:       scheme,
+		secrets:      secrets,
+		tolerance:    5 * time.Minute,
+		nonceTTL:     24 * time.Hour,
+		nonces:       NewMemoryNonceStore(),
+		maxBodyBytes: 1 << 20,
+		now:          time.Now,
+		handlers:     make(map[string]func(ctx context.Context, event Event[json.RawMessage]) error),
+	}
+	r.eventType = r.defaultEventType
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Handle registers fn for events of eventType, decoding their JSON payload
+// into T. It replaces any handler registered for the type before.
+func Handle[T any](r *Receiver, eventType string, fn func(ctx context.Context, event Event[T]) error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[eventType] = func(ctx context.Context, raw Event[json.RawMessage]) error {
+		event := Event[T]{Type: raw.Type, ID: raw.ID, Timestamp: raw.Timestamp, Header: raw.Header}
+		if err := json.Unmarshal(raw.Data, &event.Data); err != nil {
+			return Permanent(fmt.Errorf("decode %s event failed: %s", eventType, err))
+		}
+		return fn(ctx, event)
+	}
+}
+
+func (r *Receiver) defaultEventType(header http.Header, body []byte) (string, error) {
+	if r.scheme.EventHeader != "" {
+		if t := header.Get(r.scheme.EventHeader); t != "" {
+			return t, nil
+		}
+		return "", fmt.Errorf("missing %s header", r.scheme.EventHeader)
+	}
+	var envelope struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return "", fmt.Errorf("decode payload failed: %s", err)
+	}
+	if envelope.Type == "" {
+		return "", fmt.Errorf("payload has no event type")
+	}
+	return envelope.Type, nil
+}
+
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		reject(w, req, http.StatusMethodNotAllowed, "webhooks must be POSTed")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, r.maxBodyBytes+1))
+	if err != nil {
+		reject(w, req, http.StatusBadRequest, "read body failed: "+err.Error())
+		return
+	}
+	if int64(len(body)) > r.maxBodyBytes {
+		reject(w, req, http.StatusRequestEntityTooLarge, fmt.Sprintf("payload exceeds %d bytes", r.maxBodyBytes))
+		return
+	}
+
+	event, nonce, expiry, status, err := r.verify(req.Header, body)
+	if err != nil {
+		reject(w, req, status, err.Error())
+		return
+	}
+
+	added, err := r.nonces.Add(req.Context(), nonce, expiry)
+	if err != nil {
+		reject(w, req, http.StatusServiceUnavailable, "nonce store failed: "+err.Error())
+		return
+	}
+	if !added {
+		// Replays, and retries of deliveries already handled, stop here.
+		reject(w, req, http.StatusConflict, "delivery already received")
+		return
+	}
+
+	r.mu.RLock()
+	handler, ok := r.handlers[event.Type]
+	r.mu.RUnlock()
+	if !ok {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if err := handler(req.Context(), event); err != nil {
+		// Let the sender's retry through the replay check.
+		r.nonces.Remove(context.Background(), nonce)
+		r.fail(w, req, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verify checks the signature and timestamp of a delivery and returns its
+// event, its nonce and the time the nonce must be kept until, and on failure
+// the status to answer with. The nonce is the delivery id only when the
+// scheme signs it, and the canonical signature otherwise.
+func (r *Receiver) verify(header http.Header, body []byte) (Event[json.RawMessage], string, time.Time, int, error) {
+	event := Event[json.RawMessage]{Header: header, Data: body}
+
+	signature := header.Get(r.scheme.SignatureHeader)
+	if signature == "" {
+		return event, "", time.Time{}, http.StatusUnauthorized, fmt.Errorf("missing %s header", r.scheme.SignatureHeader)
+	}
+
+	now := r.now()
+	expiry := now.Add(r.nonceTTL)
+	var timestamp string
+	if r.scheme.TimestampHeader != "" {
+		timestamp = header.Get(r.scheme.TimestampHeader)
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return event, "", time.Time{}, http.StatusBadRequest, fmt.Errorf("invalid %s header %q", r.scheme.TimestampHeader, timestamp)
+		}
+		event.Timestamp = time.Unix(seconds, 0)
+		if event.Timestamp.Before(now.Add(-r.tolerance)) || event.Timestamp.After(now.Add(r.tolerance)) {
+			return event, "", time.Time{}, http.StatusBadRequest, fmt.Errorf("timestamp %s is outside the tolerance of %s", event.Timestamp.UTC().Format(time.RFC3339), r.tolerance)
+		}
+		expiry = event.Timestamp.Add(r.tolerance)
+	}
+
+	if r.scheme.NonceHeader != "" {
+		event.ID = header.Get(r.scheme.NonceHeader)
+		if event.ID == "" {
+			return event, "", time.Time{}, http.StatusBadRequest, fmt.Errorf("missing %s header", r.scheme.NonceHeader)
+		}
+	}
+
+	matched, ok := r.scheme.verify(signature, r.secrets, r.scheme.payload(event.ID, timestamp, body))
+	if !ok {
+		return event, "", time.Time{}, http.StatusUnauthorized, fmt.Errorf("signature does not match")
+	}
+	if event.ID == "" {
+		event.ID = matched
+	}
+	nonce := matched
+	if r.scheme.signsNonce() {
+		nonce = event.ID
+	}
+
+	eventType, err := r.eventType(header, body)
+	if err != nil {
+		return event, "", time.Time{}, http.StatusBadRequest, err
+	}
+	event.Type = eventType
+	return event, nonce, expiry, 0, nil
+}
+
+func (r *Receiver) fail(w http.ResponseWriter, req *http.Request, err error) {
+	var retry *retryAfterError
+	if errors.As(err, &retry) {
+		w.Header().Set("Retry-After", strconv.Itoa(int((retry.after+time.Second-1)/time.Second)))
+		reject(w, req, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		reject(w, req, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	reject(w, req, http.StatusInternalServerError, err.Error())
+}
+
+func reject(w http.ResponseWriter, req *http.Request, status int, detail string) {
+	p := httpclient.NewProblem(status, detail)
+	p.Instance = req.URL.Path
+	httpclient.WriteProblem(w, p)
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a handler error that retrying will not fix. The delivery is
+// answered 422 so the sender gives up. Other handler errors are answered 500.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+type retryAfterError struct {
+	after time.Duration
+	err   error
+}
+
+func (e *retryAfterError) Error() string { return e.err.Error() }
+
+func (e *retryAfterError) Unwrap() error { return e.err }
+
+// RetryAfter asks the sender to retry the delivery after d. It is answered 503
+// with a Retry-After header.
+func RetryAfter(d time.Duration, err error) error {
+	return &retryAfterError{after: d, err: err}
+}
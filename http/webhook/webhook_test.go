@@ -0,0 +1,238 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGitHubSignature(t *testing.T) {
+	fmt.Println("TestGitHubSignature")
+	// Example from GitHub's "Validating webhook deliveries" documentation.
+	got := GitHub.Sign([]byte("It's a Secret to Everybody"), "", "", []byte("Hello, World!"))
+	want := "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"
+	if got != want {
+		t.Fatalf("Sign = %s, want %s", got, want)
+	}
+}
+
+type labelCreated struct {
+	Type    string `json:"type"`
+	OrderID string `json:"orderid"`
+}
+
+var testSecret = []byte("carrier-secret")
+
+func delivery(secret []byte, id string, ts time.Time, body string) *http.Request {
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/carrier", strings.NewReader(body))
+	req.Header.Set("webhook-id", id)
+	req.Header.Set("webhook-timestamp", timestamp)
+	req.Header.Set("webhook-signature", StandardWebhooks.Sign(secret, id, timestamp, []byte(body)))
+	return req
+}
+
+// newTestReceiver creates a receiver whose clock, and its nonce store's, is
+// stopped at now.
+func newTestReceiver(now time.Time, scheme Scheme, secrets [][]byte, opts ...Option) *Receiver {
+	store := NewMemoryNonceStore()
+	store.now = func() time.Time { return now }
+	r := NewReceiver(scheme, secrets, append([]Option{WithNonceStore(store)}, opts...)...)
+	r.now = func() time.Time { return now }
+	return r
+}
+
+func serve(r *Receiver, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestReceiverDispatch(t *testing.T) {
+	fmt.Println("TestReceiverDispatch")
+	now := time.Unix(1700000000, 0)
+	receiver := newTestReceiver(now, StandardWebhooks, [][]byte{[]byte("old-secret"), testSecret})
+
+	var got []string
+	Handle(receiver, "label.created", func(ctx context.Context, event Event[labelCreated]) error {
+		got = append(got, event.ID+":"+event.Data.OrderID)
+		if !event.Timestamp.Equal(now) {
+			t.Errorf("unexpected timestamp %s", event.Timestamp)
+		}
+		return nil
+	})
+
+	body := `{"type":"label.created","orderid":"odr-1"}`
+	if rec := serve(receiver, delivery(testSecret, "msg-1", now, body)); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rec.Code, rec.Body)
+	}
+	if len(got) != 1 || got[0] != "msg-1:odr-1" {
+		t.Fatalf("unexpected events %q", got)
+	}
+
+	// The same delivery again is a replay.
+	rec := serve(receiver, delivery(testSecret, "msg-1", now, body))
+	if rec.Code != http.StatusConflict || rec.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("expected a 409 problem, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	if rec := serve(receiver, delivery(testSecret, "msg-2", now, `{"type":"label.voided"}`)); rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 for an unhandled event, got %d", rec.Code)
+	}
+	if len(got) != 1 {
+		t.Fatalf("unexpected events %q", got)
+	}
+}
+
+func TestReceiverRejects(t *testing.T) {
+	fmt.Println("TestReceiverRejects")
+	now := time.Unix(1700000000, 0)
+	receiver := newTestReceiver(now, StandardWebhooks, [][]byte{testSecret}, WithTolerance(time.Minute), WithMaxBodyBytes(64))
+	body := `{"type":"label.created"}`
+
+	tampered := delivery(testSecret, "msg-3", now, body)
+	tampered.Header.Set("webhook-id", "msg-4")
+	missing := delivery(testSecret, "msg-5", now, body)
+	missing.Header.Del("webhook-signature")
+	get := delivery(testSecret, "msg-6", now, body)
+	get.Method = http.MethodGet
+
+	for name, tc := range map[string]struct {
+		req  *http.Request
+		want int
+	}{
+		"wrong secret":      {delivery([]byte("other"), "msg-1", now, body), http.StatusUnauthorized},
+		"stale":             {delivery(testSecret, "msg-2", now.Add(-2*time.Minute), body), http.StatusBadRequest},
+		"future":            {delivery(testSecret, "msg-2", now.Add(2*time.Minute), body), http.StatusBadRequest},
+		"tampered id":       {tampered, http.StatusUnauthorized},
+		"missing signature": {missing, http.StatusUnauthorized},
+		"too large":         {delivery(testSecret, "msg-7", now, `{"type":"label.created","pad":"`+strings.Repeat("x", 64)+`"}`), http.StatusRequestEntityTooLarge},
+		"no event type":     {delivery(testSecret, "msg-8", now, `{}`), http.StatusBadRequest},
+		"method":            {get, http.StatusMethodNotAllowed},
+	} {
+		if rec := serve(receiver, tc.req); rec.Code != tc.want {
+			t.Fatalf("%s: expected %d, got %d: %s", name, tc.want, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestReceiverHandlerErrors(t *testing.T) {
+	fmt.Println("TestReceiverHandlerErrors")
+	now := time.Unix(1700000000, 0)
+	receiver := newTestReceiver(now, StandardWebhooks, [][]byte{testSecret})
+
+	var fail error
+	Handle(receiver, "label.created", func(ctx context.Context, event Event[labelCreated]) error {
+		return fail
+	})
+	body := `{"type":"label.created","orderid":"odr-1"}`
+
+	fail = errors.New("database down")
+	if rec := serve(receiver, delivery(testSecret, "msg-1", now, body)); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rec.Code)
+	}
+	fail = RetryAfter(1500*time.Millisecond, errors.New("busy"))
+	rec := serve(receiver, delivery(testSecret, "msg-1", now, body))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "2" {
+		t.Fatalf("expected 503 with Retry-After 2, got %d %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	fail = Permanent(errors.New("unknown order"))
+	if rec := serve(receiver, delivery(testSecret, "msg-1", now, body)); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", rec.Code)
+	}
+	// Failed deliveries may be retried until one succeeds.
+	fail = nil
+	if rec := serve(receiver, delivery(testSecret, "msg-1", now, body)); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rec.Code)
+	}
+	if rec := serve(receiver, delivery(testSecret, "msg-1", now, `{"type":"label.created","orderid":1}`)); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rec.Code)
+	}
+	if rec := serve(receiver, delivery(testSecret, "msg-2", now, `{"type":"label.created","orderid":1}`)); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for an undecodable event, got %d", rec.Code)
+	}
+}
+
+func TestReceiverWithoutNonceHeader(t *testing.T) {
+	fmt.Println("TestReceiverWithoutNonceHeader")
+	scheme := GitHub
+	scheme.NonceHeader = ""
+	store := NewMemoryNonceStore()
+	receiver := NewReceiver(scheme, [][]byte{testSecret}, WithNonceStore(store))
+
+	body := []byte(`{"action":"opened"}`)
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/hooks", strings.NewReader(string(body)))
+		req.Header.Set("X-Hub-Signature-256", scheme.Sign(testSecret, "", "", body))
+		req.Header.Set("X-GitHub-Event", "pull_request")
+		return req
+	}
+	if rec := serve(receiver, newRequest()); rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", rec.Code)
+	}
+	// The signature identifies the delivery, so an identical one is a replay.
+	if rec := serve(receiver, newRequest()); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rec.Code)
+	}
+	// Nor is one with the signature spelled in upper case.
+	req := newRequest()
+	req.Header.Set("X-Hub-Signature-256", "sha256="+strings.ToUpper(strings.TrimPrefix(req.Header.Get("X-Hub-Signature-256"), "sha256=")))
+	if rec := serve(receiver, req); rec.Code != http.StatusConflict {
+		t.Fatalf("expected the re-cased replay to be rejected, got %d", rec.Code)
+	}
+	if store.Len() != 1 {
+		t.Fatalf("expected one nonce, got %d", store.Len())
+	}
+}
+
+func TestReceiverUnsignedDeliveryID(t *testing.T) {
+	fmt.Println("TestReceiverUnsignedDeliveryID")
+	receiver := NewReceiver(GitHub, [][]byte{testSecret})
+
+	body := []byte(`{"action":"opened"}`)
+	newRequest := func(delivery string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/hooks", strings.NewReader(string(body)))
+		req.Header.Set("X-Hub-Signature-256", GitHub.Sign(testSecret, "", "", body))
+		req.Header.Set("X-GitHub-Event", "pull_request")
+		req.Header.Set("X-GitHub-Delivery", delivery)
+		return req
+	}
+	if rec := serve(receiver, newRequest("72d3162e-cc78-11e3-81ab-4c9367dc0958")); rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", rec.Code)
+	}
+	// The delivery id is not signed, so changing it does not make a new delivery.
+	if rec := serve(receiver, newRequest("a-new-delivery-id")); rec.Code != http.StatusConflict {
+		t.Fatalf("expected the replay to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestMemoryNonceStoreExpiry(t *testing.T) {
+	fmt.Println("TestMemoryNonceStoreExpiry")
+	now := time.Unix(1700000000, 0)
+	store := NewMemoryNonceStore()
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if added, _ := store.Add(ctx, "a", now.Add(time.Minute)); !added {
+		t.Fatalf("expected a to be new")
+	}
+	if added, _ := store.Add(ctx, "a", now.Add(time.Minute)); added {
+		t.Fatalf("expected a to be known")
+	}
+	now = now.Add(2 * time.Minute)
+	if added, _ := store.Add(ctx, "b", now.Add(time.Minute)); !added {
+		t.Fatalf("expected b to be new")
+	}
+	if store.Len() != 1 {
+		t.Fatalf("expected the expired nonce to be pruned, have %d", store.Len())
+	}
+	if added, _ := store.Add(ctx, "a", now.Add(time.Minute)); !added {
+		t.Fatalf("expected a to be new again after expiry")
+	}
+}
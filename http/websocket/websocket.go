@@ -0,0 +1,453 @@
+// Package websocket is a WebSocket client that dials through an HTTPClient,
+// sharing its transport's TLS and proxy settings and the headers its
+// middleware adds, exchanges JSON messages and reconnects when the
+// connection drops:
+//
+//	conn, err := websocket.Dial(ctx, client, "wss://feed.example.com/v1/stream",
+//		websocket.WithConnectHook(func(ctx context.Context, conn *websocket.Conn) error {
+//			return conn.Send(ctx, Subscribe{Channels: []string{"trades"}})
+//		}))
+//	if err != nil {
+//		return err
+//	}
+//	defer conn.Close()
+//	for {
+//		var trade Trade
+//		if err := conn.Receive(ctx, &trade); err != nil {
+//			return err
+//		}
+//	}
+//
+// The connect hook runs after every connection, so subscriptions survive
+// reconnects.
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+
+	httpclient "http"
+)
+
+// ErrClosed is returned once the connection was closed with Close.
+var ErrClosed = errors.New("websocket connection closed")
+
+// Conn is a WebSocket connection that reconnects until it is closed. Send
+// and Receive may be called concurrently.
+type Conn struct {
+	client       *httpclient.HTTPClient
+	url          string
+	headers      map[string]string
+	subprotocols []string
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	onConnect    func(context.Context, *Conn) error
+	onDisconnect func(error)
+	dialer       *ws.Dialer
+
+	// ctx is cancelled by Close.
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopped  chan struct{}
+	messages chan []byte
+
+	mu    sync.Mutex
+	ws    *ws.Conn      // nil while reconnecting
+	ready chan struct{} // closed while connected
+
+	writeMu sync.Mutex
+}
+
+type Option func(*Conn)
+
+// WithHeaders adds headers to the handshake.
+func WithHeaders(headers map[string]string) Option {
+	return func(c *Conn) {
+		c.headers = headers
+	}
+}
+
+// WithSubprotocols offers subprotocols in the handshake.
+func WithSubprotocols(protocols ...string) Option {
+	return func(c *Conn) {
+		c.subprotocols = protocols
+	}
+}
+
+// WithPingInterval sets how often the server is pinged, 30s by default, or
+// never when it is zero. A connection on which nothing, not even a pong, is
+// read for the interval plus the pong timeout is dropped and reconnected.
+func WithPingInterval(interval time.Duration) Option {
+	return func(c *Conn) {
+		c.pingInterval = interval
+	}
+}
+
+// WithPongTimeout sets how long a ping may wait for its pong, 10s by
+// default.
+func WithPongTimeout(timeout time.Duration) Option {
+	return func(c *Conn) {
+		c.pongTimeout = timeout
+	}
+}
+
+// WithBackoff sets the bounds of the jittered exponential backoff between
+// reconnection attempts, 1s and 1m by default.
+func WithBackoff(min, max time.Duration) Option {
+	return func(c *Conn) {
+		c.minBackoff, c.maxBackoff = min, max
+	}
+}
+
+// WithConnectHook runs fn after every connection, e.g. to subscribe to
+// channels. Messages received meanwhile are delivered to Receive. When fn
+// fails the connection is dropped; the error is returned by Dial the first
+// time and retried with backoff afterwards.
+func WithConnectHook(fn func(ctx context.Context, conn *Conn) error) Option {
+	return func(c *Conn) {
+		c.onConnect = fn
+	}
+}
+
+// WithDisconnectHandler is called with the error that ended a connection,
+// and with every failed reconnection attempt.
+func WithDisconnectHandler(fn func(error)) Option {
+	return func(c *Conn) {
+		c.onDisconnect = fn
+	}
+}
+
+// Dial connects to url, a ws, wss, http or https URL, with the transport and
+// middleware of client. It fails when the first connection fails; later
+// ones are retried until Close.
+func Dial(ctx context.Context, client *httpclient.HTTPClient, url string, opts ...Option) (*Conn, error) {
+	c := &Conn{
+		client:       client,
+		url:          url,
+		pingInterval: 30 * time.Second,
+		pongTimeout:  10 * time.Second,
+		minBackoff:   time.Second,
+		maxBackoff:   time.Minute,
+		stopped:      make(chan struct{}),
+		messages:     make(chan []byte),
+		ready:        make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.dialer = newDialer(client.Transport(), c.subprotocols)
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+
+	done, err := c.connect(ctx)
+	if err != nil {
+		c.cancel()
+		return nil, err
+	}
+	go c.run(done)
+	return c, nil
+}
+
+// newDialer dials with the TLS, proxy and dial settings of transport when it
+// is an *http.Transport.
+func newDialer(transport http.RoundTripper, subprotocols []string) *ws.Dialer {
+	dialer := &ws.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: 45 * time.Second,
+		Subprotocols:     subprotocols,
+	}
+	if t, ok := transport.(*http.Transport); ok {
+		dialer.Proxy = t.Proxy
+		dialer.NetDialContext = t.DialContext
+		dialer.TLSClientConfig = t.TLSClientConfig.Clone()
+		if t.TLSHandshakeTimeout > 0 {
+			dialer.HandshakeTimeout = t.TLSHandshakeTimeout
+		}
+	}
+	return dialer
+}
+
+// Send sends v as a JSON text message, waiting for a connection if the
+// connection is being reestablished.
+func (c *Conn) Send(ctx context.Context, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encode websocket message failed: %s", err)
+	}
+	return c.write(ctx, data)
+}
+
+// Receive waits for the next message and decodes it as JSON into v.
+func (c *Conn) Receive(ctx context.Context, v interface{}) error {
+	data, err := c.ReceiveBytes(ctx)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("decode websocket message failed: %s, %s", err, data)
+	}
+	return nil
+}
+
+// ReceiveBytes waits for the next message and returns it undecoded.
+func (c *Conn) ReceiveBytes(ctx context.Context) ([]byte, error) {
+	select {
+	case data := <-c.messages:
+		return data, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.ctx.Done():
+		return nil, ErrClosed
+	}
+}
+
+// Close closes the connection and stops reconnecting.
+func (c *Conn) Close() error {
+	c.cancel()
+	c.mu.Lock()
+	conn := c.ws
+	c.mu.Unlock()
+	if conn != nil {
+		deadline := time.Now().Add(time.Second)
+		conn.WriteControl(ws.CloseMessage, ws.FormatCloseMessage(ws.CloseNormalClosure, ""), deadline)
+		conn.Close()
+	}
+	<-c.stopped
+	return nil
+}
+
+func (c *Conn) write(ctx context.Context, data []byte) error {
+	for {
+		c.mu.Lock()
+		conn, ready := c.ws, c.ready
+		c.mu.Unlock()
+		if conn == nil {
+			select {
+			case <-ready:
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-c.ctx.Done():
+				return ErrClosed
+			}
+		}
+
+		c.writeMu.Lock()
+		deadline, _ := ctx.Deadline()
+		conn.SetWriteDeadline(deadline)
+		err := conn.WriteMessage(ws.TextMessage, data)
+		c.writeMu.Unlock()
+		if err != nil {
+			// The reader notices the closed connection and reconnects.
+			conn.Close()
+			return fmt.Errorf("send websocket message failed: %s", err)
+		}
+		return nil
+	}
+}
+
+// run reconnects every time a connection ends, until Close.
+func (c *Conn) run(done <-chan error) {
+	defer close(c.stopped)
+	for {
+		select {
+		case err := <-done:
+			c.detach()
+			if c.ctx.Err() != nil {
+				return
+			}
+			c.disconnected(err)
+		case <-c.ctx.Done():
+			<-done
+			return
+		}
+
+		for failures := 0; ; failures++ {
+			timer := time.NewTimer(c.backoff(failures))
+			select {
+			case <-timer.C:
+			case <-c.ctx.Done():
+				timer.Stop()
+				return
+			}
+			var err error
+			if done, err = c.connect(c.ctx); err == nil {
+				break
+			}
+			if c.ctx.Err() != nil {
+				return
+			}
+			c.disconnected(err)
+		}
+	}
+}
+
+// connect dials, starts reading and runs the connect hook. The returned
+// channel receives the error that ends the connection.
+func (c *Conn) connect(ctx context.Context) (<-chan error, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if c.ctx.Err() != nil {
+		c.mu.Unlock()
+		conn.Close()
+		return nil, ErrClosed
+	}
+	c.ws = conn
+	close(c.ready)
+	c.mu.Unlock()
+
+	done := make(chan error, 1)
+	// drop stops the reader of a connection given up on before connect
+	// returns, when it may be waiting to deliver a message no one receives.
+	drop := make(chan struct{})
+	go c.read(conn, drop, done)
+	abort := func(err error) (<-chan error, error) {
+		close(drop)
+		conn.Close()
+		<-done
+		c.detach()
+		return nil, err
+	}
+	if c.onConnect != nil {
+		if err := c.onConnect(ctx, c); err != nil {
+			return abort(fmt.Errorf("websocket connect hook failed: %s", err))
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return abort(err)
+	}
+	return done, nil
+}
+
+func (c *Conn) dial(ctx context.Context) (*ws.Conn, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch req.URL.Scheme {
+	case "ws":
+		req.URL.Scheme = "http"
+	case "wss":
+		req.URL.Scheme = "https"
+	}
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
+	}
+	prepared, err := c.client.Prepare(req)
+	if err != nil {
+		return nil, err
+	}
+
+	u := *prepared.URL
+	u.Scheme = "ws"
+	if prepared.URL.Scheme == "https" {
+		u.Scheme = "wss"
+	}
+	header := prepared.Header.Clone()
+	// The dialer sets the handshake headers itself.
+	for _, key := range []string{"Upgrade", "Connection", "Sec-Websocket-Key", "Sec-Websocket-Version", "Sec-Websocket-Extensions", "Sec-Websocket-Protocol"} {
+		header.Del(key)
+	}
+
+	conn, resp, err := c.dialer.DialContext(ctx, u.String(), header)
+	if err != nil {
+		if resp != nil && resp.StatusCode != http.StatusSwitchingProtocols {
+			// The dialer leaves up to 1KiB of the body.
+			body, _ := io.ReadAll(resp.Body)
+			return nil, &httpclient.StatusError{StatusCode: resp.StatusCode, Body: body}
+		}
+		return nil, fmt.Errorf("dial websocket failed: %s", err)
+	}
+	return conn, nil
+}
+
+// read delivers the messages of conn until it fails or drop is closed, and
+// keeps the connection alive with pings.
+func (c *Conn) read(conn *ws.Conn, drop <-chan struct{}, done chan<- error) {
+	stopPing := make(chan struct{})
+	defer close(stopPing)
+	if c.pingInterval > 0 {
+		timeout := c.pingInterval + c.pongTimeout
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(timeout))
+		})
+		go c.ping(conn, stopPing)
+	}
+
+	for {
+		if c.pingInterval > 0 {
+			conn.SetReadDeadline(time.Now().Add(c.pingInterval + c.pongTimeout))
+		}
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			conn.Close()
+			done <- err
+			return
+		}
+		select {
+		case c.messages <- data:
+		case <-drop:
+			conn.Close()
+			done <- ErrClosed
+			return
+		case <-c.ctx.Done():
+			conn.Close()
+			done <- ErrClosed
+			return
+		}
+	}
+}
+
+func (c *Conn) ping(conn *ws.Conn, stop <-chan struct{}) {
+	ticker := time.NewTicker(c.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := conn.WriteControl(ws.PingMessage, nil, time.Now().Add(c.pongTimeout)); err != nil {
+				return
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (c *Conn) detach() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ws != nil {
+		c.ws = nil
+		c.ready = make(chan struct{})
+	}
+}
+
+func (c *Conn) disconnected(err error) {
+	if c.onDisconnect != nil {
+		c.onDisconnect(err)
+	}
+}
+
+func (c *Conn) backoff(failures int) time.Duration {
+	d := c.minBackoff
+	for i := 0; i < failures && d < c.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > c.maxBackoff {
+		d = c.maxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
@@ -0,0 +1,260 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+
+	httpclient "http"
+)
+
+type subscribe struct {
+	Channel string `json:"channel"`
+}
+
+type tick struct {
+	Channel string `json:"channel"`
+	Seq     int    `json:"seq"`
+}
+
+var upgrader = ws.Upgrader{}
+
+// feed is a server that answers subscriptions with ticks. Each connection
+// gets a number from 1 and behaves as its handler says.
+type feed struct {
+	mu          sync.Mutex
+	connections int
+	subscribes  []string
+	handle      func(n int, conn *ws.Conn, channel string)
+}
+
+func (f *feed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer token" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	f.mu.Lock()
+	f.connections++
+	n := f.connections
+	f.mu.Unlock()
+
+	var sub subscribe
+	if err := conn.ReadJSON(&sub); err != nil {
+		return
+	}
+	f.mu.Lock()
+	f.subscribes = append(f.subscribes, sub.Channel)
+	f.mu.Unlock()
+	f.handle(n, conn, sub.Channel)
+}
+
+func bearer(next http.RoundTripper) http.RoundTripper {
+	return httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", "Bearer token")
+		return next.RoundTrip(req)
+	})
+}
+
+func subscribeHook(channel string) Option {
+	return WithConnectHook(func(ctx context.Context, conn *Conn) error {
+		return conn.Send(ctx, subscribe{Channel: channel})
+	})
+}
+
+func TestSendReceive(t *testing.T) {
+	fmt.Println("TestSendReceive")
+	f := &feed{handle: func(n int, conn *ws.Conn, channel string) {
+		for seq := 1; seq <= 3; seq++ {
+			conn.WriteJSON(tick{Channel: channel, Seq: seq})
+		}
+		conn.ReadMessage()
+	}}
+	// TLS and authorization both come from the client.
+	server := httptest.NewTLSServer(f)
+	defer server.Close()
+	client := httpclient.NewHTTPClient(
+		httpclient.WithTransport(server.Client().Transport),
+		httpclient.WithMiddleware(bearer))
+
+	ctx := context.Background()
+	conn, err := Dial(ctx, client, strings.Replace(server.URL, "https", "wss", 1), subscribeHook("trades"))
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+	for seq := 1; seq <= 3; seq++ {
+		var got tick
+		if err := conn.Receive(ctx, &got); err != nil || got != (tick{Channel: "trades", Seq: seq}) {
+			t.Fatalf("unexpected tick %+v, %v", got, err)
+		}
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	fmt.Println("TestReconnect")
+	f := &feed{handle: func(n int, conn *ws.Conn, channel string) {
+		conn.WriteJSON(tick{Channel: channel, Seq: n})
+		if n == 1 {
+			// Drop the first connection without a close frame.
+			return
+		}
+		conn.ReadMessage()
+	}}
+	server := httptest.NewServer(f)
+	defer server.Close()
+	client := httpclient.NewHTTPClient(httpclient.WithMiddleware(bearer))
+
+	var mu sync.Mutex
+	var disconnects []error
+	ctx := context.Background()
+	conn, err := Dial(ctx, client, server.URL, subscribeHook("trades"),
+		WithBackoff(10*time.Millisecond, 20*time.Millisecond),
+		WithDisconnectHandler(func(err error) {
+			mu.Lock()
+			disconnects = append(disconnects, err)
+			mu.Unlock()
+		}))
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	for seq := 1; seq <= 2; seq++ {
+		var got tick
+		if err := conn.Receive(ctx, &got); err != nil || got.Seq != seq {
+			t.Fatalf("unexpected tick %+v, %v", got, err)
+		}
+	}
+	conn.Close()
+
+	if err := conn.Receive(ctx, &tick{}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected closed, got %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(disconnects) != 1 {
+		t.Fatalf("expected a disconnect, got %v", disconnects)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.subscribes) != 2 || f.subscribes[1] != "trades" {
+		t.Fatalf("expected a resubscribe, got %q", f.subscribes)
+	}
+}
+
+func TestPingTimeout(t *testing.T) {
+	fmt.Println("TestPingTimeout")
+	f := &feed{handle: func(n int, conn *ws.Conn, channel string) {
+		if n == 1 {
+			// Stop reading, so pings go unanswered.
+			time.Sleep(time.Second)
+			return
+		}
+		conn.WriteJSON(tick{Channel: channel, Seq: n})
+		conn.ReadMessage()
+	}}
+	server := httptest.NewServer(f)
+	defer server.Close()
+	client := httpclient.NewHTTPClient(httpclient.WithMiddleware(bearer))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := Dial(ctx, client, server.URL, subscribeHook("trades"),
+		WithPingInterval(50*time.Millisecond), WithPongTimeout(50*time.Millisecond),
+		WithBackoff(10*time.Millisecond, 20*time.Millisecond))
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+	start := time.Now()
+	var got tick
+	if err := conn.Receive(ctx, &got); err != nil || got.Seq != 2 {
+		t.Fatalf("unexpected tick %+v, %v", got, err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("dead connection noticed after %s", elapsed)
+	}
+}
+
+func TestDialErrors(t *testing.T) {
+	fmt.Println("TestDialErrors")
+	server := httptest.NewServer(&feed{})
+	defer server.Close()
+	ctx := context.Background()
+
+	var statusErr *httpclient.StatusError
+	_, err := Dial(ctx, httpclient.NewHTTPClient(), server.URL)
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected a 401 status error, got %v", err)
+	}
+
+	failing := WithConnectHook(func(ctx context.Context, conn *Conn) error {
+		return errors.New("no such channel")
+	})
+	client := httpclient.NewHTTPClient(httpclient.WithMiddleware(bearer))
+	if _, err := Dial(ctx, client, server.URL, failing); err == nil || !strings.Contains(err.Error(), "no such channel") {
+		t.Fatalf("expected the hook error, got %v", err)
+	}
+}
+
+func TestConnectHookFailsAfterMessage(t *testing.T) {
+	fmt.Println("TestConnectHookFailsAfterMessage")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.WriteJSON(tick{Channel: "trades", Seq: 1})
+		conn.ReadMessage()
+	}))
+	defer server.Close()
+	client := httpclient.NewHTTPClient()
+
+	dial := func(ctx context.Context, hook func(ctx context.Context) error) error {
+		errs := make(chan error, 1)
+		go func() {
+			_, err := Dial(ctx, client, server.URL, WithConnectHook(func(ctx context.Context, conn *Conn) error {
+				// Give the reader time to wait on the undelivered message.
+				time.Sleep(100 * time.Millisecond)
+				return hook(ctx)
+			}))
+			errs <- err
+		}()
+		select {
+		case err := <-errs:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatalf("dial hung")
+			return nil
+		}
+	}
+
+	err := dial(context.Background(), func(context.Context) error {
+		return errors.New("no such channel")
+	})
+	if err == nil || !strings.Contains(err.Error(), "no such channel") {
+		t.Fatalf("expected the hook error, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err = dial(ctx, func(context.Context) error {
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the dial context error, got %v", err)
+	}
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"errors"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+
+	httpclient "http"
 )
 
 // album represents data about a record album.
@@ -14,7 +18,7 @@ type album struct {
 	Price  float64 `json:"price"`
 }
 
-// albums slice to seed record album data.
+// albums seeds the in-memory album store.
 var albums = []album{
 	{ID: "1", Title: "Blue Train", Artist: "John Coltrane", Price: 56.99},
 	{ID: "2", Title: "Jeru", Artist: "Gerry Mulligan", Price: 17.99},
@@ -22,46 +26,129 @@ var albums = []album{
 }
 
 func main() {
-	router := gin.Default()
-	router.GET("/albums", getAlbums)
-	router.GET("/albums/:id", getAlbumByID)
-	router.POST("/albums", postAlbums)
+	store, err := newAlbumStore()
+	if err != nil {
+		log.Fatal(err)
+	}
+	receiver, err := newCarrierReceiver()
+	if err != nil {
+		log.Fatal(err)
+	}
+	router := newRouter(store)
+	if receiver != nil {
+		router.POST("/webhooks/carrier", webhookHandler(receiver))
+	}
 
 	router.Run("localhost:8080")
 }
 
+// newRouter routes the album endpoints to store.
+func newRouter(store AlbumStore) *gin.Engine {
+	router := gin.Default()
+	router.GET("/albums", getAlbums(store))
+	router.GET("/albums/:id", getAlbumByID(store))
+	router.POST("/albums", postAlbums(store))
+	router.PUT("/albums/:id", putAlbum(store))
+	router.DELETE("/albums/:id", deleteAlbum(store))
+	return router
+}
+
 // getAlbums responds with the list of all albums as JSON.
-func getAlbums(c *gin.Context) {
-	c.IndentedJSON(http.StatusOK, albums)
+func getAlbums(store AlbumStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := store.List(c.Request.Context())
+		if err != nil {
+			abortWithStoreError(c, err)
+			return
+		}
+		c.IndentedJSON(http.StatusOK, list)
+	}
 }
 
-// postAlbums adds an album from JSON received in the request body.
-func postAlbums(c *gin.Context) {
-	var newAlbum album
+// postAlbums adds an album from JSON received in the request body. The store
+// chooses the ID of an album sent without one.
+func postAlbums(store AlbumStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var newAlbum album
 
-	// Call BindJSON to bind the received JSON to
-	// newAlbum.
-	if err := c.BindJSON(&newAlbum); err != nil {
-		return
-	}
+		// Call ShouldBindJSON to bind the received JSON to
+		// newAlbum.
+		if err := c.ShouldBindJSON(&newAlbum); err != nil {
+			abortWithProblem(c, httpclient.NewProblem(http.StatusBadRequest, err.Error()))
+			return
+		}
 
-	// Add the new album to the slice.
-	albums = append(albums, newAlbum)
-	c.IndentedJSON(http.StatusCreated, newAlbum)
+		created, err := store.Create(c.Request.Context(), newAlbum)
+		if err != nil {
+			abortWithStoreError(c, err)
+			return
+		}
+		c.IndentedJSON(http.StatusCreated, created)
+	}
 }
 
 // getAlbumByID locates the album whose ID value matches the id
 // parameter sent by the client, then returns that album as a response.
-func getAlbumByID(c *gin.Context) {
-	id := c.Param("id")
-
-	// Loop through the list of albums, looking for
-	// an album whose ID value matches the parameter.
-	for _, a := range albums {
-		if a.ID == id {
-			c.IndentedJSON(http.StatusOK, a)
+func getAlbumByID(store AlbumStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		a, err := store.Get(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			abortWithStoreError(c, err)
 			return
 		}
+		c.IndentedJSON(http.StatusOK, a)
+	}
+}
+
+// putAlbum replaces the album with the id parameter by the JSON received in
+// the request body. An ID in the body must match the parameter.
+func putAlbum(store AlbumStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var a album
+		if err := c.ShouldBindJSON(&a); err != nil {
+			abortWithProblem(c, httpclient.NewProblem(http.StatusBadRequest, err.Error()))
+			return
+		}
+		id := c.Param("id")
+		if a.ID != "" && a.ID != id {
+			abortWithProblem(c, httpclient.NewProblem(http.StatusBadRequest, "album id does not match the path"))
+			return
+		}
+		a.ID = id
+
+		if err := store.Update(c.Request.Context(), a); err != nil {
+			abortWithStoreError(c, err)
+			return
+		}
+		c.IndentedJSON(http.StatusOK, a)
+	}
+}
+
+// deleteAlbum removes the album with the id parameter.
+func deleteAlbum(store AlbumStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := store.Delete(c.Request.Context(), c.Param("id")); err != nil {
+			abortWithStoreError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// abortWithStoreError responds with the problem matching an AlbumStore error.
+func abortWithStoreError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrAlbumNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrAlbumExists):
+		status = http.StatusConflict
+	case errors.Is(err, ErrInvalidAlbumID):
+		status = http.StatusBadRequest
+	default:
+		log.Printf("album store: %v", err)
+		abortWithProblem(c, httpclient.NewProblem(status, "album store failed"))
+		return
 	}
-	c.IndentedJSON(http.StatusNotFound, gin.H{"message": "album not found"})
+	abortWithProblem(c, httpclient.NewProblem(status, err.Error()))
 }
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/go-sql-driver/mysql"
+
+	"db"
+)
+
+// mysqlErrDuplicateEntry is the MySQL error number for a duplicate key.
+const mysqlErrDuplicateEntry = 1062
+
+// mysqlStore is an AlbumStore over the album table of the db package, whose
+// rows are db.Album. Album IDs are the table's integer ids.
+type mysqlStore struct {
+	db *sql.DB
+}
+
+// newMySQLStore connects to the database at dsn, a go-sql-driver data source
+// name such as "user:password@tcp(localhost:3306)/test".
+func newMySQLStore(dsn string) (*mysqlStore, error) {
+	if dsn == "" {
+		return nil, errors.New("mysql album store needs ALBUM_STORE_DSN")
+	}
+	conn, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open mysql album store failed: %s", err)
+	}
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("connect mysql album store failed: %s", err)
+	}
+	return &mysqlStore{db: conn}, nil
+}
+
+func (s *mysqlStore) List(ctx context.Context) ([]album, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, title, artist, price FROM album ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("list albums failed: %s", err)
+	}
+	defer rows.Close()
+
+	list := []album{}
+	for rows.Next() {
+		var row db.Album
+		if err := rows.Scan(&row.ID, &row.Title, &row.Artist, &row.Price); err != nil {
+			return nil, fmt.Errorf("list albums failed: %s", err)
+		}
+		list = append(list, fromRow(row))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list albums failed: %s", err)
+	}
+	return list, nil
+}
+
+func (s *mysqlStore) Get(ctx context.Context, id string) (album, error) {
+	rowID, err := parseAlbumID(id)
+	if err != nil {
+		return album{}, err
+	}
+	var row db.Album
+	err = s.db.QueryRowContext(ctx, "SELECT id, title, artist, price FROM album WHERE id = ?", rowID).
+		Scan(&row.ID, &row.Title, &row.Artist, &row.Price)
+	if errors.Is(err, sql.ErrNoRows) {
+		return album{}, ErrAlbumNotFound
+	}
+	if err != nil {
+		return album{}, fmt.Errorf("get album failed: %s", err)
+	}
+	return fromRow(row), nil
+}
+
+func (s *mysqlStore) Create(ctx context.Context, a album) (album, error) {
+	var result sql.Result
+	var err error
+	if a.ID == "" {
+		result, err = s.db.ExecContext(ctx, "INSERT INTO album (title, artist, price) VALUES (?, ?, ?)",
+			a.Title, a.Artist, float32(a.Price))
+	} else {
+		row, perr := toRow(a)
+		if perr != nil {
+			return album{}, perr
+		}
+		result, err = s.db.ExecContext(ctx, "INSERT INTO album (id, title, artist, price) VALUES (?, ?, ?, ?)",
+			row.ID, row.Title, row.Artist, row.Price)
+	}
+	if err != nil {
+		return album{}, createError(err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return album{}, fmt.Errorf("create album failed: %s", err)
+	}
+	a.ID = strconv.FormatInt(id, 10)
+	return a, nil
+}
+
+func (s *mysqlStore) Update(ctx context.Context, a album) error {
+	row, err := toRow(a)
+	if err != nil {
+		return err
+	}
+	// Without CLIENT_FOUND_ROWS, MySQL counts only changed rows, so an
+	// unchanged album is told from a missing one by looking it up.
+	result, err := s.db.ExecContext(ctx, "UPDATE album SET title = ?, artist = ?, price = ? WHERE id = ?",
+		row.Title, row.Artist, row.Price, row.ID)
+	if err != nil {
+		return fmt.Errorf("update album failed: %s", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n > 0 {
+		return nil
+	}
+	_, err = s.Get(ctx, a.ID)
+	return err
+}
+
+func (s *mysqlStore) Delete(ctx context.Context, id string) error {
+	rowID, err := parseAlbumID(id)
+	if err != nil {
+		return err
+	}
+	result, err := s.db.ExecContext(ctx, "DELETE FROM album WHERE id = ?", rowID)
+	if err != nil {
+		return fmt.Errorf("delete album failed: %s", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("delete album failed: %s", err)
+	}
+	if n == 0 {
+		return ErrAlbumNotFound
+	}
+	return nil
+}
+
+// createError maps an insert error to the AlbumStore errors.
+func createError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDuplicateEntry {
+		return ErrAlbumExists
+	}
+	return fmt.Errorf("create album failed: %s", err)
+}
+
+func toRow(a album) (db.Album, error) {
+	id, err := parseAlbumID(a.ID)
+	if err != nil {
+		return db.Album{}, err
+	}
+	return db.Album{ID: id, Title: a.Title, Artist: a.Artist, Price: float32(a.Price)}, nil
+}
+
+func fromRow(row db.Album) album {
+	return album{
+		ID:     strconv.FormatInt(row.ID, 10),
+		Title:  row.Title,
+		Artist: row.Artist,
+		Price:  price(row.Price),
+	}
+}
+
+// price widens a stored price to the shortest float64 printing the same,
+// 56.99 rather than 56.9900016784668.
+func price(p float32) float64 {
+	f, _ := strconv.ParseFloat(strconv.FormatFloat(float64(p), 'f', -1, 32), 64)
+	return f
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+
+	"db"
+)
+
+func TestAlbumRows(t *testing.T) {
+	fmt.Println("TestAlbumRows")
+	a := album{ID: "7", Title: "Blue Train", Artist: "John Coltrane", Price: 56.99}
+	row, err := toRow(a)
+	if err != nil || row != (db.Album{ID: 7, Title: "Blue Train", Artist: "John Coltrane", Price: 56.99}) {
+		t.Fatalf("unexpected row %+v, %v", row, err)
+	}
+	if got := fromRow(row); got != a {
+		t.Fatalf("expected %+v back, got %+v", a, got)
+	}
+	if _, err := toRow(album{ID: "seven"}); !errors.Is(err, ErrInvalidAlbumID) {
+		t.Fatalf("expected ErrInvalidAlbumID, got %v", err)
+	}
+}
+
+func TestPrice(t *testing.T) {
+	fmt.Println("TestPrice")
+	for _, p := range []float64{0, 17.99, 39.99, 56.99, 1e6} {
+		if got := price(float32(p)); got != p {
+			t.Fatalf("expected %v, got %v", p, got)
+		}
+	}
+}
+
+func TestCreateError(t *testing.T) {
+	fmt.Println("TestCreateError")
+	if err := createError(&mysql.MySQLError{Number: mysqlErrDuplicateEntry}); !errors.Is(err, ErrAlbumExists) {
+		t.Fatalf("expected ErrAlbumExists, got %v", err)
+	}
+	err := createError(&mysql.MySQLError{Number: 1146, Message: "Table 'test.album' doesn't exist"})
+	if err == nil || errors.Is(err, ErrAlbumExists) || err.Error() != "create album failed: Error 1146: Table 'test.album' doesn't exist" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+
+	httpclient "http"
+)
+
+// abortWithProblem stops the handler chain and responds with RFC 9457 problem
+// details. The request path is used as the problem instance when none is set.
+func abortWithProblem(c *gin.Context, p *httpclient.Problem) {
+	if p.Instance == "" {
+		p.Instance = c.Request.URL.Path
+	}
+	httpclient.WriteProblem(c.Writer, p)
+	c.Abort()
+}
@@ -1,42 +1,122 @@
 package main
 
 import (
-	// "fmt"
-	"log"
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
 
 	"github.com/gin-gonic/gin"
-	// "github.com/ahmetalpbalkan/go-linq"
 )
 
-// album represents data about a record album.
-type album struct {
-	ID     string  `json:"id"`
-	Title  string  `json:"title"`
-	Artist string  `json:"artist"`
-	Price  float64 `json:"price"`
+func init() {
+	gin.SetMode(gin.TestMode)
 }
 
-// albums slice to seed record album data.
-var albums = []album{
-	{ID: "1", Title: "Blue Train", Artist: "John Coltrane", Price: 56.99},
-	{ID: "2", Title: "Jeru", Artist: "Gerry Mulligan", Price: 17.99},
-	{ID: "3", Title: "Sarah Vaughan and Clifford Brown", Artist: "Sarah Vaughan", Price: 39.99},
+func serve(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
 }
 
-// getAlbums responds with the list of all albums as JSON.
-func getAlbums(c *gin.Context) {
-	c.IndentedJSON(http.StatusOK, albums)
+func TestGetAlbums01(t *testing.T) {
+	fmt.Println("TestGetAlbums01")
+	router := newRouter(newMemoryStore(albums...))
+
+	w := serve(router, http.MethodGet, "/albums", "")
+	var list []album
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("failed to list albums: %d %s", w.Code, w.Body)
+	}
+	if len(list) != len(albums) || list[0] != albums[0] {
+		t.Fatalf("unexpected albums %+v", list)
+	}
+
+	w = serve(router, http.MethodGet, "/albums/2", "")
+	var a album
+	if err := json.Unmarshal(w.Body.Bytes(), &a); err != nil || a != albums[1] {
+		t.Fatalf("unexpected album %d %s", w.Code, w.Body)
+	}
+	if w = serve(router, http.MethodGet, "/albums/9", ""); w.Code != http.StatusNotFound ||
+		w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("expected a not found problem, got %d %s", w.Code, w.Body)
+	}
+	if w = serve(router, http.MethodGet, "/albums/abc", ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected a non-numeric id to be rejected, got %d %s", w.Code, w.Body)
+	}
 }
 
-func TestListAlbums01(t *testing.T) {
-	log.Println("TestListAlbums01")
+func TestAlbumLifecycle(t *testing.T) {
+	fmt.Println("TestAlbumLifecycle")
+	router := newRouter(newMemoryStore(albums...))
+
+	w := serve(router, http.MethodPost, "/albums", `{"title": "Kind of Blue", "artist": "Miles Davis", "price": 29.99}`)
+	var created album
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || w.Code != http.StatusCreated || created.ID != "4" {
+		t.Fatalf("failed to create album: %d %s", w.Code, w.Body)
+	}
+	if w = serve(router, http.MethodPost, "/albums", `{"id": "1", "title": "Blue Train"}`); w.Code != http.StatusConflict {
+		t.Fatalf("expected a conflict, got %d %s", w.Code, w.Body)
+	}
+
+	if w = serve(router, http.MethodPut, "/albums/4", `{"title": "Kind of Blue", "artist": "Miles Davis", "price": 19.99}`); w.Code != http.StatusOK {
+		t.Fatalf("failed to update album: %d %s", w.Code, w.Body)
+	}
+	if w = serve(router, http.MethodPut, "/albums/4", `{"id": "5"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected a mismatched id to be rejected, got %d %s", w.Code, w.Body)
+	}
+	if w = serve(router, http.MethodPut, "/albums/9", `{"title": "Missing"}`); w.Code != http.StatusNotFound {
+		t.Fatalf("expected updating a missing album to fail, got %d %s", w.Code, w.Body)
+	}
+	w = serve(router, http.MethodGet, "/albums/4", "")
+	var updated album
+	if err := json.Unmarshal(w.Body.Bytes(), &updated); err != nil || updated.Price != 19.99 {
+		t.Fatalf("unexpected album %d %s", w.Code, w.Body)
+	}
+
+	if w = serve(router, http.MethodDelete, "/albums/4", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("failed to delete album: %d %s", w.Code, w.Body)
+	}
+	if w = serve(router, http.MethodDelete, "/albums/4", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected deleting twice to fail, got %d %s", w.Code, w.Body)
+	}
 }
 
-func TestGetAlbums01(t *testing.T) {
-	router := gin.Default()
-	router.GET("/albums", getAlbums)
+func TestConcurrentPostAlbums(t *testing.T) {
+	fmt.Println("TestConcurrentPostAlbums")
+	store := newMemoryStore(albums...)
+	router := newRouter(store)
+
+	const n = 50
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := fmt.Sprintf(`{"title": "Album %d", "artist": "Various", "price": 9.99}`, i)
+			if w := serve(router, http.MethodPost, "/albums", body); w.Code != http.StatusCreated {
+				t.Errorf("failed to create album %d: %d %s", i, w.Code, w.Body)
+			}
+			serve(router, http.MethodGet, "/albums", "")
+		}(i)
+	}
+	wg.Wait()
 
-	router.Run("localhost:8080")
+	w := serve(router, http.MethodGet, "/albums", "")
+	var list []album
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("failed to list albums: %v", err)
+	}
+	ids := map[string]bool{}
+	for _, a := range list {
+		ids[a.ID] = true
+	}
+	if len(list) != len(albums)+n || len(ids) != len(list) {
+		t.Fatalf("expected %d albums with distinct ids, got %d", len(albums)+n, len(list))
+	}
 }
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+var (
+	// ErrAlbumNotFound is returned for an ID no album has.
+	ErrAlbumNotFound = errors.New("album not found")
+	// ErrAlbumExists is returned when creating an album with an ID in use.
+	ErrAlbumExists = errors.New("album already exists")
+	// ErrInvalidAlbumID is returned for an ID that is not an integer.
+	ErrInvalidAlbumID = errors.New("invalid album id")
+)
+
+// AlbumStore keeps the record albums served by the service. Implementations
+// are safe for concurrent use.
+type AlbumStore interface {
+	List(ctx context.Context) ([]album, error)
+	Get(ctx context.Context, id string) (album, error)
+	// Create adds an album, choosing its ID when it has none, and returns
+	// it as stored.
+	Create(ctx context.Context, a album) (album, error)
+	// Update replaces the album with the same ID.
+	Update(ctx context.Context, a album) error
+	Delete(ctx context.Context, id string) error
+}
+
+// newAlbumStore creates the store named by ALBUM_STORE: "memory", the
+// default, seeded with the sample albums, or "mysql", connecting to the data
+// source name in ALBUM_STORE_DSN.
+func newAlbumStore() (AlbumStore, error) {
+	switch backend := os.Getenv("ALBUM_STORE"); backend {
+	case "", "memory":
+		return newMemoryStore(albums...), nil
+	case "mysql":
+		return newMySQLStore(os.Getenv("ALBUM_STORE_DSN"))
+	default:
+		return nil, fmt.Errorf("unknown album store %q", backend)
+	}
+}
+
+// memoryStore is an AlbumStore in memory, lost on restart. Like the MySQL
+// store, it only holds numeric IDs, kept in their canonical form.
+type memoryStore struct {
+	mu     sync.RWMutex
+	albums map[int64]album
+	nextID int64
+}
+
+// newMemoryStore creates a store holding seed, whose IDs must be numeric.
+func newMemoryStore(seed ...album) *memoryStore {
+	s := &memoryStore{albums: make(map[int64]album, len(seed)), nextID: 1}
+	for _, a := range seed {
+		id, err := parseAlbumID(a.ID)
+		if err != nil {
+			panic(fmt.Sprintf("seed album id %q: %s", a.ID, err))
+		}
+		s.put(id, a)
+	}
+	return s
+}
+
+// put stores a under id, keeping generated IDs above it.
+func (s *memoryStore) put(id int64, a album) {
+	a.ID = strconv.FormatInt(id, 10)
+	s.albums[id] = a
+	if id >= s.nextID {
+		s.nextID = id + 1
+	}
+}
+
+func (s *memoryStore) List(ctx context.Context) ([]album, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]int64, 0, len(s.albums))
+	for id := range s.albums {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	list := make([]album, 0, len(ids))
+	for _, id := range ids {
+		list = append(list, s.albums[id])
+	}
+	return list, nil
+}
+
+func (s *memoryStore) Get(ctx context.Context, id string) (album, error) {
+	n, err := parseAlbumID(id)
+	if err != nil {
+		return album{}, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	a, ok := s.albums[n]
+	if !ok {
+		return album{}, ErrAlbumNotFound
+	}
+	return a, nil
+}
+
+func (s *memoryStore) Create(ctx context.Context, a album) (album, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextID
+	if a.ID != "" {
+		n, err := parseAlbumID(a.ID)
+		if err != nil {
+			return album{}, err
+		}
+		if _, ok := s.albums[n]; ok {
+			return album{}, ErrAlbumExists
+		}
+		id = n
+	}
+	s.put(id, a)
+	return s.albums[id], nil
+}
+
+func (s *memoryStore) Update(ctx context.Context, a album) error {
+	id, err := parseAlbumID(a.ID)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.albums[id]; !ok {
+		return ErrAlbumNotFound
+	}
+	s.put(id, a)
+	return nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, id string) error {
+	n, err := parseAlbumID(id)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.albums[n]; !ok {
+		return ErrAlbumNotFound
+	}
+	delete(s.albums, n)
+	return nil
+}
+
+// parseAlbumID parses the numeric ID both stores hold.
+func parseAlbumID(id string) (int64, error) {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidAlbumID
+	}
+	return n, nil
+}
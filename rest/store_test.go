@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+// testAlbumStore checks the behaviour every AlbumStore shares. It leaves the
+// albums it did not create alone, so it can run against a shared database.
+func testAlbumStore(t *testing.T, store AlbumStore) {
+	ctx := context.Background()
+	created, err := store.Create(ctx, album{Title: "Kind of Blue", Artist: "Miles Davis", Price: 29.99})
+	if err != nil || created.ID == "" {
+		t.Fatalf("failed to create album: %+v, %v", created, err)
+	}
+	defer store.Delete(ctx, created.ID)
+	if got, err := store.Get(ctx, created.ID); err != nil || got != created {
+		t.Fatalf("expected %+v, got %+v, %v", created, got, err)
+	}
+	if _, err := store.Create(ctx, created); !errors.Is(err, ErrAlbumExists) {
+		t.Fatalf("expected ErrAlbumExists, got %v", err)
+	}
+
+	created.Price = 19.99
+	if err := store.Update(ctx, created); err != nil {
+		t.Fatalf("failed to update album: %v", err)
+	}
+	// Updating with the same values is not mistaken for a missing album.
+	if err := store.Update(ctx, created); err != nil {
+		t.Fatalf("failed to update album unchanged: %v", err)
+	}
+	list, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("failed to list albums: %v", err)
+	}
+	found := false
+	for i, a := range list {
+		if i > 0 && !albumIDLess(list[i-1].ID, a.ID) {
+			t.Fatalf("expected albums ordered by id, got %s before %s", list[i-1].ID, a.ID)
+		}
+		found = found || a == created
+	}
+	if !found {
+		t.Fatalf("expected %+v in %+v", created, list)
+	}
+
+	if err := store.Delete(ctx, created.ID); err != nil {
+		t.Fatalf("failed to delete album: %v", err)
+	}
+	if _, err := store.Get(ctx, created.ID); !errors.Is(err, ErrAlbumNotFound) {
+		t.Fatalf("expected ErrAlbumNotFound after delete, got %v", err)
+	}
+	if err := store.Update(ctx, created); !errors.Is(err, ErrAlbumNotFound) {
+		t.Fatalf("expected ErrAlbumNotFound updating, got %v", err)
+	}
+	if err := store.Delete(ctx, created.ID); !errors.Is(err, ErrAlbumNotFound) {
+		t.Fatalf("expected ErrAlbumNotFound deleting twice, got %v", err)
+	}
+
+	// Both stores only hold numeric IDs.
+	if _, err := store.Get(ctx, "abc"); !errors.Is(err, ErrInvalidAlbumID) {
+		t.Fatalf("expected ErrInvalidAlbumID from Get, got %v", err)
+	}
+	if _, err := store.Create(ctx, album{ID: "abc"}); !errors.Is(err, ErrInvalidAlbumID) {
+		t.Fatalf("expected ErrInvalidAlbumID from Create, got %v", err)
+	}
+	if err := store.Update(ctx, album{ID: "abc"}); !errors.Is(err, ErrInvalidAlbumID) {
+		t.Fatalf("expected ErrInvalidAlbumID from Update, got %v", err)
+	}
+	if err := store.Delete(ctx, "abc"); !errors.Is(err, ErrInvalidAlbumID) {
+		t.Fatalf("expected ErrInvalidAlbumID from Delete, got %v", err)
+	}
+}
+
+// albumIDLess orders numeric IDs by value.
+func albumIDLess(l, r string) bool {
+	ln, _ := parseAlbumID(l)
+	rn, _ := parseAlbumID(r)
+	return ln < rn
+}
+
+func TestMemoryStore(t *testing.T) {
+	fmt.Println("TestMemoryStore")
+	testAlbumStore(t, newMemoryStore(albums...))
+}
+
+func TestMySQLStore(t *testing.T) {
+	fmt.Println("TestMySQLStore")
+	dsn := os.Getenv("ALBUM_STORE_DSN")
+	if dsn == "" {
+		t.Skip("set ALBUM_STORE_DSN to test the mysql album store")
+	}
+	store, err := newMySQLStore(dsn)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer store.db.Close()
+	testAlbumStore(t, store)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"http/webhook"
+)
+
+// shipmentEvent is the payload of the carrier's shipment webhooks.
+type shipmentEvent struct {
+	Type           string `json:"type"`
+	OrderID        string `json:"orderid"`
+	TrackingNumber string `json:"tracking_number"`
+	Status         string `json:"status"`
+}
+
+// newCarrierReceiver creates the receiver for carrier webhooks, accepting the
+// comma separated Standard Webhooks secrets in CARRIER_WEBHOOK_SECRETS, each
+// "whsec_" followed by the base64 encoded key. It returns nil when no secret
+// is configured, and an error for a secret that does not decode.
+func newCarrierReceiver() (*webhook.Receiver, error) {
+	var secrets [][]byte
+	for _, s := range strings.Split(os.Getenv("CARRIER_WEBHOOK_SECRETS"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			secret, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, "whsec_"))
+			if err != nil {
+				return nil, fmt.Errorf("decode CARRIER_WEBHOOK_SECRETS failed: %s", err)
+			}
+			secrets = append(secrets, secret)
+		}
+	}
+	if len(secrets) == 0 {
+		return nil, nil
+	}
+
+	r := webhook.NewReceiver(webhook.StandardWebhooks, secrets)
+	for _, eventType := range []string{"shipment.created", "shipment.delivered"} {
+		webhook.Handle(r, eventType, func(ctx context.Context, event webhook.Event[shipmentEvent]) error {
+			log.Printf("webhook %s %s: order %s is %s", event.ID, event.Type, event.Data.OrderID, event.Data.Status)
+			return nil
+		})
+	}
+	return r, nil
+}
+
+// webhookHandler adapts a webhook receiver to gin, stopping the handler chain
+// when a delivery is rejected.
+func webhookHandler(r *webhook.Receiver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r.ServeHTTP(c.Writer, c.Request)
+		if c.Writer.Status() >= http.StatusBadRequest {
+			c.Abort()
+		}
+	}
+}
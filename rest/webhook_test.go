@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"http/webhook"
+)
+
+func TestCarrierReceiver(t *testing.T) {
+	fmt.Println("TestCarrierReceiver")
+	key := []byte("carrier-signing-key")
+	t.Setenv("CARRIER_WEBHOOK_SECRETS", "whsec_old=, whsec_"+base64.StdEncoding.EncodeToString(key))
+	receiver, err := newCarrierReceiver()
+	if err != nil || receiver == nil {
+		t.Fatalf("failed to create receiver: %v", err)
+	}
+	router := newRouter(newMemoryStore())
+	router.POST("/webhooks/carrier", webhookHandler(receiver))
+
+	// A delivery signed as the carrier signs it, with the decoded key.
+	body := []byte(`{"type": "shipment.delivered", "orderid": "odr-1", "status": "delivered"}`)
+	nonce, timestamp := "msg_1", strconv.FormatInt(time.Now().Unix(), 10)
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/carrier", bytes.NewReader(body))
+	req.Header.Set("webhook-id", nonce)
+	req.Header.Set("webhook-timestamp", timestamp)
+	req.Header.Set("webhook-signature", webhook.StandardWebhooks.Sign(key, nonce, timestamp, body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code >= http.StatusBadRequest {
+		t.Fatalf("expected the delivery to verify, got %d %s", w.Code, w.Body)
+	}
+
+	t.Setenv("CARRIER_WEBHOOK_SECRETS", "whsec_not base64")
+	if _, err := newCarrierReceiver(); err == nil {
+		t.Fatalf("expected an undecodable secret to fail")
+	}
+	t.Setenv("CARRIER_WEBHOOK_SECRETS", "")
+	if receiver, err := newCarrierReceiver(); receiver != nil || err != nil {
+		t.Fatalf("expected no receiver without secrets, got %v", err)
+	}
+}